@@ -11,7 +11,9 @@ import (
 	"social/docs"
 	"social/internal/auth"
 	"social/internal/env"
+	"social/internal/lockout"
 	"social/internal/mailer"
+	"social/internal/media"
 	"social/internal/ratelimiter"
 	"social/internal/store"
 	"social/internal/store/cache"
@@ -21,6 +23,7 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
+	"github.com/go-webauthn/webauthn/webauthn"
 	httpSwagger "github.com/swaggo/http-swagger"
 	"go.uber.org/zap"
 )
@@ -33,6 +36,11 @@ type application struct {
 	mailer        mailer.Client
 	authenticator auth.Authenticator
 	rateLimiter   ratelimiter.Limiter
+	webauthn      *webauthn.WebAuthn
+	emailLockout  lockout.Tracker
+	ipLockout     lockout.Tracker
+	mfaLockout    lockout.Tracker
+	blobStore     media.BlobStore
 }
 
 type config struct {
@@ -45,6 +53,39 @@ type config struct {
 	auth        authConfig
 	redisCfg    redisConfig
 	rateLimiter ratelimiter.Config
+	webauthn    webauthnConfig
+	lockout     lockoutConfig
+	suggestions suggestionsConfig
+	media       mediaConfig
+}
+
+type mediaConfig struct {
+	dir            string // where the local blob store keeps uploads
+	baseURL        string // where the API serves them back
+	maxUploadBytes int64
+	unattachedExp  time.Duration // uploads no post uses are deleted after this
+}
+
+type suggestionsConfig struct {
+	refreshEvery time.Duration
+	activeWithin time.Duration // users seen this recently get theirs precomputed
+	maxRefresh   int           // at most this many of them per refresh, the most recently seen first
+	workers      int           // rankings run at once during a refresh
+	cacheExp     time.Duration
+}
+
+type lockoutConfig struct {
+	email     lockout.Config
+	ip        lockout.Config
+	mfa       lockout.Config // wrong second factor codes, per user
+	unlockExp time.Duration
+}
+
+type webauthnConfig struct {
+	rpID          string
+	rpDisplayName string
+	rpOrigins     []string
+	sessionExp    time.Duration
 }
 
 type redisConfig struct {
@@ -60,9 +101,16 @@ type authConfig struct {
 }
 
 type tokenConfig struct {
-	secret string
-	exp    time.Duration
-	iss    string
+	secret     string
+	exp        time.Duration
+	refreshExp time.Duration
+	mfaExp     time.Duration
+	iss        string
+	keysDir    string // directory of PEM signing keys, empty to use the HS256 secret
+	signingKID string
+
+	// lifetime of an impersonation token, they can't be refreshed
+	impersonationExp time.Duration
 }
 
 type basicConfig struct {
@@ -71,9 +119,13 @@ type basicConfig struct {
 }
 
 type mailConfig struct {
-	sendGrid  sendGridConfig
-	fromEmail string
-	exp       time.Duration
+	sendGrid       sendGridConfig
+	fromEmail      string
+	exp            time.Duration
+	resetExp       time.Duration
+	purgeExp       time.Duration // never activated accounts are deleted after this
+	emailChangeExp time.Duration
+	emailRevertExp time.Duration
 }
 
 type sendGridConfig struct {
@@ -102,9 +154,9 @@ func (app *application) mount() http.Handler {
 	r.Use(middleware.Recoverer) // Recover from panics
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{env.GetString("CORS_ALLOWED_ORIGIN", "http://localhost:5174")},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
+		ExposedHeaders:   []string{"Link", "X-Impersonated-By", "X-Impersonation-ID"},
 		AllowCredentials: false,
 		MaxAge:           300, // Maximum value not ignored by any of major browsers
 	}))
@@ -124,43 +176,183 @@ func (app *application) mount() http.Handler {
 		// r.With(app.BasicAuthMiddleware()).Get("/health", app.healthcheckHandler)
 		r.Get("/health", app.healthcheckHandler)
 		r.With(app.BasicAuthMiddleware()).Get("/metrics", expvar.Handler().ServeHTTP)
+		r.Get("/.well-known/jwks.json", app.jwksHandler)
 
 		docsURL := fmt.Sprintf("%s/swagger/doc.json", app.config.addr)
 		r.Get("/swagger/*", httpSwagger.Handler(httpSwagger.URL(docsURL)))
 
+		r.Route("/media", func(r chi.Router) {
+			// media URLs end up in img tags, which can't send a token. They are
+			// unguessable, keyed by a random media ID, but not checked against
+			// the post they are on: whoever has the URL of an image can fetch
+			// it, even after a block or once its author goes private. A blob
+			// store like S3 serves them without the API anyway.
+			r.Get("/files/*", app.serveMediaFileHandler)
+
+			r.Group(func(r chi.Router) {
+				r.Use(app.AuthenthicationMiddleware)
+				r.With(app.requireScope(scopePostsWrite)).Post("/", app.uploadMediaHandler)
+				r.With(app.requireScope(scopePostsRead)).Get("/{mediaID}", app.getMediaHandler)
+				r.With(app.requireScope(scopePostsWrite)).Delete("/{mediaID}", app.deleteMediaHandler)
+			})
+		})
+
 		r.Route("/posts", func(r chi.Router) {
 			r.Use(app.AuthenthicationMiddleware)
-			r.Post("/", app.createPostHandler) // No postID expected here
+			r.With(app.requireScope(scopePostsWrite)).Post("/", app.createPostHandler) // No postID expected here
 
 			r.Route("/{postID}", func(r chi.Router) {
 				r.Use(app.postsContextMiddleware) // Middleware for postID validation
-				r.Get("/", app.getPostHandler)
-				r.Delete("/", app.checkPostOwnership("admin", app.deletePostHandler))
-				r.Patch("/", app.checkPostOwnership("moderator", app.updatePostHandler))
+				r.With(app.requireScope(scopePostsRead)).Get("/", app.getPostHandler)
+				r.With(app.requireScope(scopePostsWrite)).Delete("/", app.checkPostOwnership(permPostsDelete, app.deletePostHandler))
+				r.With(app.requireScope(scopePostsWrite)).Patch("/", app.checkPostOwnership(permPostsUpdate, app.updatePostHandler))
+
+				r.With(app.requireScope(scopePostsRead)).Get("/comments", app.listCommentsHandler)
+				r.With(app.requireScope(scopePostsWrite)).Post("/comments", app.createCommentHandler)
 			})
 		})
 
+		r.Route("/comments/{commentID}", func(r chi.Router) {
+			r.Use(app.AuthenthicationMiddleware)
+			r.Use(app.commentsContextMiddleware)
+			r.With(app.requireScope(scopePostsWrite)).Patch("/", app.updateCommentHandler)
+			r.With(app.requireScope(scopePostsWrite)).Delete("/", app.checkCommentOwnership(permCommentsDelete, app.deleteCommentHandler))
+
+			r.With(app.requireScope(scopePostsRead)).Get("/replies", app.listRepliesHandler)
+			r.With(app.requireScope(scopePostsWrite)).Post("/replies", app.createReplyHandler)
+		})
+
 		r.Route("/users", func(r chi.Router) {
 			r.Put("/activate/{token}", app.activateUserHandler)
+			r.Put("/email/confirm/{token}", app.confirmEmailHandler)
+			r.Put("/email/revert/{token}", app.revertEmailHandler)
+
+			r.Route("/me", func(r chi.Router) {
+				r.Use(app.AuthenthicationMiddleware)
+
+				r.With(app.requireScope(scopeUsersRead)).Get("/", app.getMeHandler)
+				r.With(app.requireScope(scopeUsersWrite)).Patch("/", app.updateMeHandler)
+
+				r.With(app.requireScope(scopeAccount)).Post("/email", app.changeEmailHandler)
+				r.With(app.requireScope(scopeAccount)).Post("/password", app.changePasswordHandler)
+
+				r.Route("/tokens", func(r chi.Router) {
+					r.Use(app.requireScope(scopeAccount))
+					r.Get("/", app.listAccessTokensHandler)
+					r.Post("/", app.createAccessTokenHandler)
+					r.Delete("/{tokenID}", app.deleteAccessTokenHandler)
+				})
+
+				r.Route("/sessions", func(r chi.Router) {
+					r.Use(app.requireScope(scopeAccount))
+					r.Get("/", app.listSessionsHandler)
+					r.Delete("/{sessionID}", app.revokeSessionHandler)
+				})
+
+				r.With(app.requireScope(scopeAccount)).Put("/privacy", app.updatePrivacyHandler)
+
+				r.With(app.requireScope(scopeUsersRead)).Get("/suggestions", app.listSuggestionsHandler)
+				r.With(app.requireScope(scopeUsersRead)).Get("/blocks", app.listBlockedHandler)
+				r.With(app.requireScope(scopeUsersRead)).Get("/mutes", app.listMutedHandler)
+
+				r.Route("/follow-requests", func(r chi.Router) {
+					r.With(app.requireScope(scopeUsersRead)).Get("/", app.listFollowRequestsHandler)
+					r.With(app.requireScope(scopeUsersWrite)).Put("/{userID}/approve", app.approveFollowRequestHandler)
+					r.With(app.requireScope(scopeUsersWrite)).Put("/{userID}/reject", app.rejectFollowRequestHandler)
+				})
+			})
+
 			r.Route("/{userID}", func(r chi.Router) {
 				r.Use(app.AuthenthicationMiddleware)
 
-				r.Get("/", app.getUserHandler)
+				r.With(app.requireScope(scopeUsersRead)).Get("/", app.getUserHandler)
+				r.With(app.requireScope(scopeUsersRead)).Get("/followers", app.listFollowersHandler)
+				r.With(app.requireScope(scopeUsersRead)).Get("/following", app.listFollowingHandler)
 
-				r.Put("/follow", app.followUserHandler)
-				r.Put("/unfollow", app.unfollowUserHandler)
+				r.With(app.requireScope(scopeUsersWrite)).Put("/follow", app.followUserHandler)
+				r.With(app.requireScope(scopeUsersWrite)).Put("/unfollow", app.unfollowUserHandler)
+				r.With(app.requireScope(scopeUsersWrite)).Put("/block", app.blockUserHandler)
+				r.With(app.requireScope(scopeUsersWrite)).Put("/unblock", app.unblockUserHandler)
+				r.With(app.requireScope(scopeUsersWrite)).Put("/mute", app.muteUserHandler)
+				r.With(app.requireScope(scopeUsersWrite)).Put("/unmute", app.unmuteUserHandler)
 			})
 
 			r.Group(func(r chi.Router) {
 				r.Use(app.AuthenthicationMiddleware)
-				r.Get("/feed", app.getUserFeedHandler)
+				r.With(app.requireScope(scopeFeedRead)).Get("/feed", app.getUserFeedHandler)
+				r.With(app.requireScope(scopeUsersRead)).Get("/search", app.searchUsersHandler)
+			})
+		})
+
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(app.AuthenthicationMiddleware)
+			r.Use(app.requireScope(scopeAccount))
+
+			r.Group(func(r chi.Router) {
+				r.Use(app.requirePermission(permRolesManage))
+				r.Get("/permissions", app.listPermissionsHandler)
+				r.Get("/roles", app.listRolesHandler)
+				r.Post("/roles", app.createRoleHandler)
+				r.Patch("/roles/{roleID}", app.updateRoleHandler)
+				r.Get("/roles/{roleID}/users", app.listRoleUsersHandler)
+				r.Put("/users/{userID}/role", app.assignRoleHandler)
+			})
+
+			r.Group(func(r chi.Router) {
+				r.Use(app.requirePermission(permUsersImpersonate))
+				r.Post("/users/{userID}/impersonate", app.startImpersonationHandler)
+				r.Delete("/impersonations/{impersonationID}", app.endImpersonationHandler)
+			})
+
+			r.Group(func(r chi.Router) {
+				r.Use(app.requirePermission(permUsersModerate))
+				r.Get("/users/{userID}/status", app.getAccountStatusHandler)
+				r.Put("/users/{userID}/status", app.setAccountStatusHandler)
+			})
+
+			r.Group(func(r chi.Router) {
+				r.Use(app.requirePermission(permAuditRead))
+				r.Get("/audit", app.listAuditLogHandler)
+				r.Get("/audit/export", app.exportAuditLogHandler)
 			})
 		})
 
 		// Public routes
 		r.Route("/authentication", func(r chi.Router) {
 			r.Post("/user", app.registerUserHandler)
+			r.Post("/resend-activation", app.resendActivationHandler)
 			r.Post("/token", app.createTokenHandler)
+			r.Post("/unlock", app.unlockAccountHandler)
+			r.Post("/refresh", app.refreshTokenHandler)
+			r.Post("/logout", app.logoutHandler)
+			r.Post("/forgot-password", app.forgotPasswordHandler)
+			r.Post("/reset-password", app.resetPasswordHandler)
+
+			r.Route("/mfa", func(r chi.Router) {
+				r.Post("/verify", app.verifyMFAHandler)
+
+				r.Group(func(r chi.Router) {
+					r.Use(app.AuthenthicationMiddleware)
+					r.Use(app.requireScope(scopeAccount))
+					r.Post("/enroll", app.enrollMFAHandler)
+					r.Post("/confirm", app.confirmMFAHandler)
+					r.Post("/disable", app.disableMFAHandler)
+				})
+			})
+
+			r.Route("/passkeys", func(r chi.Router) {
+				r.Post("/login/begin", app.beginPasskeyLoginHandler)
+				r.Post("/login/finish", app.finishPasskeyLoginHandler)
+
+				r.Group(func(r chi.Router) {
+					r.Use(app.AuthenthicationMiddleware)
+					r.Use(app.requireScope(scopeAccount))
+					r.Get("/", app.listPasskeysHandler)
+					r.Post("/register/begin", app.beginPasskeyRegistrationHandler)
+					r.Post("/register/finish", app.finishPasskeyRegistrationHandler)
+					r.Delete("/{passkeyID}", app.deletePasskeyHandler)
+				})
+			})
 		})
 	})
 
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"social/internal/store"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// Actions recorded in the audit log besides post overrides, which are
+// recorded under the permission that allowed them.
+const (
+	auditRolesCreate = "roles:create"
+	auditRolesUpdate = "roles:update"
+	auditRolesAssign = "roles:assign"
+)
+
+// auditExportBatch is how many entries an export reads at a time.
+const auditExportBatch = 500
+
+// audit appends a privileged action to the audit log. before and after are
+// snapshots of the target, nil when there is none. The action already
+// happened, so a failure is logged rather than returned.
+func (app *application) audit(r *http.Request, action, targetType string, targetID int64, before, after any) {
+	// an impersonating admin acts, not the user they act as
+	actorID := getUserFromCtx(r).ID
+	if imp := getImpersonationFromCtx(r); imp != nil {
+		actorID = imp.ActorID
+	}
+
+	entry := &store.AuditEntry{
+		ActorID:    actorID,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		RequestID:  middleware.GetReqID(r.Context()),
+		IP:         clientIP(r),
+	}
+
+	var err error
+	if before != nil {
+		if entry.Before, err = json.Marshal(before); err != nil {
+			app.logger.Errorw("error encoding audit snapshot", "action", action, "error", err)
+		}
+	}
+	if after != nil {
+		if entry.After, err = json.Marshal(after); err != nil {
+			app.logger.Errorw("error encoding audit snapshot", "action", action, "error", err)
+		}
+	}
+
+	// recorded even when the client has gone away
+	ctx := context.WithoutCancel(r.Context())
+
+	if err := app.store.Audit.Create(ctx, entry); err != nil {
+		app.logger.Errorw(
+			"error writing audit log",
+			"action", action,
+			"actor_id", entry.ActorID,
+			"target_type", targetType,
+			"target_id", targetID,
+			"error", err,
+		)
+	}
+}
+
+type AuditLogPage struct {
+	Entries    []store.AuditEntry `json:"entries"`
+	NextCursor int64              `json:"next_cursor,omitempty"` // empty on the last page
+}
+
+// ListAuditLogHandler godoc
+//
+//	@Summary		List the audit log
+//	@Description	List privileged actions, newest first. Pass next_cursor back as cursor for the next page
+//	@Tags			admin
+//	@Produce		json
+//	@Param			actor_id	query		int		false	"Actor user ID"
+//	@Param			action		query		string	false	"Action, like posts:delete"
+//	@Param			target_type	query		string	false	"Target type, like post"
+//	@Param			target_id	query		int		false	"Target ID"
+//	@Param			since		query		string	false	"RFC 3339 time"
+//	@Param			until		query		string	false	"RFC 3339 time"
+//	@Param			limit		query		int		false	"Limit"
+//	@Param			cursor		query		int		false	"Cursor"
+//
+//	@Success		200			{object}	AuditLogPage
+//	@Failure		400			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/audit [get]
+func (app *application) listAuditLogHandler(w http.ResponseWriter, r *http.Request) {
+	q, err := app.parseAuditQuery(r, 20)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	// one extra entry tells whether there is a next page
+	q.Limit++
+
+	entries, err := app.store.Audit.Get(r.Context(), q)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	page := AuditLogPage{Entries: entries}
+	if len(entries) == q.Limit {
+		page.Entries = entries[:len(entries)-1]
+		page.NextCursor = page.Entries[len(page.Entries)-1].ID
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, page); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// ExportAuditLogHandler godoc
+//
+//	@Summary		Export the audit log
+//	@Description	Stream every entry matching the filters as JSON lines, newest first
+//	@Tags			admin
+//	@Produce		application/x-ndjson
+//	@Param			actor_id	query		int		false	"Actor user ID"
+//	@Param			action		query		string	false	"Action, like posts:delete"
+//	@Param			target_type	query		string	false	"Target type, like post"
+//	@Param			target_id	query		int		false	"Target ID"
+//	@Param			since		query		string	false	"RFC 3339 time"
+//	@Param			until		query		string	false	"RFC 3339 time"
+//
+//	@Success		200			{string}	string	"One store.AuditEntry per line"
+//	@Failure		400			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/audit/export [get]
+func (app *application) exportAuditLogHandler(w http.ResponseWriter, r *http.Request) {
+	q, err := app.parseAuditQuery(r, 20)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	// exports aren't paged, the limit only sizes the reads
+	q.Limit = auditExportBatch
+
+	ctx := r.Context()
+
+	// the first batch is read before any header goes out, so an error can
+	// still be reported properly
+	entries, err := app.store.Audit.Get(ctx, q)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", `attachment; filename="audit.jsonl"`)
+	w.WriteHeader(http.StatusOK)
+
+	enc := json.NewEncoder(w)
+	flusher, _ := w.(http.Flusher)
+
+	for len(entries) > 0 {
+		for _, e := range entries {
+			if err := enc.Encode(e); err != nil {
+				app.logger.Warnw("audit export interrupted", "error", err)
+				return
+			}
+		}
+
+		if flusher != nil {
+			flusher.Flush()
+		}
+
+		if len(entries) < q.Limit {
+			return
+		}
+
+		q.Cursor = entries[len(entries)-1].ID
+
+		entries, err = app.store.Audit.Get(ctx, q)
+		if err != nil {
+			// too late for an error response, the file ends short
+			app.logger.Errorw("error exporting audit log", "error", err)
+			return
+		}
+	}
+}
+
+func (app *application) parseAuditQuery(r *http.Request, limit int) (store.AuditQuery, error) {
+	q := store.AuditQuery{Limit: limit}
+
+	q, err := q.Parse(r)
+	if err != nil {
+		return q, err
+	}
+
+	if err := Validate.Struct(q); err != nil {
+		return q, err
+	}
+
+	return q, nil
+}
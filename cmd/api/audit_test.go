@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"social/internal/store"
+)
+
+func TestAuditLog(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app.store.Roles.(*store.MockRoleStore).Permissions = store.Permissions{permRolesManage, permAuditRead}
+	auditLog := app.store.Audit.(*store.MockAuditStore)
+
+	t.Run("should record role assignments", func(t *testing.T) {
+		for _, userID := range []string{"2", "3", "4"} {
+			req, err := http.NewRequest(http.MethodPut, "/v1/admin/users/"+userID+"/role", strings.NewReader(`{"role_id":2}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req.Header.Set("Authorization", "Bearer "+testToken)
+
+			checkResponseCode(t, http.StatusNoContent, executeRequest(req, mux).Code)
+		}
+
+		if len(auditLog.Entries) != 3 {
+			t.Fatalf("expected 3 audit entries, got %d", len(auditLog.Entries))
+		}
+
+		if e := auditLog.Entries[0]; e.Action != auditRolesAssign || e.ActorID != 1 || e.TargetID != 2 {
+			t.Errorf("unexpected audit entry %+v", e)
+		}
+	})
+
+	t.Run("should page with a cursor", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/admin/audit?limit=2", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+testToken)
+
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusOK, rr.Code)
+
+		var body struct {
+			Data AuditLogPage `json:"data"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(body.Data.Entries) != 2 || body.Data.NextCursor != 2 {
+			t.Errorf("expected 2 entries and cursor 2, got %d entries and cursor %d", len(body.Data.Entries), body.Data.NextCursor)
+		}
+	})
+
+	t.Run("should export JSON lines", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/admin/audit/export", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+testToken)
+
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusOK, rr.Code)
+
+		lines := 0
+		scanner := bufio.NewScanner(rr.Body)
+		for scanner.Scan() {
+			var e store.AuditEntry
+			if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+				t.Fatal(err)
+			}
+			lines++
+		}
+
+		if lines != 3 {
+			t.Errorf("expected 3 lines, got %d", lines)
+		}
+	})
+}
@@ -67,12 +67,8 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 
 	plainToken := uuid.New().String()
 
-	// store
-	hash := sha256.Sum256([]byte(plainToken))
-	hashToken := hex.EncodeToString(hash[:])
-
 	// store the user
-	err := app.store.Users.CreateAndInvite(ctx, user, hashToken, app.config.mail.exp)
+	err := app.store.Users.CreateAndInvite(ctx, user, hashToken(plainToken), app.config.mail.exp)
 	if err != nil {
 		switch err {
 		case store.ErrDuplicateEmail:
@@ -122,6 +118,76 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 	}
 }
 
+type ResendActivationPayload struct {
+	Email string `json:"email" validate:"required,email,max=255"`
+}
+
+// ResendActivationHandler godoc
+//
+//	@Summary		Resend the activation email
+//	@Description	Mails a new activation link to an account that isn't active yet, previous links stop working
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		ResendActivationPayload	true	"Account email"
+//
+//	@success		202		{string}	string					"Activation email sent"
+//	@failure		400		{object}	error
+//	@failure		500		{object}	error
+//	@Router			/authentication/resend-activation [post]
+func (app *application) resendActivationHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ResendActivationPayload
+	if err := readJson(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	// the response is the same whether there is an inactive account or not
+	response := "if an inactive account with that email exists, an activation link has been sent"
+
+	plainToken := uuid.New().String()
+
+	user, err := app.store.Users.RotateInvitation(r.Context(), payload.Email, hashToken(plainToken), app.config.mail.exp)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			if err := app.jsonResponse(w, http.StatusAccepted, response); err != nil {
+				app.internalServerError(w, r, err)
+			}
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	vars := struct {
+		Username      string
+		ActivationURL string
+	}{
+		Username:      user.Username,
+		ActivationURL: fmt.Sprintf("%s/confirm/%s", app.config.frontendURL, plainToken),
+	}
+
+	isProdEnv := app.config.env == "development"
+
+	// mail in the background so the response time doesn't tell whether the account exists
+	go func() {
+		err := app.mailer.SendEmail(mailer.UserWelcomeTemplate, user.Username, user.Email, vars, !isProdEnv)
+		if err != nil {
+			app.logger.Errorw("error sending activation email", "error", err)
+		}
+	}()
+
+	if err := app.jsonResponse(w, http.StatusAccepted, response); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
 type CreateUserTokenPayload struct {
 	Email    string `json:"email" validate:"required,email,max=255"`
 	Password string `json:"password" validate:"required,min=3,max=72"`
@@ -136,9 +202,12 @@ type CreateUserTokenPayload struct {
 //	@Produce		json
 //	@Param			payload	body		CreateUserTokenPayload	true	"User credentials"
 //
-//	@success		200		{string}	string					"Token created"
+//	@success		201		{object}	TokenPair				"Token created"
+//	@success		202		{object}	MFAChallenge			"Second factor required"
 //	@failure		400		{object}	error
 //	@failure		401		{object}	error
+//	@failure		403		{object}	error	"Account suspended or banned"
+//	@failure		429		{object}	error	"Too many failed attempts"
 //	@failure		500		{object}	error
 //	@Router			/authentication/token [post]
 func (app *application) createTokenHandler(w http.ResponseWriter, r *http.Request) {
@@ -153,11 +222,27 @@ func (app *application) createTokenHandler(w http.ResponseWriter, r *http.Reques
 		app.badRequestResponse(w, r, err)
 		return
 	}
+	ctx := r.Context()
+	ip := clientIP(r)
+
+	// a locked out login is refused before the password is even looked at
+	lockedFor, err := app.loginLockedFor(ctx, payload.Email, ip)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if lockedFor > 0 {
+		app.loginLockedResponse(w, r, lockedFor)
+		return
+	}
+
 	// fetch user (check if user exists) from the payload
-	user, err := app.store.Users.GetByEmail(r.Context(), payload.Email)
+	user, err := app.store.Users.GetByEmail(ctx, payload.Email)
 	if err != nil {
 		switch err {
 		case store.ErrNotFound:
+			app.recordFailedLogin(ctx, payload.Email, ip, nil)
 			app.unauthorizedErrorResponse(w, r, err)
 		default:
 			app.internalServerError(w, r, err)
@@ -166,27 +251,265 @@ func (app *application) createTokenHandler(w http.ResponseWriter, r *http.Reques
 	}
 
 	if err := user.Password.Compare(payload.Password); err != nil {
+		app.recordFailedLogin(ctx, payload.Email, ip, user)
 		app.unauthorizedErrorResponse(w, r, err)
 		return
 	}
 
+	// only told to whoever knows the password
+	if !user.InGoodStanding(time.Now()) {
+		app.accountRestrictedResponse(w, r, user.AccountStatus)
+		return
+	}
+
+	// upgrade the hash while the password is at hand, a failure keeps the old one working
+	if user.Password.NeedsRehash() {
+		if err := user.Password.Set(payload.Password); err != nil {
+			app.logger.Errorw("error rehashing password", "user_id", user.ID, "error", err)
+		} else if err := app.store.Users.UpdatePassword(ctx, user); err != nil {
+			app.logger.Errorw("error rehashing password", "user_id", user.ID, "error", err)
+		}
+	}
+
+	mfa, err := app.store.MFA.GetByUserID(ctx, user.ID)
+	if err != nil && err != store.ErrNotFound {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	// with 2FA the password only buys a challenge, see verifyMFAHandler. The
+	// failed logins are forgotten once the second factor passes too, or the
+	// password alone would keep lifting the lockout
+	if mfa.Enabled() {
+		challenge, err := app.newMFAChallenge(user.ID)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		if err := app.jsonResponse(w, http.StatusAccepted, challenge); err != nil {
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.emailLockout.Reset(ctx, lockoutEmailKey(payload.Email)); err != nil {
+		app.logger.Errorw("error resetting failed logins", "error", err)
+	}
+
+	tokens, err := app.issueTokens(r, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	// send it to the client
+	if err := app.jsonResponse(w, http.StatusCreated, tokens); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+type RefreshTokenPayload struct {
+	RefreshToken string `json:"refresh_token" validate:"required,max=255"`
+}
+
+// RefreshTokenHandler godoc
+//
+//	@Summary		Refresh a token
+//	@Description	Exchange a refresh token for a new access and refresh token pair
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		RefreshTokenPayload	true	"Refresh token"
+//
+//	@success		201		{object}	TokenPair			"Token refreshed"
+//	@failure		400		{object}	error
+//	@failure		401		{object}	error
+//	@failure		403		{object}	error	"Account suspended or banned"
+//	@failure		500		{object}	error
+//	@Router			/authentication/refresh [post]
+func (app *application) refreshTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var payload RefreshTokenPayload
+	if err := readJson(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	plainRefresh := uuid.New().String()
+	next := &store.RefreshToken{
+		Token:     hashToken(plainRefresh),
+		ExpiresAt: time.Now().Add(app.config.auth.token.refreshExp),
+	}
+
+	err := app.store.RefreshTokens.Rotate(r.Context(), hashToken(payload.RefreshToken), next)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.unauthorizedErrorResponse(w, r, err)
+		case store.ErrRefreshTokenReused:
+			app.logger.Warnw("refresh token reuse detected, family revoked", "user_id", next.UserID)
+			app.denySessions(r.Context(), next.FamilyID)
+			app.unauthorizedErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	// the token it replaced is spent, a restricted account logs in again
+	// once it is reinstated
+	user, err := app.store.Users.GetByID(r.Context(), next.UserID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.unauthorizedErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if !user.InGoodStanding(time.Now()) {
+		app.accountRestrictedResponse(w, r, user.AccountStatus)
+		return
+	}
+
+	tokens, err := app.newTokenPair(next.UserID, next.FamilyID, plainRefresh)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, tokens); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// LogoutHandler godoc
+//
+//	@Summary		Logout
+//	@Description	Revoke a refresh token and every token rotated from the same login
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		RefreshTokenPayload	true	"Refresh token"
+//
+//	@success		204		{string}	string				"Logged out"
+//	@failure		400		{object}	error
+//	@failure		500		{object}	error
+//	@Router			/authentication/logout [post]
+func (app *application) logoutHandler(w http.ResponseWriter, r *http.Request) {
+	var payload RefreshTokenPayload
+	if err := readJson(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	sessionID, err := app.store.RefreshTokens.RevokeFamily(r.Context(), hashToken(payload.RefreshToken))
+	if err != nil && err != store.ErrNotFound {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if sessionID != "" {
+		app.denySessions(r.Context(), sessionID)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// JWKSHandler godoc
+//
+//	@Summary		JSON Web Key Set
+//	@Description	Public keys that validate the access tokens issued by this API
+//	@Tags			authentication
+//	@Produce		json
+//
+//	@success		200	{object}	auth.JSONWebKeySet
+//	@Router			/.well-known/jwks.json [get]
+func (app *application) jwksHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+
+	// served without the data envelope, JWKS clients expect the keys at the top level
+	if err := writeJson(w, http.StatusOK, app.authenticator.JWKS()); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"` // access token lifetime in seconds
+}
+
+// issueTokens starts a new session, and its refresh token family, for a fresh
+// login from the device making the request.
+func (app *application) issueTokens(r *http.Request, userID int64) (*TokenPair, error) {
+	ctx := r.Context()
+
+	session := &store.Session{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		UserAgent: r.UserAgent(),
+		IP:        clientIP(r),
+	}
+
+	if err := app.store.Sessions.Create(ctx, session); err != nil {
+		return nil, err
+	}
+
+	plainRefresh := uuid.New().String()
+	refresh := &store.RefreshToken{
+		Token:     hashToken(plainRefresh),
+		UserID:    userID,
+		FamilyID:  session.ID, // a new login starts a new rotation family
+		ExpiresAt: time.Now().Add(app.config.auth.token.refreshExp),
+	}
+
+	if err := app.store.RefreshTokens.Create(ctx, refresh); err != nil {
+		return nil, err
+	}
+
+	return app.newTokenPair(userID, session.ID, plainRefresh)
+}
+
+func (app *application) newTokenPair(userID int64, sessionID string, refreshToken string) (*TokenPair, error) {
 	// generate the token -> add claims
 	claims := jwt.MapClaims{
-		"sub": user.ID,
+		"sub": userID,
+		"jti": sessionID,
 		"exp": time.Now().Add(app.config.auth.token.exp).Unix(),
 		"iat": time.Now().Unix(),
 		"nbf": time.Now().Unix(),
 		"iss": app.config.auth.token.iss,
 		"aud": app.config.auth.token.iss,
 	}
+
 	token, err := app.authenticator.GenerateToken(claims)
 	if err != nil {
-		app.internalServerError(w, r, err)
-		return
+		return nil, err
 	}
-	// send it to the client
 
-	if err := app.jsonResponse(w, http.StatusCreated, token); err != nil {
-		app.internalServerError(w, r, err)
-	}
+	return &TokenPair{
+		AccessToken:  token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(app.config.auth.token.exp.Seconds()),
+	}, nil
+}
+
+// hashToken returns the representation of an opaque token that is persisted,
+// so a database leak does not hand out usable tokens.
+func hashToken(plain string) string {
+	hash := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(hash[:])
 }
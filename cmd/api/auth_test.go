@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"social/internal/lockout"
+	"social/internal/store"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRefreshToken(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+
+	t.Run("should reject a missing refresh token", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/v1/authentication/refresh", strings.NewReader(`{}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := executeRequest(req, mux)
+
+		checkResponseCode(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("should rotate a valid refresh token", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/v1/authentication/refresh", strings.NewReader(`{"refresh_token":"some-token"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := executeRequest(req, mux)
+
+		checkResponseCode(t, http.StatusCreated, rr.Code)
+
+		if !strings.Contains(rr.Body.String(), `"refresh_token"`) {
+			t.Errorf("expected a new refresh token in the response, got %s", rr.Body.String())
+		}
+	})
+
+	t.Run("should refuse to refresh a restricted account", func(t *testing.T) {
+		users := app.store.Users.(*store.MockUserStore)
+		users.Statuses = map[int64]store.AccountStatus{
+			1: {State: store.AccountBanned, StateReason: "spam"},
+		}
+		defer func() { users.Statuses = nil }()
+
+		req, err := http.NewRequest(http.MethodPost, "/v1/authentication/refresh", strings.NewReader(`{"refresh_token":"some-token"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		checkResponseCode(t, http.StatusForbidden, executeRequest(req, mux).Code)
+	})
+
+	t.Run("should logout", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/v1/authentication/logout", strings.NewReader(`{"refresh_token":"some-token"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := executeRequest(req, mux)
+
+		checkResponseCode(t, http.StatusNoContent, rr.Code)
+	})
+}
+
+func TestResetPassword(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+
+	t.Run("should reject a password that is too short", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/v1/authentication/reset-password", strings.NewReader(`{"token":"some-token","password":"ab"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := executeRequest(req, mux)
+
+		checkResponseCode(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("should reset the password", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/v1/authentication/reset-password", strings.NewReader(`{"token":"some-token","password":"new-password"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := executeRequest(req, mux)
+
+		checkResponseCode(t, http.StatusNoContent, rr.Code)
+	})
+}
+
+func TestLoginLockout(t *testing.T) {
+	app := newTestApplication(t, config{
+		lockout: lockoutConfig{
+			email: lockout.Config{MaxAttempts: 3, BaseDelay: time.Minute, MaxDelay: time.Hour, Window: time.Hour},
+			ip:    lockout.Config{MaxAttempts: 10, BaseDelay: time.Minute, MaxDelay: time.Hour, Window: time.Hour},
+		},
+	})
+	mux := app.mount()
+
+	login := func(email string) int {
+		body := `{"email":"` + email + `","password":"wrong-password"}`
+		req, err := http.NewRequest(http.MethodPost, "/v1/authentication/token", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return executeRequest(req, mux).Code
+	}
+
+	t.Run("should lock an email out after too many failures", func(t *testing.T) {
+		for range 3 {
+			checkResponseCode(t, http.StatusUnauthorized, login("gopher@example.com"))
+		}
+
+		checkResponseCode(t, http.StatusTooManyRequests, login("Gopher@example.com"))
+	})
+
+	t.Run("should lock an IP out across emails", func(t *testing.T) {
+		// 3 failures were already counted against the IP above
+		for i := range 7 {
+			checkResponseCode(t, http.StatusUnauthorized, login(fmt.Sprintf("gopher%d@example.com", i)))
+		}
+
+		checkResponseCode(t, http.StatusTooManyRequests, login("someone-else@example.com"))
+	})
+
+	t.Run("should reject a token that isn't an unlock token", func(t *testing.T) {
+		testToken, err := app.authenticator.GenerateToken(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req, err := http.NewRequest(http.MethodPost, "/v1/authentication/unlock", strings.NewReader(`{"token":"`+testToken+`"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		checkResponseCode(t, http.StatusUnauthorized, executeRequest(req, mux).Code)
+	})
+}
+
+func TestResendActivation(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+
+	t.Run("should reject an invalid email", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/v1/authentication/resend-activation", strings.NewReader(`{"email":"nope"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		checkResponseCode(t, http.StatusBadRequest, executeRequest(req, mux).Code)
+	})
+
+	t.Run("should accept the request", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/v1/authentication/resend-activation", strings.NewReader(`{"email":"gopher@example.com"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		checkResponseCode(t, http.StatusAccepted, executeRequest(req, mux).Code)
+	})
+}
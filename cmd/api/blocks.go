@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"social/internal/store"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// getVisibleUser is getUser for looking at someone else's profile: a user
+// who blocked the viewer, or was blocked by them, is store.ErrNotFound, and so
+// is a suspended or banned one unless they look themselves up.
+func (app *application) getVisibleUser(ctx context.Context, userID, viewerID int64) (*store.User, error) {
+	user, err := app.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if userID != viewerID && !user.InGoodStanding(time.Now()) {
+		return nil, store.ErrNotFound
+	}
+
+	blocked, err := app.store.Blocks.IsBlocked(ctx, userID, viewerID)
+	if err != nil {
+		return nil, err
+	}
+
+	if blocked {
+		return nil, store.ErrNotFound
+	}
+
+	return user, nil
+}
+
+// BlockUserHandler godoc
+//
+//	@Summary		Block a user
+//	@Description	Block a user, the follows between you both are dropped and neither can follow, comment on or look up the other
+//	@Tags			users
+//	@Produce		json
+//	@Param			userID	path		int		true	"User ID"
+//
+//	@Success		204		{string}	string	"User blocked"
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error	"Already blocked"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/block [put]
+func (app *application) blockUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.changeRelation(w, r, app.store.Blocks.Block)
+}
+
+// UnblockUserHandler godoc
+//
+//	@Summary		Unblock a user
+//	@Description	Unblock a user, the follows the block dropped stay dropped
+//	@Tags			users
+//	@Produce		json
+//	@Param			userID	path		int		true	"User ID"
+//
+//	@Success		204		{string}	string	"User unblocked"
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error	"Not blocked"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/unblock [put]
+func (app *application) unblockUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.changeRelation(w, r, app.store.Blocks.Unblock)
+}
+
+// MuteUserHandler godoc
+//
+//	@Summary		Mute a user
+//	@Description	Hide the posts of a user from your feed, they aren't told
+//	@Tags			users
+//	@Produce		json
+//	@Param			userID	path		int		true	"User ID"
+//
+//	@Success		204		{string}	string	"User muted"
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error	"Already muted"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/mute [put]
+func (app *application) muteUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.changeRelation(w, r, app.store.Blocks.Mute)
+}
+
+// UnmuteUserHandler godoc
+//
+//	@Summary		Unmute a user
+//	@Description	Show the posts of a muted user in your feed again
+//	@Tags			users
+//	@Produce		json
+//	@Param			userID	path		int		true	"User ID"
+//
+//	@Success		204		{string}	string	"User unmuted"
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error	"Not muted"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/unmute [put]
+func (app *application) unmuteUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.changeRelation(w, r, app.store.Blocks.Unmute)
+}
+
+func (app *application) changeRelation(w http.ResponseWriter, r *http.Request, change func(ctx context.Context, userID, otherID int64) error) {
+	otherID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromCtx(r)
+	if otherID == user.ID {
+		app.badRequestResponse(w, r, errors.New("you can't block or mute yourself"))
+		return
+	}
+
+	if err := change(r.Context(), user.ID, otherID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		case store.ErrorConflict:
+			app.conflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.forgetSuggestions(r.Context(), user.ID, otherID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListBlockedHandler godoc
+//
+//	@Summary		List blocked users
+//	@Description	List the users the current user has blocked, most recent first
+//	@Tags			users
+//	@Produce		json
+//	@Param			limit	query		int	false	"Limit"
+//	@Param			offset	query		int	false	"Offset"
+//
+//	@Success		200		{object}	[]store.RelatedUser
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/blocks [get]
+func (app *application) listBlockedHandler(w http.ResponseWriter, r *http.Request) {
+	app.listRelated(w, r, app.store.Blocks.GetBlocked)
+}
+
+// ListMutedHandler godoc
+//
+//	@Summary		List muted users
+//	@Description	List the users the current user has muted, most recent first
+//	@Tags			users
+//	@Produce		json
+//	@Param			limit	query		int	false	"Limit"
+//	@Param			offset	query		int	false	"Offset"
+//
+//	@Success		200		{object}	[]store.RelatedUser
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/mutes [get]
+func (app *application) listMutedHandler(w http.ResponseWriter, r *http.Request) {
+	app.listRelated(w, r, app.store.Blocks.GetMuted)
+}
+
+func (app *application) listRelated(w http.ResponseWriter, r *http.Request, list func(ctx context.Context, userID int64, q store.PaginatedQuery) ([]store.RelatedUser, error)) {
+	q := store.PaginatedQuery{
+		Limit:  20,
+		Offset: 0,
+	}
+
+	q, err := q.Parse(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(q); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	users, err := list(r.Context(), getUserFromCtx(r).ID, q)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, users); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
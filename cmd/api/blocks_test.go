@@ -0,0 +1,82 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestBlocks(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should hide the profile of a user who blocked you", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/users/4", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+testToken)
+
+		checkResponseCode(t, http.StatusNotFound, executeRequest(req, mux).Code)
+	})
+
+	t.Run("should hide the followers of a user who blocked you", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/users/4/followers", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+testToken)
+
+		checkResponseCode(t, http.StatusNotFound, executeRequest(req, mux).Code)
+	})
+
+	t.Run("should not block yourself", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPut, "/v1/users/1/block", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+testToken)
+
+		checkResponseCode(t, http.StatusBadRequest, executeRequest(req, mux).Code)
+	})
+
+	t.Run("should block a user", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPut, "/v1/users/2/block", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+testToken)
+
+		checkResponseCode(t, http.StatusNoContent, executeRequest(req, mux).Code)
+	})
+
+	t.Run("should not unmute a user who isn't muted", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPut, "/v1/users/2/unmute", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+testToken)
+
+		checkResponseCode(t, http.StatusNotFound, executeRequest(req, mux).Code)
+	})
+
+	t.Run("should list muted users", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/users/me/mutes", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+testToken)
+
+		checkResponseCode(t, http.StatusOK, executeRequest(req, mux).Code)
+	})
+}
@@ -0,0 +1,484 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"social/internal/store"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+type commentKey string
+
+const commentCtx commentKey = "comment"
+
+// Comments are listed with this many levels of replies unless the depth query
+// param says otherwise, each comment with up to repliesPerComment of them.
+const (
+	defaultReplyDepth = 2
+	repliesPerComment = 3
+)
+
+type CommentPayload struct {
+	Content string `json:"content" validate:"required,max=1000"`
+}
+
+type CommentPage struct {
+	Comments   []store.Comment `json:"comments"`
+	NextCursor string          `json:"next_cursor,omitempty"` // empty on the last page
+}
+
+// CreateCommentHandler godoc
+//
+//	@Summary		Comment on a post
+//	@Description	Comment on a post the caller can see, users blocked with the author can't
+//	@Tags			comments
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path		int				true	"Post ID"
+//	@Param			payload	body		CommentPayload	true	"Comment"
+//
+//	@Success		201		{object}	store.Comment
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/comments [post]
+func (app *application) createCommentHandler(w http.ResponseWriter, r *http.Request) {
+	app.writeComment(w, r, getPostFromCtx(r), nil)
+}
+
+// CreateReplyHandler godoc
+//
+//	@Summary		Reply to a comment
+//	@Description	Reply to a comment, users blocked with the author of the post or of the comment can't
+//	@Tags			comments
+//	@Accept			json
+//	@Produce		json
+//	@Param			commentID	path		int				true	"Comment ID"
+//	@Param			payload		body		CommentPayload	true	"Reply"
+//
+//	@Success		201			{object}	store.Comment
+//	@Failure		400			{object}	error
+//	@Failure		404			{object}	error	"Unknown or deleted comment"
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/comments/{commentID}/replies [post]
+func (app *application) createReplyHandler(w http.ResponseWriter, r *http.Request) {
+	parent := getCommentFromCtx(r)
+	if parent.Deleted {
+		app.notFoundResponse(w, r, errors.New("comment not found"))
+		return
+	}
+
+	post, ok := app.getCommentPost(w, r, parent)
+	if !ok {
+		return
+	}
+
+	app.writeComment(w, r, post, &parent.ID)
+}
+
+// writeComment creates a comment on the post from the payload, a reply when
+// parentID is set.
+func (app *application) writeComment(w http.ResponseWriter, r *http.Request, post *store.Post, parentID *int64) {
+	var payload CommentPayload
+	if err := readJson(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if !app.checkPostVisible(w, r, post) {
+		return
+	}
+
+	user := getUserFromCtx(r)
+
+	comment := &store.Comment{
+		PostID:   post.ID,
+		ParentID: parentID,
+		UserID:   user.ID,
+		Content:  payload.Content,
+		User:     store.User{ID: user.ID, Username: user.Username},
+	}
+
+	if err := app.store.Comments.Create(r.Context(), comment); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, errors.New("post or comment not found"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, comment); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// ListCommentsHandler godoc
+//
+//	@Summary		List the comments of a post
+//	@Description	List the top level comments of a post, oldest first unless sort is desc, with their replies depth levels deep. Pass next_cursor back as cursor, with the same sort, for the next page. A comment with more replies than came along has a replies_cursor for /comments/{id}/replies, one with replies past the depth only has its replies_count
+//	@Tags			comments
+//	@Produce		json
+//	@Param			postID	path		int		true	"Post ID"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			sort	query		string	false	"asc (oldest first) or desc (newest first)"
+//	@Param			cursor	query		string	false	"Cursor"
+//	@Param			depth	query		int		false	"Levels of replies, 0 to 5"
+//
+//	@Success		200		{object}	CommentPage
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/comments [get]
+func (app *application) listCommentsHandler(w http.ResponseWriter, r *http.Request) {
+	app.listComments(w, r, getPostFromCtx(r), app.store.Comments.GetByPostID, store.CommentQuery{Sort: "asc"})
+}
+
+// ListRepliesHandler godoc
+//
+//	@Summary		List the replies to a comment
+//	@Description	List the replies to a comment, oldest first unless sort is desc, with their own replies depth levels deep. Pass a replies_cursor as cursor to load the replies that didn't come along with the comment
+//	@Tags			comments
+//	@Produce		json
+//	@Param			commentID	path		int		true	"Comment ID"
+//	@Param			limit		query		int		false	"Limit"
+//	@Param			sort		query		string	false	"asc (oldest first) or desc (newest first)"
+//	@Param			cursor		query		string	false	"Cursor"
+//	@Param			depth		query		int		false	"Levels of replies, 0 to 5"
+//
+//	@Success		200			{object}	CommentPage
+//	@Failure		400			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/comments/{commentID}/replies [get]
+func (app *application) listRepliesHandler(w http.ResponseWriter, r *http.Request) {
+	parent := getCommentFromCtx(r)
+
+	post, ok := app.getCommentPost(w, r, parent)
+	if !ok {
+		return
+	}
+
+	// the replies of a comment list, so the parent's ID stands in for the post's
+	list := func(ctx context.Context, _, viewerID int64, q store.CommentQuery) ([]store.Comment, error) {
+		return app.store.Comments.GetReplies(ctx, parent.ID, viewerID, q)
+	}
+
+	app.listComments(w, r, post, list, store.CommentQuery{Sort: "asc"})
+}
+
+type commentListFunc func(ctx context.Context, postID, viewerID int64, q store.CommentQuery) ([]store.Comment, error)
+
+func (app *application) listComments(w http.ResponseWriter, r *http.Request, post *store.Post, list commentListFunc, q store.CommentQuery) {
+	q.Limit = 20
+	q.Depth = defaultReplyDepth
+
+	q, err := q.Parse(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(q); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if !app.checkPostVisible(w, r, post) {
+		return
+	}
+
+	ctx := r.Context()
+	viewerID := getUserFromCtx(r).ID
+
+	// one extra comment tells whether there is a next page
+	q.Limit++
+
+	comments, err := list(ctx, post.ID, viewerID, q)
+	if err != nil {
+		switch err {
+		case store.ErrInvalidCursor:
+			app.badRequestResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	page := CommentPage{Comments: comments}
+	if len(comments) == q.Limit {
+		page.Comments = comments[:len(comments)-1]
+		page.NextCursor = store.CommentCursor(page.Comments[len(page.Comments)-1])
+	}
+
+	if err := app.loadReplies(ctx, page.Comments, viewerID, q.Depth); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, page); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// UpdateCommentHandler godoc
+//
+//	@Summary		Edit a comment
+//	@Description	Edit a comment of the caller's, it is marked with edited_at
+//	@Tags			comments
+//	@Accept			json
+//	@Produce		json
+//	@Param			commentID	path		int				true	"Comment ID"
+//	@Param			payload		body		CommentPayload	true	"Comment"
+//
+//	@Success		200			{object}	store.Comment
+//	@Failure		400			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/comments/{commentID} [patch]
+func (app *application) updateCommentHandler(w http.ResponseWriter, r *http.Request) {
+	comment := getCommentFromCtx(r)
+	if comment.Deleted {
+		app.notFoundResponse(w, r, errors.New("comment not found"))
+		return
+	}
+
+	// moderators remove comments, they don't put words in anyone's mouth
+	if comment.UserID != getUserFromCtx(r).ID {
+		app.forbiddenResponse(w, r)
+		return
+	}
+
+	var payload CommentPayload
+	if err := readJson(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	comment.Content = payload.Content
+
+	if err := app.store.Comments.Update(r.Context(), comment); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, comment); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// DeleteCommentHandler godoc
+//
+//	@Summary		Delete a comment
+//	@Description	Delete a comment of the caller's, or any comment with the comments:delete permission. A comment with replies is left as a [deleted] placeholder without content or author
+//	@Tags			comments
+//	@Produce		json
+//	@Param			commentID	path		int		true	"Comment ID"
+//
+//	@Success		204			{string}	string	"Comment deleted"
+//	@Failure		400			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/comments/{commentID} [delete]
+func (app *application) deleteCommentHandler(w http.ResponseWriter, r *http.Request) {
+	comment := getCommentFromCtx(r)
+
+	if err := app.store.Comments.Delete(r.Context(), comment.ID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// checkPostVisible answers not found when the caller can't see the post, as
+// getPostHandler does.
+func (app *application) checkPostVisible(w http.ResponseWriter, r *http.Request, post *store.Post) bool {
+	allowed, err := app.store.Followers.CanView(r.Context(), post.UserID, getUserFromCtx(r).ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return false
+	}
+
+	if !allowed {
+		app.notFoundResponse(w, r, errors.New("post not found"))
+		return false
+	}
+
+	return true
+}
+
+// getCommentPost reads the post of a comment, answering not found when the
+// caller can't see it.
+func (app *application) getCommentPost(w http.ResponseWriter, r *http.Request, comment *store.Comment) (*store.Post, bool) {
+	post, err := app.store.Posts.GetByID(r.Context(), comment.PostID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, errors.New("post not found"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return nil, false
+	}
+
+	if !app.checkPostVisible(w, r, post) {
+		return nil, false
+	}
+
+	return post, true
+}
+
+// loadReplies fills in the replies of the comments, and theirs, down to depth
+// levels, a level at a time. Each comment gets up to repliesPerComment of
+// them, and a RepliesCursor when it has more.
+func (app *application) loadReplies(ctx context.Context, comments []store.Comment, viewerID int64, depth int) error {
+	level := make([]*store.Comment, 0, len(comments))
+	for i := range comments {
+		level = append(level, &comments[i])
+	}
+
+	for ; depth > 0 && len(level) > 0; depth-- {
+		var parentIDs []int64
+		for _, c := range level {
+			if c.RepliesCount > 0 {
+				parentIDs = append(parentIDs, c.ID)
+			}
+		}
+
+		if len(parentIDs) == 0 {
+			return nil
+		}
+
+		// one extra reply tells whether there are more
+		replies, err := app.store.Comments.GetRepliesOf(ctx, parentIDs, viewerID, repliesPerComment+1)
+		if err != nil {
+			return err
+		}
+
+		var next []*store.Comment
+		for _, c := range level {
+			c.Replies = replies[c.ID]
+			if len(c.Replies) > repliesPerComment {
+				c.Replies = c.Replies[:repliesPerComment]
+				c.RepliesCursor = store.CommentCursor(c.Replies[len(c.Replies)-1])
+			}
+
+			for i := range c.Replies {
+				next = append(next, &c.Replies[i])
+			}
+		}
+
+		level = next
+	}
+
+	return nil
+}
+
+// checkCommentOwnership lets the author of the comment through, anyone else
+// needs the permission and the override is recorded in the audit log under it.
+func (app *application) checkCommentOwnership(permission string, next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		comment := getCommentFromCtx(r)
+
+		// placeholders have no author left, and nothing more to remove
+		if comment.Deleted {
+			app.notFoundResponse(w, r, errors.New("comment not found"))
+			return
+		}
+
+		if comment.UserID == getUserFromCtx(r).ID {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		allowed, err := app.hasPermission(r.Context(), getUserFromCtx(r), permission)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		if !allowed {
+			app.forbiddenResponse(w, r)
+			return
+		}
+
+		before := *comment
+
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		if ww.Status() >= http.StatusBadRequest {
+			return
+		}
+
+		app.audit(r, permission, "comment", comment.ID, before, nil)
+	})
+}
+
+func (app *application) commentsContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "commentID"), 10, 64)
+		if err != nil {
+			app.badRequestResponse(w, r, errors.New("invalid commentID"))
+			return
+		}
+
+		ctx := r.Context()
+
+		comment, err := app.store.Comments.GetByID(ctx, id)
+		if err != nil {
+			switch err {
+			case store.ErrNotFound:
+				app.notFoundResponse(w, r, errors.New("comment not found"))
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		ctx = context.WithValue(ctx, commentCtx, comment)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func getCommentFromCtx(r *http.Request) *store.Comment {
+	comment, ok := r.Context().Value(commentCtx).(*store.Comment)
+	if !ok {
+		return nil
+	}
+	return comment
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"social/internal/store"
+)
+
+func TestComments(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := func(t *testing.T, method, path, body string) int {
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+testToken)
+
+		return executeRequest(req, mux).Code
+	}
+
+	t.Run("should comment on a post", func(t *testing.T) {
+		checkResponseCode(t, http.StatusCreated, request(t, http.MethodPost, "/v1/posts/1/comments", `{"content":"nice"}`))
+	})
+
+	t.Run("should validate comments", func(t *testing.T) {
+		checkResponseCode(t, http.StatusBadRequest, request(t, http.MethodPost, "/v1/posts/1/comments", `{"content":""}`))
+		checkResponseCode(t, http.StatusBadRequest, request(t, http.MethodPost, "/v1/posts/1/comments", `{"content":"`+strings.Repeat("a", 1001)+`"}`))
+	})
+
+	t.Run("should not comment on posts the user can't see", func(t *testing.T) {
+		// post 3 is by user 3, a private account
+		checkResponseCode(t, http.StatusNotFound, request(t, http.MethodPost, "/v1/posts/3/comments", `{"content":"nice"}`))
+		checkResponseCode(t, http.StatusNotFound, request(t, http.MethodGet, "/v1/posts/3/comments", ""))
+	})
+
+	t.Run("should list comments", func(t *testing.T) {
+		checkResponseCode(t, http.StatusOK, request(t, http.MethodGet, "/v1/posts/1/comments?sort=desc&limit=10", ""))
+		checkResponseCode(t, http.StatusBadRequest, request(t, http.MethodGet, "/v1/posts/1/comments?sort=random", ""))
+		checkResponseCode(t, http.StatusBadRequest, request(t, http.MethodGet, "/v1/posts/1/comments?limit=1000", ""))
+	})
+
+	t.Run("should reply to comments", func(t *testing.T) {
+		checkResponseCode(t, http.StatusCreated, request(t, http.MethodPost, "/v1/comments/1/replies", `{"content":"indeed"}`))
+		checkResponseCode(t, http.StatusBadRequest, request(t, http.MethodPost, "/v1/comments/1/replies", `{"content":""}`))
+
+		// comment 9 is a deleted placeholder
+		checkResponseCode(t, http.StatusNotFound, request(t, http.MethodPost, "/v1/comments/9/replies", `{"content":"indeed"}`))
+	})
+
+	t.Run("should list comment trees to a depth", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/posts/1/comments?depth=2", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+testToken)
+
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusOK, rr.Code)
+
+		var body struct {
+			Data CommentPage `json:"data"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+
+		top := body.Data.Comments[0]
+		if len(top.Replies) != repliesPerComment || top.RepliesCursor == "" {
+			t.Fatalf("expected %d replies and a cursor, got %d replies and %q", repliesPerComment, len(top.Replies), top.RepliesCursor)
+		}
+
+		reply := top.Replies[0]
+		if len(reply.Replies) != repliesPerComment {
+			t.Errorf("expected %d nested replies, got %d", repliesPerComment, len(reply.Replies))
+		}
+
+		if deepest := reply.Replies[0]; len(deepest.Replies) != 0 || deepest.RepliesCount == 0 {
+			t.Errorf("expected replies past the depth to only be counted, got %d replies", len(deepest.Replies))
+		}
+
+		checkResponseCode(t, http.StatusBadRequest, request(t, http.MethodGet, "/v1/posts/1/comments?depth=6", ""))
+	})
+
+	t.Run("should load more replies", func(t *testing.T) {
+		checkResponseCode(t, http.StatusOK, request(t, http.MethodGet, "/v1/comments/1/replies?depth=1", ""))
+		checkResponseCode(t, http.StatusOK, request(t, http.MethodGet, "/v1/comments/9/replies", ""))
+	})
+
+	t.Run("should not edit or delete placeholders", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNotFound, request(t, http.MethodPatch, "/v1/comments/9", `{"content":"back"}`))
+		checkResponseCode(t, http.StatusNotFound, request(t, http.MethodDelete, "/v1/comments/9", ""))
+	})
+
+	t.Run("should only let the author edit", func(t *testing.T) {
+		checkResponseCode(t, http.StatusOK, request(t, http.MethodPatch, "/v1/comments/1", `{"content":"edited"}`))
+		checkResponseCode(t, http.StatusForbidden, request(t, http.MethodPatch, "/v1/comments/2", `{"content":"edited"}`))
+	})
+
+	t.Run("should let the author and moderators delete", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNoContent, request(t, http.MethodDelete, "/v1/comments/1", ""))
+		checkResponseCode(t, http.StatusForbidden, request(t, http.MethodDelete, "/v1/comments/2", ""))
+
+		app.store.Roles.(*store.MockRoleStore).Permissions = store.Permissions{permCommentsDelete}
+		checkResponseCode(t, http.StatusNoContent, request(t, http.MethodDelete, "/v1/comments/2", ""))
+	})
+}
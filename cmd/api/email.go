@@ -0,0 +1,208 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"social/internal/mailer"
+	"social/internal/store"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+type ChangeEmailPayload struct {
+	Email    string `json:"email" validate:"required,email,max=255"`
+	Password string `json:"password" validate:"required,max=72"`
+}
+
+// ChangeEmailHandler godoc
+//
+//	@Summary		Change email
+//	@Description	Mails a confirmation link to the new address and a revert link to the current one, the email changes once confirmed
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		ChangeEmailPayload	true	"New email and current password"
+//
+//	@Success		202		{string}	string				"Confirmation sent"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/email [post]
+func (app *application) changeEmailHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ChangeEmailPayload
+	if err := readJson(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	user, ok := app.checkPassword(w, r, payload.Password)
+	if !ok {
+		return
+	}
+
+	if strings.EqualFold(payload.Email, user.Email) {
+		app.badRequestResponse(w, r, errors.New("this is already your email"))
+		return
+	}
+
+	plainToken := uuid.New().String()
+	plainRevertToken := uuid.New().String()
+
+	change := &store.EmailChange{
+		UserID:          user.ID,
+		OldEmail:        user.Email,
+		NewEmail:        payload.Email,
+		Token:           hashToken(plainToken),
+		RevertToken:     hashToken(plainRevertToken),
+		ExpiresAt:       time.Now().Add(app.config.mail.emailChangeExp),
+		RevertExpiresAt: time.Now().Add(app.config.mail.emailRevertExp),
+	}
+
+	if err := app.store.Users.CreateEmailChange(ctx, change); err != nil {
+		switch err {
+		case store.ErrDuplicateEmail:
+			app.badRequestResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	confirmVars := struct {
+		Username   string
+		ConfirmURL string
+		ExpiresIn  string
+	}{
+		Username:   user.Username,
+		ConfirmURL: fmt.Sprintf("%s/confirm-email/%s", app.config.frontendURL, plainToken),
+		ExpiresIn:  app.config.mail.emailChangeExp.String(),
+	}
+
+	noticeVars := struct {
+		Username  string
+		NewEmail  string
+		RevertURL string
+		ExpiresIn string
+	}{
+		Username:  user.Username,
+		NewEmail:  payload.Email,
+		RevertURL: fmt.Sprintf("%s/revert-email/%s", app.config.frontendURL, plainRevertToken),
+		ExpiresIn: app.config.mail.emailRevertExp.String(),
+	}
+
+	isProdEnv := app.config.env == "development"
+
+	go func() {
+		err := app.mailer.SendEmail(mailer.EmailChangeTemplate, user.Username, payload.Email, confirmVars, !isProdEnv)
+		if err != nil {
+			app.logger.Errorw("error sending email change confirmation", "error", err)
+		}
+
+		err = app.mailer.SendEmail(mailer.EmailNoticeTemplate, user.Username, user.Email, noticeVars, !isProdEnv)
+		if err != nil {
+			app.logger.Errorw("error sending email change notice", "error", err)
+		}
+	}()
+
+	if err := app.jsonResponse(w, http.StatusAccepted, "a confirmation link has been sent to the new address"); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// ConfirmEmailHandler godoc
+//
+//	@Summary		Confirm an email change
+//	@Description	Switch the account to the new email with the link mailed to it
+//	@Tags			users
+//	@Produce		json
+//	@Param			token	path		string	true	"Confirmation token"
+//
+//	@Success		204		{string}	string	"Email changed"
+//	@Failure		400		{object}	error	"Email already taken"
+//	@Failure		404		{object}	error	"Unknown token"
+//	@Failure		410		{object}	error	"Expired token"
+//	@Failure		500		{object}	error
+//	@Router			/users/email/confirm/{token} [put]
+func (app *application) confirmEmailHandler(w http.ResponseWriter, r *http.Request) {
+	token := chi.URLParam(r, "token")
+
+	ctx := r.Context()
+
+	change, err := app.store.Users.ConfirmEmailChange(ctx, hashToken(token))
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		case store.ErrTokenExpired:
+			app.goneResponse(w, r, errors.New("confirmation link has expired, change your email again"))
+		case store.ErrDuplicateEmail:
+			app.badRequestResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.forgetUser(ctx, change.UserID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RevertEmailHandler godoc
+//
+//	@Summary		Revert an email change
+//	@Description	Cancel an email change, or restore the previous email, with the link mailed to the previous address. Every session is logged out
+//	@Tags			users
+//	@Produce		json
+//	@Param			token	path		string	true	"Revert token"
+//
+//	@Success		204		{string}	string	"Email change reverted"
+//	@Failure		400		{object}	error	"Email already taken"
+//	@Failure		404		{object}	error	"Unknown token"
+//	@Failure		410		{object}	error	"Expired token"
+//	@Failure		500		{object}	error
+//	@Router			/users/email/revert/{token} [put]
+func (app *application) revertEmailHandler(w http.ResponseWriter, r *http.Request) {
+	token := chi.URLParam(r, "token")
+
+	ctx := r.Context()
+
+	change, err := app.store.Users.RevertEmailChange(ctx, hashToken(token))
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		case store.ErrTokenExpired:
+			app.goneResponse(w, r, errors.New("revert link has expired"))
+		case store.ErrDuplicateEmail:
+			app.badRequestResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.forgetUser(ctx, change.UserID)
+
+	// whoever changed the email may still be logged in
+	sessionIDs, err := app.store.Sessions.RevokeAll(ctx, change.UserID, "")
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	app.denySessions(ctx, sessionIDs...)
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestChangeEmail(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should require the current password", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/v1/users/me/email", strings.NewReader(`{"email":"new@example.com","password":"wrong"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+testToken)
+
+		checkResponseCode(t, http.StatusUnauthorized, executeRequest(req, mux).Code)
+	})
+
+	t.Run("should confirm the new email", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPut, "/v1/users/email/confirm/some-token", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		checkResponseCode(t, http.StatusNoContent, executeRequest(req, mux).Code)
+	})
+
+	t.Run("should revert the change and log out every session", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPut, "/v1/users/email/revert/some-token", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		checkResponseCode(t, http.StatusNoContent, executeRequest(req, mux).Code)
+	})
+}
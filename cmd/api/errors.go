@@ -2,6 +2,9 @@ package main
 
 import (
 	"net/http"
+	"social/internal/store"
+	"strconv"
+	"time"
 )
 
 func (app *application) internalServerError(w http.ResponseWriter, r *http.Request, err error) {
@@ -43,6 +46,24 @@ func (app *application) conflictResponse(w http.ResponseWriter, r *http.Request,
 	writeJSONError(w, http.StatusConflict, err.Error())
 }
 
+func (app *application) goneResponse(w http.ResponseWriter, r *http.Request, err error) {
+	app.logger.Warnw("gone response", "method", r.Method, "path", r.URL.Path, "error", err.Error())
+
+	writeJSONError(w, http.StatusGone, err.Error())
+}
+
+func (app *application) payloadTooLargeResponse(w http.ResponseWriter, r *http.Request, err error) {
+	app.logger.Warnw("payload too large", "method", r.Method, "path", r.URL.Path, "error", err.Error())
+
+	writeJSONError(w, http.StatusRequestEntityTooLarge, err.Error())
+}
+
+func (app *application) unsupportedMediaTypeResponse(w http.ResponseWriter, r *http.Request, err error) {
+	app.logger.Warnw("unsupported media type", "method", r.Method, "path", r.URL.Path, "error", err.Error())
+
+	writeJSONError(w, http.StatusUnsupportedMediaType, err.Error())
+}
+
 func (app *application) unauthorizedErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
 
 	app.logger.Warnf("unauthorized error", "method", r.Method, "path", r.URL.Path, "error", err.Error())
@@ -50,6 +71,24 @@ func (app *application) unauthorizedErrorResponse(w http.ResponseWriter, r *http
 	writeJSONError(w, http.StatusUnauthorized, "unauthorized")
 }
 
+// accountRestrictedResponse tells a suspended or banned user why they are
+// refused, and until when.
+func (app *application) accountRestrictedResponse(w http.ResponseWriter, r *http.Request, status store.AccountStatus) {
+	app.logger.Warnw("account restricted", "method", r.Method, "path", r.URL.Path, "state", status.State)
+
+	message := "your account has been banned"
+	if status.State == store.AccountSuspended && status.SuspendedUntil != nil {
+		w.Header().Set("Retry-After", strconv.Itoa(int(time.Until(*status.SuspendedUntil).Seconds())+1))
+		message = "your account is suspended until " + status.SuspendedUntil.UTC().Format(time.RFC3339)
+	}
+
+	if status.StateReason != "" {
+		message += ", reason: " + status.StateReason
+	}
+
+	writeJSONError(w, http.StatusForbidden, message)
+}
+
 func (app *application) unauthorizedBasicErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
 
 	app.logger.Warnf("unauthorized basic error", "method", r.Method, "path", r.URL.Path, "error", err.Error())
@@ -66,3 +105,11 @@ func (app *application) rateLimitExceededResponse(w http.ResponseWriter, r *http
 
 	writeJSONError(w, http.StatusTooManyRequests, "rate limit exceeded, retry after: "+retryAfter)
 }
+
+func (app *application) loginLockedResponse(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+	app.logger.Warnw("login locked out", "method", r.Method, "path", r.URL.Path, "retry_after", retryAfter)
+
+	w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+
+	writeJSONError(w, http.StatusTooManyRequests, "too many failed login attempts, retry after: "+retryAfter.Round(time.Second).String())
+}
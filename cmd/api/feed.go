@@ -43,13 +43,17 @@ func (app *application) getUserFeedHandler(w http.ResponseWriter, r *http.Reques
 	}
 
 	ctx := r.Context()
-	feed, err := app.store.Posts.GetUserFeed(ctx, int64(42), fq)
+	feed, err := app.store.Posts.GetUserFeed(ctx, getUserFromCtx(r).ID, fq)
 
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 
+	for i := range feed {
+		app.setPostMediaURLs(&feed[i].Post)
+	}
+
 	if err := app.jsonResponse(w, http.StatusOK, feed); err != nil {
 		app.internalServerError(w, r, err)
 	}
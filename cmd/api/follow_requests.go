@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"social/internal/store"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type UpdatePrivacyPayload struct {
+	IsPrivate *bool `json:"is_private" validate:"required"`
+}
+
+// UpdatePrivacyHandler godoc
+//
+//	@Summary		Make the account private or public
+//	@Description	Posts of a private account only show to approved followers. Going public approves every pending follow request
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		UpdatePrivacyPayload	true	"Privacy"
+//
+//	@Success		204		{string}	string					"Privacy updated"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/privacy [put]
+func (app *application) updatePrivacyHandler(w http.ResponseWriter, r *http.Request) {
+	var payload UpdatePrivacyPayload
+	if err := readJson(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	user := getUserFromCtx(r)
+
+	if err := app.store.Followers.SetPrivate(ctx, user.ID, *payload.IsPrivate); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.forgetUser(ctx, user.ID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListFollowRequestsHandler godoc
+//
+//	@Summary		List follow requests
+//	@Description	List the pending requests to follow the current user, oldest first
+//	@Tags			users
+//	@Produce		json
+//	@Param			limit	query		int	false	"Limit"
+//	@Param			offset	query		int	false	"Offset"
+//
+//	@Success		200		{object}	[]store.FollowRequest
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/follow-requests [get]
+func (app *application) listFollowRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	q := store.PaginatedQuery{
+		Limit:  20,
+		Offset: 0,
+	}
+
+	q, err := q.Parse(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(q); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	requests, err := app.store.Followers.GetRequests(r.Context(), getUserFromCtx(r).ID, q)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, requests); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// ApproveFollowRequestHandler godoc
+//
+//	@Summary		Approve a follow request
+//	@Description	Let a user who asked to follow the current user follow them
+//	@Tags			users
+//	@Produce		json
+//	@Param			userID	path		int		true	"ID of the user who sent the request"
+//
+//	@Success		204		{string}	string	"Request approved"
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error	"No such request"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/follow-requests/{userID}/approve [put]
+func (app *application) approveFollowRequestHandler(w http.ResponseWriter, r *http.Request) {
+	app.answerFollowRequest(w, r, app.store.Followers.ApproveRequest)
+}
+
+// RejectFollowRequestHandler godoc
+//
+//	@Summary		Reject a follow request
+//	@Description	Drop the request of a user who asked to follow the current user
+//	@Tags			users
+//	@Produce		json
+//	@Param			userID	path		int		true	"ID of the user who sent the request"
+//
+//	@Success		204		{string}	string	"Request rejected"
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error	"No such request"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/follow-requests/{userID}/reject [put]
+func (app *application) rejectFollowRequestHandler(w http.ResponseWriter, r *http.Request) {
+	app.answerFollowRequest(w, r, app.store.Followers.RejectRequest)
+}
+
+func (app *application) answerFollowRequest(w http.ResponseWriter, r *http.Request, answer func(ctx context.Context, userID, followerID int64) error) {
+	followerID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := answer(r.Context(), getUserFromCtx(r).ID, followerID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
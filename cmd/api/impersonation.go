@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"social/internal/store"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+type impersonationKey string
+
+const impersonationCtx impersonationKey = "impersonation"
+
+// Actions recorded in the audit log for impersonations.
+const (
+	auditImpersonationStart = "users:impersonate"
+	auditImpersonationEnd   = "impersonation:end"
+	auditImpersonationWrite = "impersonation:write"
+)
+
+var errImpersonationEnded = errors.New("impersonation has ended")
+
+type ImpersonatePayload struct {
+	Reason string `json:"reason" validate:"required,max=500"`
+	Write  bool   `json:"write"` // read-only unless set
+}
+
+type ImpersonationToken struct {
+	AccessToken   string               `json:"access_token"`
+	ExpiresIn     int64                `json:"expires_in"` // seconds
+	Impersonation *store.Impersonation `json:"impersonation"`
+}
+
+// StartImpersonationHandler godoc
+//
+//	@Summary		Impersonate a user
+//	@Description	Mint a short lived token acting as the user, read-only unless write is set. The token can't manage the account, every response carries X-Impersonated-By, and the impersonation is audited
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			userID	path		int					true	"User ID"
+//	@Param			payload	body		ImpersonatePayload	true	"Reason and access"
+//
+//	@Success		201		{object}	ImpersonationToken
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/users/{userID}/impersonate [post]
+func (app *application) startImpersonationHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	var payload ImpersonatePayload
+	if err := readJson(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	actor := getUserFromCtx(r)
+	if userID == actor.ID {
+		app.badRequestResponse(w, r, errors.New("you can't impersonate yourself"))
+		return
+	}
+
+	ctx := r.Context()
+
+	target, err := app.store.Users.GetByID(ctx, userID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	// acting as another admin would hand out their permissions
+	privileged, err := app.hasPermission(ctx, target, permUsersImpersonate)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if privileged {
+		app.forbiddenResponse(w, r)
+		return
+	}
+
+	imp := &store.Impersonation{
+		ID:        uuid.New().String(),
+		ActorID:   actor.ID,
+		UserID:    target.ID,
+		Reason:    payload.Reason,
+		Write:     payload.Write,
+		ExpiresAt: time.Now().Add(app.config.auth.token.impersonationExp),
+	}
+
+	if err := app.store.Impersonations.Create(ctx, imp); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	claims := jwt.MapClaims{
+		"sub": target.ID,
+		"jti": imp.ID,
+		"act": map[string]any{"sub": actor.ID},
+		"exp": imp.ExpiresAt.Unix(),
+		"iat": time.Now().Unix(),
+		"nbf": time.Now().Unix(),
+		"iss": app.config.auth.token.iss,
+		"aud": app.config.auth.token.iss,
+	}
+
+	token, err := app.authenticator.GenerateToken(claims)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.audit(r, auditImpersonationStart, "user", target.ID, nil, imp)
+
+	response := ImpersonationToken{
+		AccessToken:   token,
+		ExpiresIn:     int64(app.config.auth.token.impersonationExp.Seconds()),
+		Impersonation: imp,
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, response); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// EndImpersonationHandler godoc
+//
+//	@Summary		End an impersonation
+//	@Description	Revoke an impersonation token before it expires
+//	@Tags			admin
+//	@Produce		json
+//	@Param			impersonationID	path		string	true	"Impersonation ID"
+//
+//	@Success		204				{string}	string	"Impersonation ended"
+//	@Failure		400				{object}	error
+//	@Failure		403				{object}	error
+//	@Failure		404				{object}	error
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/impersonations/{impersonationID} [delete]
+func (app *application) endImpersonationHandler(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "impersonationID")
+	if err := Validate.Var(id, "uuid"); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	imp, err := app.store.Impersonations.GetByID(ctx, id)
+	if err == nil {
+		err = app.store.Impersonations.End(ctx, id)
+	}
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.audit(r, auditImpersonationEnd, "user", imp.UserID, imp, nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// checkImpersonation makes sure an impersonation token is still live, was
+// minted for the user and actor it claims and that the actor may still
+// impersonate.
+func (app *application) checkImpersonation(ctx context.Context, id string, userID int64, claims jwt.MapClaims) (*store.Impersonation, error) {
+	act, _ := claims["act"].(map[string]any)
+
+	actorID, err := strconv.ParseInt(fmt.Sprintf("%.f", act["sub"]), 10, 64)
+	if err != nil {
+		return nil, errImpersonationEnded
+	}
+
+	imp, err := app.store.Impersonations.GetByID(ctx, id)
+	if err != nil {
+		if err == store.ErrNotFound {
+			return nil, errImpersonationEnded
+		}
+		return nil, err
+	}
+
+	if imp.UserID != userID || imp.ActorID != actorID {
+		return nil, errImpersonationEnded
+	}
+
+	// an actor whose role lost the permission can't keep using its tokens
+	allowed, err := app.hasPermission(ctx, &store.User{ID: actorID}, permUsersImpersonate)
+	if err != nil {
+		return nil, err
+	}
+
+	if !allowed {
+		return nil, errImpersonationEnded
+	}
+
+	return imp, nil
+}
+
+// serveImpersonated flags the response, refuses writes to read-only
+// impersonations and records the writes of the others.
+func (app *application) serveImpersonated(w http.ResponseWriter, r *http.Request, next http.Handler, imp *store.Impersonation) {
+	w.Header().Set("X-Impersonated-By", strconv.FormatInt(imp.ActorID, 10))
+	w.Header().Set("X-Impersonation-ID", imp.ID)
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	if !imp.Write {
+		app.forbiddenResponse(w, r)
+		return
+	}
+
+	ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+	next.ServeHTTP(ww, r)
+
+	app.audit(r, auditImpersonationWrite, "user", imp.UserID, nil, map[string]any{
+		"impersonation_id": imp.ID,
+		"method":           r.Method,
+		"path":             r.URL.Path,
+		"status":           ww.Status(),
+	})
+}
+
+func getImpersonationFromCtx(r *http.Request) *store.Impersonation {
+	imp, ok := r.Context().Value(impersonationCtx).(*store.Impersonation)
+	if !ok {
+		return nil
+	}
+	return imp
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"social/internal/store"
+)
+
+func TestImpersonation(t *testing.T) {
+	cfg := config{
+		auth: authConfig{
+			token: tokenConfig{impersonationExp: time.Minute},
+		},
+	}
+
+	app := newTestApplication(t, cfg)
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app.store.Roles.(*store.MockRoleStore).Permissions = store.Permissions{permUsersImpersonate}
+
+	impersonate := func(t *testing.T, payload string) string {
+		req, err := http.NewRequest(http.MethodPost, "/v1/admin/users/2/impersonate", strings.NewReader(payload))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+testToken)
+
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusCreated, rr.Code)
+
+		var body struct {
+			Data ImpersonationToken `json:"data"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+
+		return body.Data.AccessToken
+	}
+
+	t.Run("should flag responses and allow reads", func(t *testing.T) {
+		token := impersonate(t, `{"reason":"broken feed"}`)
+
+		req, err := http.NewRequest(http.MethodGet, "/v1/users/2", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusOK, rr.Code)
+
+		if got := rr.Header().Get("X-Impersonated-By"); got != "1" {
+			t.Errorf("expected X-Impersonated-By 1, got %q", got)
+		}
+	})
+
+	t.Run("should be read-only by default", func(t *testing.T) {
+		token := impersonate(t, `{"reason":"broken feed"}`)
+
+		req, err := http.NewRequest(http.MethodPut, "/v1/users/3/follow", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		checkResponseCode(t, http.StatusForbidden, executeRequest(req, mux).Code)
+	})
+
+	t.Run("should never change credentials", func(t *testing.T) {
+		token := impersonate(t, `{"reason":"broken feed","write":true}`)
+
+		req, err := http.NewRequest(http.MethodPost, "/v1/users/me/password", strings.NewReader(`{"current_password":"x","new_password":"new-password"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		checkResponseCode(t, http.StatusForbidden, executeRequest(req, mux).Code)
+	})
+
+	t.Run("should end once the actor can no longer impersonate", func(t *testing.T) {
+		token := impersonate(t, `{"reason":"broken feed"}`)
+
+		roles := app.store.Roles.(*store.MockRoleStore)
+		roles.Permissions = nil
+		defer func() { roles.Permissions = store.Permissions{permUsersImpersonate} }()
+
+		req, err := http.NewRequest(http.MethodGet, "/v1/users/2", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		checkResponseCode(t, http.StatusUnauthorized, executeRequest(req, mux).Code)
+	})
+
+	t.Run("should record the impersonation", func(t *testing.T) {
+		entries := app.store.Audit.(*store.MockAuditStore).Entries
+		if len(entries) == 0 || entries[0].Action != auditImpersonationStart || entries[0].ActorID != 1 {
+			t.Errorf("expected the impersonation in the audit log, got %+v", entries)
+		}
+	})
+}
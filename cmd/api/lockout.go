@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"social/internal/mailer"
+	"social/internal/store"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// loginLockedFor returns how long logins are locked for the email or the
+// client IP, whichever is longer.
+func (app *application) loginLockedFor(ctx context.Context, email, ip string) (time.Duration, error) {
+	emailLocked, err := app.emailLockout.Locked(ctx, lockoutEmailKey(email))
+	if err != nil {
+		return 0, err
+	}
+
+	ipLocked, err := app.ipLockout.Locked(ctx, ip)
+	if err != nil {
+		return 0, err
+	}
+
+	return max(emailLocked, ipLocked), nil
+}
+
+// recordFailedLogin counts a failed login against the email and the client
+// IP. The owner of the account, when there is one, is mailed the first time
+// it gets locked.
+func (app *application) recordFailedLogin(ctx context.Context, email, ip string, user *store.User) {
+	if _, _, err := app.ipLockout.Fail(ctx, ip); err != nil {
+		app.logger.Errorw("error recording failed login", "ip", ip, "error", err)
+	}
+
+	failures, lockedFor, err := app.emailLockout.Fail(ctx, lockoutEmailKey(email))
+	if err != nil {
+		app.logger.Errorw("error recording failed login", "email", email, "error", err)
+		return
+	}
+
+	if user == nil || lockedFor == 0 || failures != app.config.lockout.email.MaxAttempts {
+		return
+	}
+
+	app.logger.Warnw("account locked out after failed logins", "user_id", user.ID, "ip", ip)
+
+	token, err := app.newUnlockToken(user.ID)
+	if err != nil {
+		app.logger.Errorw("error creating unlock token", "error", err)
+		return
+	}
+
+	vars := struct {
+		Username  string
+		Failures  int
+		LockedFor string
+		UnlockURL string
+		ExpiresIn string
+	}{
+		Username:  user.Username,
+		Failures:  failures,
+		LockedFor: lockedFor.String(),
+		UnlockURL: fmt.Sprintf("%s/unlock/%s", app.config.frontendURL, token),
+		ExpiresIn: app.config.lockout.unlockExp.String(),
+	}
+
+	isProdEnv := app.config.env == "development"
+
+	go func() {
+		err := app.mailer.SendEmail(mailer.AccountLockedTemplate, user.Username, user.Email, vars, !isProdEnv)
+		if err != nil {
+			app.logger.Errorw("error sending account locked email", "error", err)
+		}
+	}()
+}
+
+// newUnlockToken signs a token that only unlockAccountHandler accepts, it has
+// no session so AuthenthicationMiddleware refuses it.
+func (app *application) newUnlockToken(userID int64) (string, error) {
+	claims := jwt.MapClaims{
+		"sub":    userID,
+		"exp":    time.Now().Add(app.config.lockout.unlockExp).Unix(),
+		"iat":    time.Now().Unix(),
+		"nbf":    time.Now().Unix(),
+		"iss":    app.config.auth.token.iss,
+		"aud":    app.config.auth.token.iss,
+		"unlock": true,
+	}
+
+	return app.authenticator.GenerateToken(claims)
+}
+
+type UnlockAccountPayload struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// UnlockAccountHandler godoc
+//
+//	@Summary		Unlock an account
+//	@Description	Lift the login lockout of an account with the link mailed when it was locked
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		UnlockAccountPayload	true	"Unlock token"
+//
+//	@success		204		{string}	string					"Account unlocked"
+//	@failure		400		{object}	error
+//	@failure		401		{object}	error
+//	@failure		500		{object}	error
+//	@Router			/authentication/unlock [post]
+func (app *application) unlockAccountHandler(w http.ResponseWriter, r *http.Request) {
+	var payload UnlockAccountPayload
+	if err := readJson(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	jwtToken, err := app.authenticator.ValidateToken(payload.Token)
+	if err != nil {
+		app.unauthorizedErrorResponse(w, r, err)
+		return
+	}
+
+	claims, _ := jwtToken.Claims.(jwt.MapClaims)
+	if unlock, _ := claims["unlock"].(bool); !unlock {
+		app.unauthorizedErrorResponse(w, r, errors.New("not an unlock token"))
+		return
+	}
+
+	userID, err := userIDFromClaims(claims)
+	if err != nil {
+		app.unauthorizedErrorResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	user, err := app.store.Users.GetByID(ctx, userID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.unauthorizedErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	// the IP counter is left alone, it isn't tied to this account
+	if err := app.emailLockout.Reset(ctx, lockoutEmailKey(user.Email)); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func lockoutEmailKey(email string) string {
+	return strings.ToLower(email)
+}
+
+func lockoutMFAKey(userID int64) string {
+	return strconv.FormatInt(userID, 10)
+}
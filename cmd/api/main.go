@@ -1,20 +1,25 @@
 package main
 
 import (
+	"context"
 	"log"
 	"runtime"
 	"social/internal/auth"
 	"social/internal/db"
 	"social/internal/env"
+	"social/internal/lockout"
 	"social/internal/mailer"
+	"social/internal/media"
 	"social/internal/ratelimiter"
 	"social/internal/store"
 	"social/internal/store/cache"
+	"strings"
 	"time"
 
 	"expvar"
 
 	"github.com/go-redis/redis/v8"
+	"github.com/go-webauthn/webauthn/webauthn"
 	"go.uber.org/zap"
 )
 
@@ -57,8 +62,12 @@ func main() {
 		},
 		env: env.GetString("ENV", "development"),
 		mail: mailConfig{
-			exp:       time.Hour * 24 * 3, // 3 days
-			fromEmail: env.GetString("FROM_EMAIL", ""),
+			exp:            time.Hour * 24 * 3, // 3 days
+			resetExp:       time.Hour,
+			purgeExp:       time.Hour * 24 * 7, // 7 days
+			emailChangeExp: time.Hour * 24,
+			emailRevertExp: time.Hour * 24 * 7, // 7 days
+			fromEmail:      env.GetString("FROM_EMAIL", ""),
 			sendGrid: sendGridConfig{
 				apiKey: env.GetString("SENDGRID_API_KEY", ""),
 			},
@@ -70,16 +79,64 @@ func main() {
 				pass: env.GetString("Auth_BASIC_PASS", "admin"),
 			},
 			token: tokenConfig{
-				secret: env.GetString("AUTH_TOKEN_SECRET", "secret"),
-				exp:    time.Hour * 24 * 3, // 3 days
-				iss:    "gophersocial",
+				secret:     env.GetString("AUTH_TOKEN_SECRET", "secret"),
+				exp:        time.Minute * 15,
+				refreshExp: time.Hour * 24 * 30, // 30 days
+				mfaExp:     time.Minute * 5,
+				iss:        "gophersocial",
+				keysDir:    env.GetString("AUTH_TOKEN_KEYS_DIR", ""),
+				signingKID: env.GetString("AUTH_TOKEN_SIGNING_KID", ""),
+
+				impersonationExp: time.Minute * 30,
+			},
+		},
+		webauthn: webauthnConfig{
+			rpID:          env.GetString("WEBAUTHN_RP_ID", "localhost"),
+			rpDisplayName: "GopherSocial",
+			rpOrigins:     strings.Split(env.GetString("WEBAUTHN_RP_ORIGINS", "http://localhost:4000"), ","),
+			sessionExp:    time.Minute * 5,
+		},
+		lockout: lockoutConfig{
+			email: lockout.Config{
+				MaxAttempts: env.GetInt("LOGIN_MAX_ATTEMPTS_PER_EMAIL", 5),
+				BaseDelay:   time.Minute,
+				MaxDelay:    time.Hour,
+				Window:      time.Hour * 24,
 			},
+			// higher budget, an IP can be shared by a whole office behind a NAT
+			ip: lockout.Config{
+				MaxAttempts: env.GetInt("LOGIN_MAX_ATTEMPTS_PER_IP", 50),
+				BaseDelay:   time.Minute,
+				MaxDelay:    time.Hour,
+				Window:      time.Hour,
+			},
+			// a 6 digit code falls to guessing much sooner than a password
+			mfa: lockout.Config{
+				MaxAttempts: env.GetInt("LOGIN_MAX_ATTEMPTS_PER_MFA", 5),
+				BaseDelay:   time.Minute * 5,
+				MaxDelay:    time.Hour * 24,
+				Window:      time.Hour * 24,
+			},
+			unlockExp: time.Hour,
 		},
 		rateLimiter: ratelimiter.Config{
 			RequestsPerTimeFrame: env.GetInt("RATE_LIMITER_REQUESTS_COUNT", 20),
 			TimeFrame:            time.Minute * 5,
 			Enabled:              env.GetBool("RATE_LIMITER_ENABLED", true),
 		},
+		media: mediaConfig{
+			dir:            env.GetString("MEDIA_DIR", "./uploads"),
+			baseURL:        env.GetString("MEDIA_BASE_URL", "http://localhost:8080/v1/media/files"),
+			maxUploadBytes: int64(env.GetInt("MEDIA_MAX_UPLOAD_BYTES", 10<<20)), // 10 MB
+			unattachedExp:  time.Hour * 24,
+		},
+		suggestions: suggestionsConfig{
+			refreshEvery: time.Hour,
+			activeWithin: time.Hour * 24 * 7, // 7 days
+			maxRefresh:   env.GetInt("SUGGESTIONS_MAX_REFRESH", 10000),
+			workers:      env.GetInt("SUGGESTIONS_WORKERS", 4),
+			cacheExp:     time.Hour * 2,
+		},
 	}
 
 	//logger
@@ -113,12 +170,57 @@ func main() {
 		cfg.rateLimiter.TimeFrame,
 	)
 
+	var emailLockout, ipLockout, mfaLockout lockout.Tracker
+	if cfg.redisCfg.enabled {
+		emailLockout = lockout.NewRedisTracker(rdb, "login-email", cfg.lockout.email)
+		ipLockout = lockout.NewRedisTracker(rdb, "login-ip", cfg.lockout.ip)
+		mfaLockout = lockout.NewRedisTracker(rdb, "login-mfa", cfg.lockout.mfa)
+	} else {
+		emailLockout = lockout.NewMemoryTracker(cfg.lockout.email)
+		ipLockout = lockout.NewMemoryTracker(cfg.lockout.ip)
+		mfaLockout = lockout.NewMemoryTracker(cfg.lockout.mfa)
+	}
+
+	blobStore, err := media.NewLocalStore(cfg.media.dir, cfg.media.baseURL)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
 	store := store.NewStorage(db)
 	cacheStorage := cache.NewRedisStorage(rdb)
 
 	mailer := mailer.NewSendgrid(cfg.mail.sendGrid.apiKey, cfg.mail.fromEmail)
 
 	JWTAuthenticator := auth.NewJWTAuthenticator(cfg.auth.token.secret, cfg.auth.token.iss, cfg.auth.token.iss)
+	if cfg.auth.token.keysDir != "" {
+		keySet, err := auth.LoadKeySet(cfg.auth.token.keysDir, cfg.auth.token.signingKID)
+		if err != nil {
+			logger.Fatal(err)
+		}
+
+		// pick up rotated keys without a restart
+		go func() {
+			for range time.Tick(time.Minute * 5) {
+				if err := keySet.Reload(); err != nil {
+					logger.Errorw("error reloading signing keys", "error", err)
+				}
+			}
+		}()
+
+		JWTAuthenticator = auth.NewJWTKeySetAuthenticator(keySet, cfg.auth.token.iss, cfg.auth.token.iss)
+		logger.Infow("signing tokens with key set", "dir", cfg.auth.token.keysDir, "kid", keySet.SigningKey().ID)
+	} else if cfg.auth.token.secret == "secret" && cfg.env != "development" {
+		logger.Warn("signing tokens with the default AUTH_TOKEN_SECRET, set AUTH_TOKEN_KEYS_DIR or a secret")
+	}
+
+	webAuthn, err := webauthn.New(&webauthn.Config{
+		RPID:          cfg.webauthn.rpID,
+		RPDisplayName: cfg.webauthn.rpDisplayName,
+		RPOrigins:     cfg.webauthn.rpOrigins,
+	})
+	if err != nil {
+		logger.Fatal(err)
+	}
 
 	// using application struct creating  new app
 	app := &application{
@@ -129,6 +231,65 @@ func main() {
 		mailer:        mailer,
 		authenticator: JWTAuthenticator,
 		rateLimiter:   ratelimiter,
+		webauthn:      webAuthn,
+		emailLockout:  emailLockout,
+		ipLockout:     ipLockout,
+		mfaLockout:    mfaLockout,
+		blobStore:     blobStore,
+	}
+
+	// free the emails and usernames held by invitations nobody accepted
+	go func() {
+		for range time.Tick(time.Hour) {
+			invitations, users, err := app.store.Users.PurgeInactive(context.Background(), cfg.mail.purgeExp)
+			if err != nil {
+				logger.Errorw("error purging inactive users", "error", err)
+				continue
+			}
+
+			if invitations > 0 || users > 0 {
+				logger.Infow("purged expired invitations", "invitations", invitations, "users", users)
+			}
+		}
+	}()
+
+	// delete the uploads that never made it into a post
+	go func() {
+		for range time.Tick(time.Hour) {
+			app.purgeUnattachedMedia(context.Background())
+		}
+	}()
+
+	// drop the passkey ceremonies that were begun but never finished
+	go func() {
+		for range time.Tick(time.Hour) {
+			sessions, err := app.store.Passkeys.PurgeSessions(context.Background())
+			if err != nil {
+				logger.Errorw("error purging passkey sessions", "error", err)
+				continue
+			}
+
+			if sessions > 0 {
+				logger.Infow("purged expired passkey sessions", "sessions", sessions)
+			}
+		}
+	}()
+
+	// suspensions lapse on their own, this only tidies up behind them
+	go func() {
+		for range time.Tick(time.Minute) {
+			app.reinstateExpired(context.Background())
+		}
+	}()
+
+	// rank who-to-follow suggestions ahead of time, without Redis they are
+	// ranked on every request instead
+	if cfg.redisCfg.enabled {
+		go func() {
+			for range time.Tick(cfg.suggestions.refreshEvery) {
+				app.refreshSuggestions(context.Background())
+			}
+		}()
 	}
 
 	// Metrics collected
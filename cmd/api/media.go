@@ -0,0 +1,290 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"social/internal/media"
+	"social/internal/store"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// UploadMediaHandler godoc
+//
+//	@Summary		Upload an image
+//	@Description	Upload a JPEG, PNG or GIF image as the "file" field of a multipart form. Its metadata is stripped and thumbnails are made, attach it to a post by ID within a day or it is deleted
+//	@Tags			media
+//	@Accept			multipart/form-data
+//	@Produce		json
+//	@Param			file	formData	file	true	"Image"
+//
+//	@Success		201		{object}	store.Media
+//	@Failure		400		{object}	error
+//	@Failure		413		{object}	error	"File too large"
+//	@Failure		415		{object}	error	"Not a supported image"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/media [post]
+func (app *application) uploadMediaHandler(w http.ResponseWriter, r *http.Request) {
+	maxBytes := app.config.media.maxUploadBytes
+
+	// leaves room for the multipart framing, ProcessImage holds the file itself
+	// to maxBytes
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes+1<<20)
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	var img *media.Image
+	for img == nil {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			app.badRequestResponse(w, r, errors.New("the file field is required"))
+			return
+		}
+		if err != nil {
+			app.uploadError(w, r, err)
+			return
+		}
+
+		if part.FormName() != "file" {
+			continue
+		}
+
+		img, err = media.ProcessImage(part, maxBytes)
+		if err != nil {
+			app.uploadError(w, r, err)
+			return
+		}
+	}
+
+	ctx := r.Context()
+
+	m := &store.Media{
+		ID:          uuid.New().String(),
+		UserID:      getUserFromCtx(r).ID,
+		ContentType: img.ContentType,
+		Size:        int64(len(img.Files[0].Data)),
+		Width:       img.Width,
+		Height:      img.Height,
+		Files:       map[string]string{},
+	}
+
+	for _, f := range img.Files {
+		key := m.ID + "/" + f.Name + f.Ext
+		if err := app.blobStore.Put(ctx, key, f.ContentType, bytes.NewReader(f.Data)); err != nil {
+			app.deleteBlobs(ctx, m)
+			app.internalServerError(w, r, err)
+			return
+		}
+		m.Files[f.Name] = key
+	}
+
+	if err := app.store.Media.Create(ctx, m); err != nil {
+		app.deleteBlobs(ctx, m)
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.setMediaURLs(m)
+
+	if err := app.jsonResponse(w, http.StatusCreated, m); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+func (app *application) uploadError(w http.ResponseWriter, r *http.Request, err error) {
+	var maxBytesErr *http.MaxBytesError
+
+	switch {
+	case errors.Is(err, media.ErrTooLarge), errors.As(err, &maxBytesErr):
+		app.payloadTooLargeResponse(w, r, media.ErrTooLarge)
+	case errors.Is(err, media.ErrUnsupportedType):
+		app.unsupportedMediaTypeResponse(w, r, err)
+	default:
+		app.badRequestResponse(w, r, err)
+	}
+}
+
+// GetMediaHandler godoc
+//
+//	@Summary		Fetch an upload
+//	@Description	Fetch an image the current user uploaded, with the URLs of its variants
+//	@Tags			media
+//	@Produce		json
+//	@Param			mediaID	path		string	true	"Media ID"
+//
+//	@Success		200		{object}	store.Media
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/media/{mediaID} [get]
+func (app *application) getMediaHandler(w http.ResponseWriter, r *http.Request) {
+	m, ok := app.getOwnMedia(w, r)
+	if !ok {
+		return
+	}
+
+	app.setMediaURLs(m)
+
+	if err := app.jsonResponse(w, http.StatusOK, m); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// DeleteMediaHandler godoc
+//
+//	@Summary		Delete an upload
+//	@Description	Delete an image the current user uploaded, it disappears from the posts it is attached to
+//	@Tags			media
+//	@Produce		json
+//	@Param			mediaID	path		string	true	"Media ID"
+//
+//	@Success		204		{string}	string	"Media deleted"
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/media/{mediaID} [delete]
+func (app *application) deleteMediaHandler(w http.ResponseWriter, r *http.Request) {
+	m, ok := app.getOwnMedia(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+
+	if err := app.store.Media.Delete(ctx, m.ID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.deleteBlobs(ctx, m)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ServeMediaFileHandler godoc
+//
+//	@Summary		Download an image
+//	@Description	Serve the bytes of an uploaded image or of one of its variants, the URLs of a media point here. It takes no token and does not check who may see the post an image is on, its URL is all it takes
+//	@Tags			media
+//	@Produce		image/jpeg,image/png,image/gif
+//	@Param			key	path		string	true	"Blob key"
+//
+//	@Success		200	{file}		file
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Router			/media/files/{key} [get]
+func (app *application) serveMediaFileHandler(w http.ResponseWriter, r *http.Request) {
+	key := chi.URLParam(r, "*")
+
+	f, err := app.blobStore.Get(r.Context(), key)
+	if err != nil {
+		switch err {
+		case media.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	defer f.Close()
+
+	// uploads are sniffed and re-encoded, the extension is the type
+	w.Header().Set("Content-Type", mime.TypeByExtension(path.Ext(key)))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	// keys are never reused
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+
+	if _, err := io.Copy(w, f); err != nil {
+		app.logger.Errorw("error serving media file", "key", key, "error", err)
+	}
+}
+
+// getOwnMedia reads the mediaID URL param, media uploaded by someone else is
+// not found.
+func (app *application) getOwnMedia(w http.ResponseWriter, r *http.Request) (*store.Media, bool) {
+	mediaID := chi.URLParam(r, "mediaID")
+	if err := Validate.Var(mediaID, "uuid"); err != nil {
+		app.badRequestResponse(w, r, err)
+		return nil, false
+	}
+
+	m, err := app.store.Media.GetByID(r.Context(), mediaID)
+	if err == nil && m.UserID != getUserFromCtx(r).ID {
+		err = store.ErrNotFound
+	}
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return nil, false
+	}
+
+	return m, true
+}
+
+func (app *application) setMediaURLs(uploads ...*store.Media) {
+	for _, m := range uploads {
+		m.URLs = make(map[string]string, len(m.Files))
+		for name, key := range m.Files {
+			m.URLs[name] = app.blobStore.URL(key)
+		}
+	}
+}
+
+// setPostMediaURLs fills in the URLs of the media of posts.
+func (app *application) setPostMediaURLs(posts ...*store.Post) {
+	for _, p := range posts {
+		for i := range p.Media {
+			app.setMediaURLs(&p.Media[i])
+		}
+	}
+}
+
+// purgeUnattachedMedia deletes the uploads older than the unattached
+// expiry that no post uses, and their blobs.
+func (app *application) purgeUnattachedMedia(ctx context.Context) {
+	before := time.Now().Add(-app.config.media.unattachedExp)
+
+	uploads, err := app.store.Media.PurgeUnattached(ctx, before)
+	if err != nil {
+		app.logger.Errorw("error purging unattached media", "error", err)
+		return
+	}
+
+	for i := range uploads {
+		app.deleteBlobs(ctx, &uploads[i])
+	}
+
+	if len(uploads) > 0 {
+		app.logger.Infow("purged unattached media", "media", len(uploads))
+	}
+}
+
+// deleteBlobs is best effort, a blob left behind only takes space.
+func (app *application) deleteBlobs(ctx context.Context, m *store.Media) {
+	for _, key := range m.Files {
+		if err := app.blobStore.Delete(ctx, key); err != nil {
+			app.logger.Errorw("error deleting media blob", "key", key, "error", err)
+		}
+	}
+}
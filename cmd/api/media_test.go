@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestMedia(t *testing.T) {
+	app := newTestApplication(t, config{
+		media: mediaConfig{maxUploadBytes: 1 << 20},
+	})
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	upload := func(t *testing.T, field string, data []byte) *http.Request {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+
+		fw, err := mw.CreateFormFile(field, "upload")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fw.Write(data); err != nil {
+			t.Fatal(err)
+		}
+		if err := mw.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		req, err := http.NewRequest(http.MethodPost, "/v1/media", &body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+
+		return req
+	}
+
+	var img bytes.Buffer
+	if err := png.Encode(&img, image.NewRGBA(image.Rect(0, 0, 600, 300))); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should upload an image and serve its variants", func(t *testing.T) {
+		rr := executeRequest(upload(t, "file", img.Bytes()), mux)
+		checkResponseCode(t, http.StatusCreated, rr.Code)
+
+		var res struct {
+			Data struct {
+				Width int               `json:"width"`
+				URLs  map[string]string `json:"urls"`
+			} `json:"data"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+
+		if res.Data.Width != 600 {
+			t.Errorf("expected a width of 600, got %d", res.Data.Width)
+		}
+
+		for _, name := range []string{"original", "large", "small", "thumb"} {
+			url, ok := res.Data.URLs[name]
+			if !ok {
+				t.Fatalf("expected a %s URL, got %v", name, res.Data.URLs)
+			}
+
+			req, err := http.NewRequest(http.MethodGet, strings.TrimPrefix(url, "http://localhost:8080"), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rr := executeRequest(req, mux)
+			checkResponseCode(t, http.StatusOK, rr.Code)
+
+			if ct := rr.Header().Get("Content-Type"); ct != "image/png" {
+				t.Errorf("expected image/png for %s, got %q", name, ct)
+			}
+		}
+	})
+
+	t.Run("should reject files that are not images", func(t *testing.T) {
+		rr := executeRequest(upload(t, "file", []byte("<html><script>alert(1)</script></html>")), mux)
+		checkResponseCode(t, http.StatusUnsupportedMediaType, rr.Code)
+	})
+
+	t.Run("should reject files over the limit", func(t *testing.T) {
+		rr := executeRequest(upload(t, "file", make([]byte, 1<<20+1)), mux)
+		checkResponseCode(t, http.StatusRequestEntityTooLarge, rr.Code)
+	})
+
+	t.Run("should require the file field", func(t *testing.T) {
+		rr := executeRequest(upload(t, "avatar", img.Bytes()), mux)
+		checkResponseCode(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("should not serve keys outside the store", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/media/files/..%2F..%2Fetc%2Fpasswd", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := executeRequest(req, mux)
+		if rr.Code == http.StatusOK {
+			t.Errorf("expected the key to be refused, got %d", rr.Code)
+		}
+	})
+
+	t.Run("should fetch an own upload", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/media/9b2f6d0e-3c1a-4f7b-8e2d-5a6c7b8d9e0f", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+testToken)
+
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusOK, rr.Code)
+	})
+
+	t.Run("should reject unknown media on a post", func(t *testing.T) {
+		body := `{"title": "t", "content": "c", "media": [{"id": "not-a-uuid"}]}`
+		req, err := http.NewRequest(http.MethodPost, "/v1/posts", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+testToken)
+
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusBadRequest, rr.Code)
+	})
+}
@@ -0,0 +1,419 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"social/internal/auth"
+	"social/internal/store"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	mfaIssuer          = "GopherSocial"
+	recoveryCodesCount = 10
+)
+
+var errInvalidSecondFactor = errors.New("invalid authentication code")
+
+type MFAChallenge struct {
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// newMFAChallenge signs a short lived token that AuthenthicationMiddleware
+// refuses and only verifyMFAHandler accepts.
+func (app *application) newMFAChallenge(userID int64) (*MFAChallenge, error) {
+	claims := jwt.MapClaims{
+		"sub":         userID,
+		"exp":         time.Now().Add(app.config.auth.token.mfaExp).Unix(),
+		"iat":         time.Now().Unix(),
+		"nbf":         time.Now().Unix(),
+		"iss":         app.config.auth.token.iss,
+		"aud":         app.config.auth.token.iss,
+		"mfa_pending": true,
+	}
+
+	token, err := app.authenticator.GenerateToken(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MFAChallenge{
+		MFARequired: true,
+		MFAToken:    token,
+		ExpiresIn:   int64(app.config.auth.token.mfaExp.Seconds()),
+	}, nil
+}
+
+type VerifyMFAPayload struct {
+	MFAToken     string `json:"mfa_token" validate:"required"`
+	Code         string `json:"code" validate:"required_without=RecoveryCode,omitempty,len=6,numeric"`
+	RecoveryCode string `json:"recovery_code" validate:"omitempty,max=20"`
+}
+
+// VerifyMFAHandler godoc
+//
+//	@Summary		Complete a two-factor login
+//	@Description	Exchange the challenge token from /authentication/token and a TOTP or recovery code for tokens
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		VerifyMFAPayload	true	"Challenge token and code"
+//
+//	@success		201		{object}	TokenPair			"Token created"
+//	@failure		400		{object}	error
+//	@failure		401		{object}	error
+//	@failure		403		{object}	error	"Account suspended or banned"
+//	@failure		429		{object}	error	"Too many wrong codes"
+//	@failure		500		{object}	error
+//	@Router			/authentication/mfa/verify [post]
+func (app *application) verifyMFAHandler(w http.ResponseWriter, r *http.Request) {
+	var payload VerifyMFAPayload
+	if err := readJson(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	jwtToken, err := app.authenticator.ValidateToken(payload.MFAToken)
+	if err != nil {
+		app.unauthorizedErrorResponse(w, r, err)
+		return
+	}
+
+	claims, _ := jwtToken.Claims.(jwt.MapClaims)
+	if pending, _ := claims["mfa_pending"].(bool); !pending {
+		app.unauthorizedErrorResponse(w, r, errors.New("not a two-factor challenge token"))
+		return
+	}
+
+	userID, err := userIDFromClaims(claims)
+	if err != nil {
+		app.unauthorizedErrorResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	mfa, err := app.store.MFA.GetByUserID(ctx, userID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.unauthorizedErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if !app.verifySecondFactor(w, r, mfa, payload.Code, payload.RecoveryCode) {
+		return
+	}
+
+	user, err := app.store.Users.GetByID(ctx, userID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.unauthorizedErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	// it may have been restricted since the challenge was issued
+	if !user.InGoodStanding(time.Now()) {
+		app.accountRestrictedResponse(w, r, user.AccountStatus)
+		return
+	}
+
+	if err := app.emailLockout.Reset(ctx, lockoutEmailKey(user.Email)); err != nil {
+		app.logger.Errorw("error resetting failed logins", "error", err)
+	}
+
+	tokens, err := app.issueTokens(r, userID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, tokens); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+type MFAEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+// EnrollMFAHandler godoc
+//
+//	@Summary		Start two-factor enrollment
+//	@Description	Generates a TOTP secret to add to an authenticator app, 2FA is enabled once confirmed
+//	@Tags			authentication
+//	@Produce		json
+//
+//	@success		201	{object}	MFAEnrollment
+//	@failure		401	{object}	error
+//	@failure		409	{object}	error	"2FA is already enabled"
+//	@failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/authentication/mfa/enroll [post]
+func (app *application) enrollMFAHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.store.MFA.Enroll(r.Context(), user.ID, secret); err != nil {
+		switch err {
+		case store.ErrorConflict:
+			app.conflictResponse(w, r, errors.New("two-factor authentication is already enabled"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	enrollment := &MFAEnrollment{
+		Secret:          secret,
+		ProvisioningURI: auth.TOTPProvisioningURI(secret, mfaIssuer, user.Email),
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, enrollment); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+type ConfirmMFAPayload struct {
+	Code string `json:"code" validate:"required,len=6,numeric"`
+}
+
+type RecoveryCodes struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// ConfirmMFAHandler godoc
+//
+//	@Summary		Confirm two-factor enrollment
+//	@Description	Enables 2FA with a first code from the authenticator and returns one time recovery codes
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		ConfirmMFAPayload	true	"Authenticator code"
+//
+//	@success		200		{object}	RecoveryCodes
+//	@failure		400		{object}	error
+//	@failure		401		{object}	error
+//	@failure		409		{object}	error
+//	@failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/authentication/mfa/confirm [post]
+func (app *application) confirmMFAHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ConfirmMFAPayload
+	if err := readJson(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromCtx(r)
+	ctx := r.Context()
+
+	mfa, err := app.store.MFA.GetByUserID(ctx, user.ID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.badRequestResponse(w, r, errors.New("no pending two-factor enrollment"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if mfa.Enabled() {
+		app.conflictResponse(w, r, errors.New("two-factor authentication is already enabled"))
+		return
+	}
+
+	step, ok := auth.ValidateTOTP(mfa.TOTPSecret, payload.Code, time.Now())
+	if !ok {
+		app.badRequestResponse(w, r, errInvalidSecondFactor)
+		return
+	}
+
+	codes := make([]string, recoveryCodesCount)
+	hashes := make([]string, recoveryCodesCount)
+	for i := range codes {
+		code, err := auth.GenerateRecoveryCode()
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		codes[i] = code
+		hashes[i] = hashToken(code)
+	}
+
+	if err := app.store.MFA.Confirm(ctx, user.ID, step, hashes); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.conflictResponse(w, r, errors.New("two-factor authentication is already enabled"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, &RecoveryCodes{RecoveryCodes: codes}); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+type DisableMFAPayload struct {
+	Password     string `json:"password" validate:"required,min=3,max=72"`
+	Code         string `json:"code" validate:"required_without=RecoveryCode,omitempty,len=6,numeric"`
+	RecoveryCode string `json:"recovery_code" validate:"omitempty,max=20"`
+}
+
+// DisableMFAHandler godoc
+//
+//	@Summary		Disable two-factor authentication
+//	@Description	Requires the password and a current TOTP or recovery code
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		DisableMFAPayload	true	"Password and code"
+//
+//	@success		204		{string}	string				"2FA disabled"
+//	@failure		400		{object}	error
+//	@failure		401		{object}	error
+//	@failure		429		{object}	error	"Too many wrong codes"
+//	@failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/authentication/mfa/disable [post]
+func (app *application) disableMFAHandler(w http.ResponseWriter, r *http.Request) {
+	var payload DisableMFAPayload
+	if err := readJson(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	user, ok := app.checkPassword(w, r, payload.Password)
+	if !ok {
+		return
+	}
+
+	mfa, err := app.store.MFA.GetByUserID(ctx, user.ID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.badRequestResponse(w, r, errors.New("two-factor authentication is not enabled"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if !app.verifySecondFactor(w, r, mfa, payload.Code, payload.RecoveryCode) {
+		return
+	}
+
+	if err := app.store.MFA.Disable(ctx, user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// verifySecondFactor checks a code with checkSecondFactor and counts the wrong
+// ones against the user, not the challenge, so a new challenge doesn't buy
+// more guesses. Once locked out every code is refused until the lockout ends.
+func (app *application) verifySecondFactor(w http.ResponseWriter, r *http.Request, mfa *store.MFA, code, recoveryCode string) bool {
+	ctx := r.Context()
+	key := lockoutMFAKey(mfa.UserID)
+
+	lockedFor, err := app.mfaLockout.Locked(ctx, key)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return false
+	}
+
+	if lockedFor > 0 {
+		app.loginLockedResponse(w, r, lockedFor)
+		return false
+	}
+
+	if err := app.checkSecondFactor(ctx, mfa, code, recoveryCode); err != nil {
+		switch err {
+		case errInvalidSecondFactor:
+			if _, lockedFor, err := app.mfaLockout.Fail(ctx, key); err != nil {
+				app.logger.Errorw("error recording failed second factor", "user_id", mfa.UserID, "error", err)
+			} else if lockedFor > 0 {
+				app.logger.Warnw("second factor locked out after wrong codes", "user_id", mfa.UserID, "locked_for", lockedFor)
+			}
+
+			app.unauthorizedErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return false
+	}
+
+	if err := app.mfaLockout.Reset(ctx, key); err != nil {
+		app.logger.Errorw("error resetting failed second factors", "user_id", mfa.UserID, "error", err)
+	}
+
+	return true
+}
+
+// checkSecondFactor accepts either a TOTP code, that can't be replayed, or an unused recovery code.
+func (app *application) checkSecondFactor(ctx context.Context, mfa *store.MFA, code, recoveryCode string) error {
+	if !mfa.Enabled() {
+		return errInvalidSecondFactor
+	}
+
+	var err error
+	if code != "" {
+		step, ok := auth.ValidateTOTP(mfa.TOTPSecret, code, time.Now())
+		if !ok {
+			return errInvalidSecondFactor
+		}
+
+		err = app.store.MFA.UseStep(ctx, mfa.UserID, step)
+	} else {
+		normalized := strings.ToLower(strings.TrimSpace(recoveryCode))
+		err = app.store.MFA.UseRecoveryCode(ctx, mfa.UserID, hashToken(normalized))
+	}
+
+	if err == store.ErrNotFound {
+		return errInvalidSecondFactor
+	}
+
+	return err
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"social/internal/auth"
+	"social/internal/lockout"
+	"social/internal/store"
+)
+
+func TestMFALockout(t *testing.T) {
+	app := newTestApplication(t, config{
+		auth: authConfig{token: tokenConfig{mfaExp: time.Minute * 5}},
+		lockout: lockoutConfig{
+			mfa: lockout.Config{MaxAttempts: 3, BaseDelay: time.Minute, MaxDelay: time.Hour, Window: time.Hour},
+		},
+	})
+	mux := app.mount()
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app.store.MFA.(*store.MockMFAStore).Secrets = map[int64]string{1: secret}
+
+	verify := func(t *testing.T, code string) int {
+		challenge, err := app.newMFAChallenge(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		body := `{"mfa_token":"` + challenge.MFAToken + `","code":"` + code + `"}`
+		req, err := http.NewRequest(http.MethodPost, "/v1/authentication/mfa/verify", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return executeRequest(req, mux).Code
+	}
+
+	t.Run("should lock the second factor out after too many wrong codes", func(t *testing.T) {
+		// a new challenge every time, the failures are counted per user
+		for range 3 {
+			checkResponseCode(t, http.StatusUnauthorized, verify(t, "000000"))
+		}
+
+		checkResponseCode(t, http.StatusTooManyRequests, verify(t, "000000"))
+	})
+
+}
+
+func TestVerifyMFA(t *testing.T) {
+	app := newTestApplication(t, config{
+		auth: authConfig{token: tokenConfig{mfaExp: time.Minute * 5}},
+	})
+	mux := app.mount()
+
+	app.store.MFA.(*store.MockMFAStore).Secrets = map[int64]string{1: "secret"}
+	users := app.store.Users.(*store.MockUserStore)
+
+	// the store mock takes any recovery code
+	verify := func(t *testing.T) int {
+		challenge, err := app.newMFAChallenge(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		body := `{"mfa_token":"` + challenge.MFAToken + `","recovery_code":"abcd-efgh"}`
+		req, err := http.NewRequest(http.MethodPost, "/v1/authentication/mfa/verify", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return executeRequest(req, mux).Code
+	}
+
+	t.Run("should issue tokens for a valid code", func(t *testing.T) {
+		checkResponseCode(t, http.StatusCreated, verify(t))
+	})
+
+	t.Run("should refuse accounts restricted since the challenge", func(t *testing.T) {
+		users.Statuses = map[int64]store.AccountStatus{
+			1: {State: store.AccountBanned, StateReason: "spam"},
+		}
+		defer func() { users.Statuses = nil }()
+
+		checkResponseCode(t, http.StatusForbidden, verify(t))
+	})
+}
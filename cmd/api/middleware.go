@@ -8,7 +8,9 @@ import (
 	"social/internal/store"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/golang-jwt/jwt/v5"
 )
 
@@ -27,6 +29,38 @@ func (app *application) AuthenthicationMiddleware(next http.Handler) http.Handle
 		}
 
 		token := parts[1]
+		ctx := r.Context()
+
+		// personal access tokens are opaque, anything else is a JWT
+		if strings.HasPrefix(token, personalAccessTokenPrefix) {
+			pat, err := app.store.PersonalAccessTokens.GetByToken(ctx, hashToken(token))
+			if err != nil {
+				app.unauthorizedErrorResponse(w, r, err)
+				return
+			}
+
+			user, err := app.getUser(ctx, pat.UserID)
+			if err != nil {
+				app.unauthorizedErrorResponse(w, r, err)
+				return
+			}
+
+			if !user.InGoodStanding(time.Now()) {
+				app.accountRestrictedResponse(w, r, user.AccountStatus)
+				return
+			}
+
+			if err := app.store.PersonalAccessTokens.Touch(ctx, pat.ID); err != nil {
+				app.logger.Warnw("error updating access token last use", "id", pat.ID, "error", err)
+			}
+
+			ctx = context.WithValue(ctx, userCtx, user)
+			ctx = context.WithValue(ctx, accessTokenCtx, pat)
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+
 		jwtToken, err := app.authenticator.ValidateToken(token)
 		if err != nil {
 			app.unauthorizedErrorResponse(w, r, err)
@@ -35,13 +69,65 @@ func (app *application) AuthenthicationMiddleware(next http.Handler) http.Handle
 
 		claims, _ := jwtToken.Claims.(jwt.MapClaims)
 
-		userID, err := strconv.ParseInt(fmt.Sprintf("%.f", claims["sub"]), 10, 64)
+		// a challenge token only proves the password, not the second factor
+		if pending, _ := claims["mfa_pending"].(bool); pending {
+			app.unauthorizedErrorResponse(w, r, fmt.Errorf("second factor required"))
+			return
+		}
+
+		userID, err := userIDFromClaims(claims)
 		if err != nil {
 			app.unauthorizedErrorResponse(w, r, err)
 			return
 		}
 
-		ctx := r.Context()
+		sessionID, _ := claims["jti"].(string)
+		if sessionID == "" {
+			app.unauthorizedErrorResponse(w, r, fmt.Errorf("token is not bound to a session"))
+			return
+		}
+
+		// an impersonation token has no session, its jti is the impersonation
+		if _, ok := claims["act"]; ok {
+			imp, err := app.checkImpersonation(ctx, sessionID, userID, claims)
+			if err != nil {
+				switch err {
+				case errImpersonationEnded:
+					app.unauthorizedErrorResponse(w, r, err)
+				default:
+					app.internalServerError(w, r, err)
+				}
+				return
+			}
+
+			user, err := app.getUser(ctx, userID)
+			if err != nil {
+				app.unauthorizedErrorResponse(w, r, err)
+				return
+			}
+
+			if !user.InGoodStanding(time.Now()) {
+				app.accountRestrictedResponse(w, r, user.AccountStatus)
+				return
+			}
+
+			ctx = context.WithValue(ctx, userCtx, user)
+			ctx = context.WithValue(ctx, impersonationCtx, imp)
+
+			app.serveImpersonated(w, r.WithContext(ctx), next, imp)
+			return
+		}
+
+		session, err := app.checkSession(ctx, sessionID, userID)
+		if err != nil {
+			switch err {
+			case errSessionRevoked:
+				app.unauthorizedErrorResponse(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
 
 		user, err := app.getUser(ctx, userID)
 		if err != nil {
@@ -49,12 +135,48 @@ func (app *application) AuthenthicationMiddleware(next http.Handler) http.Handle
 			return
 		}
 
+		// checked on every request, a suspension can start mid-session
+		if !user.InGoodStanding(time.Now()) {
+			app.accountRestrictedResponse(w, r, user.AccountStatus)
+			return
+		}
+
+		if err := app.store.Sessions.Touch(ctx, sessionID); err != nil {
+			app.logger.Warnw("error updating session last seen", "id", sessionID, "error", err)
+		}
+
 		ctx = context.WithValue(ctx, userCtx, user)
+		ctx = context.WithValue(ctx, sessionCtx, session)
 
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// requireScope limits a route to personal access tokens granted the scope.
+// Requests authenticated with a JWT carry every scope, except impersonation
+// tokens which never carry scopeAccount.
+func (app *application) requireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if pat := getAccessTokenFromCtx(r); pat != nil && !pat.HasScope(scope) {
+				app.forbiddenResponse(w, r)
+				return
+			}
+
+			if scope == scopeAccount && getImpersonationFromCtx(r) != nil {
+				app.forbiddenResponse(w, r)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func userIDFromClaims(claims jwt.MapClaims) (int64, error) {
+	return strconv.ParseInt(fmt.Sprintf("%.f", claims["sub"]), 10, 64)
+}
+
 func (app *application) BasicAuthMiddleware() func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -92,7 +214,9 @@ func (app *application) BasicAuthMiddleware() func(http.Handler) http.Handler {
 	}
 }
 
-func (app *application) checkPostOwnership(requiredRole string, next http.HandlerFunc) http.HandlerFunc {
+// checkPostOwnership lets the author of the post through, anyone else needs
+// the permission and the override is recorded in the audit log under it.
+func (app *application) checkPostOwnership(permission string, next http.HandlerFunc) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		user := getUserFromCtx(r)
 		post := getPostFromCtx(r)
@@ -103,8 +227,7 @@ func (app *application) checkPostOwnership(requiredRole string, next http.Handle
 			return
 		}
 
-		// role precedence check
-		allowed, err := app.checkRolePrecedence(r.Context(), user, requiredRole)
+		allowed, err := app.hasPermission(r.Context(), user, permission)
 		if err != nil {
 			app.internalServerError(w, r, err)
 			return
@@ -115,18 +238,23 @@ func (app *application) checkPostOwnership(requiredRole string, next http.Handle
 			return
 		}
 
-		next.ServeHTTP(w, r)
-	})
-}
+		// the handler edits the post in place
+		before := *post
 
-func (app *application) checkRolePrecedence(ctx context.Context, user *store.User, roleName string) (bool, error) {
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
 
-	role, err := app.store.Roles.GetByName(ctx, roleName)
-	if err != nil {
-		return false, err
-	}
+		if ww.Status() >= http.StatusBadRequest {
+			return
+		}
 
-	return user.Role.Level >= role.Level, nil
+		switch r.Method {
+		case http.MethodDelete:
+			app.audit(r, permission, "post", post.ID, before, nil)
+		default:
+			app.audit(r, permission, "post", post.ID, before, post)
+		}
+	})
 }
 
 func (app *application) getUser(ctx context.Context, userID int64) (*store.User, error) {
@@ -156,6 +284,22 @@ func (app *application) getUser(ctx context.Context, userID int64) (*store.User,
 	return user, nil
 }
 
+// forgetUser drops the cached copies of a user so the next request reads
+// its role and permissions from the database.
+func (app *application) forgetUser(ctx context.Context, userID int64) {
+	if !app.config.redisCfg.enabled {
+		return
+	}
+
+	if err := app.cacheStorage.Users.Delete(ctx, userID); err != nil {
+		app.logger.Errorw("error clearing cached user", "user_id", userID, "error", err)
+	}
+
+	if err := app.cacheStorage.Permissions.Delete(ctx, userID); err != nil {
+		app.logger.Errorw("error clearing cached permissions", "user_id", userID, "error", err)
+	}
+}
+
 func (app *application) RateLimiterMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if app.config.rateLimiter.Enabled {
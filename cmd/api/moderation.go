@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"social/internal/store"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type AccountStatusPayload struct {
+	State  string `json:"state" validate:"required,oneof=active suspended banned"`
+	Reason string `json:"reason" validate:"required_unless=State active,max=500"`
+	// when a suspension ends, only for suspensions
+	Until *time.Time `json:"until" validate:"required_if=State suspended,excluded_unless=State suspended"`
+}
+
+// GetAccountStatusHandler godoc
+//
+//	@Summary		Fetch the state of an account
+//	@Description	Fetch whether a user is active, suspended or banned, and why
+//	@Tags			admin
+//	@Produce		json
+//	@Param			userID	path		int	true	"User ID"
+//
+//	@Success		200		{object}	store.AccountStatus
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/users/{userID}/status [get]
+func (app *application) getAccountStatusHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user, err := app.store.Users.GetByID(r.Context(), userID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	status := user.AccountStatus
+	// a suspension that ran out is over even before the row is reinstated
+	if status.InGoodStanding(time.Now()) {
+		status = store.AccountStatus{State: store.AccountActive}
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, status); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// SetAccountStatusHandler godoc
+//
+//	@Summary		Suspend, ban or reinstate an account
+//	@Description	Change the state of an account. Suspended and banned users can't log in or use their tokens, and their posts, comments and profile are hidden. A suspension ends on its own at until. Users who can moderate can't be moderated
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			userID	path		int						true	"User ID"
+//	@Param			payload	body		AccountStatusPayload	true	"New state"
+//
+//	@Success		200		{object}	store.AccountStatus
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/users/{userID}/status [put]
+func (app *application) setAccountStatusHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	var payload AccountStatusPayload
+	if err := readJson(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if payload.Until != nil && !payload.Until.After(time.Now()) {
+		app.badRequestResponse(w, r, errors.New("a suspension must end in the future"))
+		return
+	}
+
+	if userID == getUserFromCtx(r).ID {
+		app.badRequestResponse(w, r, errors.New("you can't moderate yourself"))
+		return
+	}
+
+	ctx := r.Context()
+
+	target, err := app.store.Users.GetByID(ctx, userID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	// moderators settle their disputes with an admin, not with each other
+	privileged, err := app.hasPermission(ctx, target, permUsersModerate)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if privileged {
+		app.forbiddenResponse(w, r)
+		return
+	}
+
+	status := store.AccountStatus{
+		State:          payload.State,
+		StateReason:    payload.Reason,
+		SuspendedUntil: payload.Until,
+	}
+
+	previous, err := app.store.Users.SetStatus(ctx, userID, status)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.audit(r, permUsersModerate, "user", userID, previous, status)
+
+	app.forgetUser(ctx, userID)
+	app.forgetUserSearch(ctx, target.Username)
+
+	if err := app.jsonResponse(w, http.StatusOK, status); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// reinstateExpired clears the suspensions that have run out. They stop being
+// enforced on their own, this keeps the rows and the cache in line.
+func (app *application) reinstateExpired(ctx context.Context) {
+	ids, err := app.store.Users.ReinstateExpired(ctx)
+	if err != nil {
+		app.logger.Errorw("error reinstating suspended users", "error", err)
+		return
+	}
+
+	for _, id := range ids {
+		app.forgetUser(ctx, id)
+	}
+
+	if len(ids) > 0 {
+		app.logger.Infow("reinstated suspended users", "count", len(ids))
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"social/internal/store"
+)
+
+func TestModeration(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	users := app.store.Users.(*store.MockUserStore)
+	roles := app.store.Roles.(*store.MockRoleStore)
+
+	setStatus := func(t *testing.T, userID, payload string) int {
+		req, err := http.NewRequest(http.MethodPut, "/v1/admin/users/"+userID+"/status", strings.NewReader(payload))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+testToken)
+
+		return executeRequest(req, mux).Code
+	}
+
+	getMe := func(t *testing.T) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodGet, "/v1/users/me", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+testToken)
+
+		return executeRequest(req, mux)
+	}
+
+	until := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+
+	t.Run("should require the permission", func(t *testing.T) {
+		roles.Permissions = nil
+
+		checkResponseCode(t, http.StatusForbidden, setStatus(t, "2", `{"state":"banned","reason":"spam"}`))
+	})
+
+	roles.Permissions = store.Permissions{permUsersModerate}
+
+	t.Run("should suspend and ban users", func(t *testing.T) {
+		checkResponseCode(t, http.StatusOK, setStatus(t, "2", `{"state":"suspended","reason":"spam","until":"`+until+`"}`))
+		checkResponseCode(t, http.StatusOK, setStatus(t, "2", `{"state":"banned","reason":"spam"}`))
+		checkResponseCode(t, http.StatusOK, setStatus(t, "2", `{"state":"active"}`))
+	})
+
+	t.Run("should validate the new state", func(t *testing.T) {
+		past := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+
+		for _, payload := range []string{
+			`{"state":"suspended","reason":"spam"}`,
+			`{"state":"suspended","reason":"spam","until":"` + past + `"}`,
+			`{"state":"banned"}`,
+			`{"state":"banned","reason":"spam","until":"` + until + `"}`,
+			`{"state":"deleted","reason":"spam"}`,
+		} {
+			checkResponseCode(t, http.StatusBadRequest, setStatus(t, "2", payload))
+		}
+	})
+
+	t.Run("should not moderate yourself", func(t *testing.T) {
+		checkResponseCode(t, http.StatusBadRequest, setStatus(t, "1", `{"state":"banned","reason":"oops"}`))
+	})
+
+	t.Run("should hide the profile of restricted users", func(t *testing.T) {
+		users.Statuses = map[int64]store.AccountStatus{
+			2: {State: store.AccountBanned, StateReason: "spam"},
+		}
+		defer func() { users.Statuses = nil }()
+
+		req, err := http.NewRequest(http.MethodGet, "/v1/users/2", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+testToken)
+
+		checkResponseCode(t, http.StatusNotFound, executeRequest(req, mux).Code)
+	})
+
+	t.Run("should refuse suspended users and say until when", func(t *testing.T) {
+		suspendedUntil := time.Now().Add(time.Hour)
+		users.Statuses = map[int64]store.AccountStatus{
+			1: {State: store.AccountSuspended, StateReason: "spam", SuspendedUntil: &suspendedUntil},
+		}
+		defer func() { users.Statuses = nil }()
+
+		rr := getMe(t)
+		checkResponseCode(t, http.StatusForbidden, rr.Code)
+
+		if body := rr.Body.String(); !strings.Contains(body, "suspended until") || !strings.Contains(body, "spam") {
+			t.Errorf("expected the suspension and its reason, got %s", body)
+		}
+
+		if rr.Header().Get("Retry-After") == "" {
+			t.Error("expected a Retry-After header")
+		}
+	})
+
+	t.Run("should let users back in when their suspension runs out", func(t *testing.T) {
+		suspendedUntil := time.Now().Add(-time.Minute)
+		users.Statuses = map[int64]store.AccountStatus{
+			1: {State: store.AccountSuspended, StateReason: "spam", SuspendedUntil: &suspendedUntil},
+		}
+		defer func() { users.Statuses = nil }()
+
+		checkResponseCode(t, http.StatusOK, getMe(t).Code)
+	})
+
+	t.Run("should refuse banned users", func(t *testing.T) {
+		users.Statuses = map[int64]store.AccountStatus{
+			1: {State: store.AccountBanned, StateReason: "spam"},
+		}
+		defer func() { users.Statuses = nil }()
+
+		checkResponseCode(t, http.StatusForbidden, getMe(t).Code)
+	})
+}
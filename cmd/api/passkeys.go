@@ -0,0 +1,403 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"social/internal/store"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+var errPasskeyCeremony = errors.New("unknown or expired passkey ceremony")
+
+// webAuthnUser adapts a user and its passkeys to webauthn.User.
+type webAuthnUser struct {
+	*store.User
+	credentials []webauthn.Credential
+}
+
+// WebAuthnID is the user handle stored on the authenticator, it is how a
+// discoverable login finds the user back.
+func (u *webAuthnUser) WebAuthnID() []byte {
+	return []byte(strconv.FormatInt(u.ID, 10))
+}
+
+func (u *webAuthnUser) WebAuthnName() string {
+	return u.Email
+}
+
+func (u *webAuthnUser) WebAuthnDisplayName() string {
+	return u.Username
+}
+
+func (u *webAuthnUser) WebAuthnCredentials() []webauthn.Credential {
+	return u.credentials
+}
+
+// BeginPasskeyRegistrationHandler godoc
+//
+//	@Summary		Start a passkey registration
+//	@Description	Returns the options to pass to navigator.credentials.create()
+//	@Tags			authentication
+//	@Produce		json
+//
+//	@success		200	{object}	object	"navigator.credentials.create() options"
+//	@failure		401	{object}	error
+//	@failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/authentication/passkeys/register/begin [post]
+func (app *application) beginPasskeyRegistrationHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	user, err := app.getWebAuthnUser(ctx, getUserFromCtx(r))
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	exclusions := make([]protocol.CredentialDescriptor, len(user.credentials))
+	for i, c := range user.credentials {
+		exclusions[i] = c.Descriptor()
+	}
+
+	creation, session, err := app.webauthn.BeginRegistration(user,
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementRequired),
+		webauthn.WithExclusions(exclusions),
+	)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.savePasskeySession(ctx, session, user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, creation); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// FinishPasskeyRegistrationHandler godoc
+//
+//	@Summary		Finish a passkey registration
+//	@Description	Verifies the result of navigator.credentials.create() and stores the passkey
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			name	query		string	false	"Passkey name"
+//
+//	@success		201		{object}	store.Passkey
+//	@failure		400		{object}	error
+//	@failure		401		{object}	error
+//	@failure		409		{object}	error
+//	@failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/authentication/passkeys/register/finish [post]
+func (app *application) finishPasskeyRegistrationHandler(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+	if err := Validate.Var(name, "max=100"); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBody(http.MaxBytesReader(w, r.Body, 1_048_576))
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	user, err := app.getWebAuthnUser(ctx, getUserFromCtx(r))
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	session, sessionUserID, err := app.consumePasskeySession(ctx, parsed.Response.CollectedClientData.Challenge)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.badRequestResponse(w, r, errPasskeyCeremony)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if sessionUserID != user.ID {
+		app.badRequestResponse(w, r, errPasskeyCeremony)
+		return
+	}
+
+	credential, err := app.webauthn.CreateCredential(user, *session, parsed)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	data, err := json.Marshal(credential)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	passkey := &store.Passkey{
+		UserID:       user.ID,
+		CredentialID: credential.ID,
+		Name:         name,
+		SignCount:    credential.Authenticator.SignCount,
+		Credential:   data,
+	}
+
+	if err := app.store.Passkeys.Create(ctx, passkey); err != nil {
+		switch err {
+		case store.ErrorConflict:
+			app.conflictResponse(w, r, errors.New("passkey is already registered"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, passkey); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// BeginPasskeyLoginHandler godoc
+//
+//	@Summary		Start a passkey login
+//	@Description	Returns the options to pass to navigator.credentials.get()
+//	@Tags			authentication
+//	@Produce		json
+//
+//	@success		200	{object}	object	"navigator.credentials.get() options"
+//	@failure		500	{object}	error
+//	@Router			/authentication/passkeys/login/begin [post]
+func (app *application) beginPasskeyLoginHandler(w http.ResponseWriter, r *http.Request) {
+	// user verification makes the passkey a second factor on its own, the
+	// login doesn't go through the TOTP challenge
+	assertion, session, err := app.webauthn.BeginDiscoverableLogin(
+		webauthn.WithUserVerification(protocol.VerificationRequired),
+	)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.savePasskeySession(r.Context(), session, 0); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, assertion); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// FinishPasskeyLoginHandler godoc
+//
+//	@Summary		Finish a passkey login
+//	@Description	Verifies the result of navigator.credentials.get() and creates a token
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//
+//	@success		201	{object}	TokenPair	"Token created"
+//	@failure		400	{object}	error
+//	@failure		401	{object}	error
+//	@failure		500	{object}	error
+//	@Router			/authentication/passkeys/login/finish [post]
+func (app *application) finishPasskeyLoginHandler(w http.ResponseWriter, r *http.Request) {
+	parsed, err := protocol.ParseCredentialRequestResponseBody(http.MaxBytesReader(w, r.Body, 1_048_576))
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	session, _, err := app.consumePasskeySession(ctx, parsed.Response.CollectedClientData.Challenge)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.badRequestResponse(w, r, errPasskeyCeremony)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	var user *webAuthnUser
+	findUser := func(rawID, userHandle []byte) (webauthn.User, error) {
+		userID, err := strconv.ParseInt(string(userHandle), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		u, err := app.store.Users.GetByID(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+
+		user, err = app.getWebAuthnUser(ctx, u)
+		return user, err
+	}
+
+	credential, err := app.webauthn.ValidateDiscoverableLogin(findUser, *session, parsed)
+	if err != nil {
+		app.unauthorizedErrorResponse(w, r, err)
+		return
+	}
+
+	// a counter that didn't move forward means the key may have been copied
+	if credential.Authenticator.CloneWarning {
+		app.unauthorizedErrorResponse(w, r, errors.New("passkey sign count went backwards, possible cloned authenticator"))
+		return
+	}
+
+	data, err := json.Marshal(credential)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	passkey := &store.Passkey{
+		CredentialID: credential.ID,
+		SignCount:    credential.Authenticator.SignCount,
+		Credential:   data,
+	}
+
+	if err := app.store.Passkeys.UpdateAfterLogin(ctx, passkey); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if !user.InGoodStanding(time.Now()) {
+		app.accountRestrictedResponse(w, r, user.AccountStatus)
+		return
+	}
+
+	tokens, err := app.issueTokens(r, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, tokens); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// ListPasskeysHandler godoc
+//
+//	@Summary		List passkeys
+//	@Description	List the passkeys registered by the current user
+//	@Tags			authentication
+//	@Produce		json
+//
+//	@success		200	{object}	[]store.Passkey
+//	@failure		401	{object}	error
+//	@failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/authentication/passkeys [get]
+func (app *application) listPasskeysHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	passkeys, err := app.store.Passkeys.GetByUserID(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, passkeys); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// DeletePasskeyHandler godoc
+//
+//	@Summary		Remove a passkey
+//	@Description	Remove a passkey of the current user
+//	@Tags			authentication
+//	@Produce		json
+//	@Param			passkeyID	path		int		true	"Passkey ID"
+//
+//	@success		204			{string}	string	"Passkey removed"
+//	@failure		400			{object}	error
+//	@failure		404			{object}	error
+//	@failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/authentication/passkeys/{passkeyID} [delete]
+func (app *application) deletePasskeyHandler(w http.ResponseWriter, r *http.Request) {
+	passkeyID, err := strconv.ParseInt(chi.URLParam(r, "passkeyID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromCtx(r)
+
+	if err := app.store.Passkeys.Delete(r.Context(), user.ID, passkeyID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) getWebAuthnUser(ctx context.Context, user *store.User) (*webAuthnUser, error) {
+	passkeys, err := app.store.Passkeys.GetByUserID(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	credentials := make([]webauthn.Credential, len(passkeys))
+	for i, p := range passkeys {
+		if err := json.Unmarshal(p.Credential, &credentials[i]); err != nil {
+			return nil, err
+		}
+
+		// the column is the source of truth for the counter
+		credentials[i].Authenticator.SignCount = p.SignCount
+	}
+
+	return &webAuthnUser{User: user, credentials: credentials}, nil
+}
+
+func (app *application) savePasskeySession(ctx context.Context, session *webauthn.SessionData, userID int64) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+
+	return app.store.Passkeys.CreateSession(ctx, session.Challenge, userID, data, app.config.webauthn.sessionExp)
+}
+
+func (app *application) consumePasskeySession(ctx context.Context, challenge string) (*webauthn.SessionData, int64, error) {
+	userID, data, err := app.store.Passkeys.ConsumeSession(ctx, challenge)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	session := &webauthn.SessionData{}
+	if err := json.Unmarshal(data, session); err != nil {
+		return nil, 0, err
+	}
+
+	return session, userID, nil
+}
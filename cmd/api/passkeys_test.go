@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+)
+
+// softwareAuthenticator is a minimal platform authenticator holding a single
+// P-256 passkey, answering ceremonies the way a browser would.
+type softwareAuthenticator struct {
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	userHandle   []byte
+	signCount    uint32
+}
+
+func newSoftwareAuthenticator(t *testing.T) *softwareAuthenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id := make([]byte, 16)
+	rand.Read(id)
+
+	return &softwareAuthenticator{key: key, credentialID: id}
+}
+
+func (a *softwareAuthenticator) authData(rpID string, attested bool) []byte {
+	rpIDHash := sha256.Sum256([]byte(rpID))
+
+	flags := byte(0x01 | 0x04) // user present, user verified
+	if attested {
+		flags |= 0x40
+	}
+
+	data := append([]byte{}, rpIDHash[:]...)
+	data = append(data, flags)
+	data = binary.BigEndian.AppendUint32(data, a.signCount)
+
+	if attested {
+		data = append(data, make([]byte, 16)...) // zero AAGUID
+		data = binary.BigEndian.AppendUint16(data, uint16(len(a.credentialID)))
+		data = append(data, a.credentialID...)
+
+		coseKey, _ := webauthncbor.Marshal(map[int]any{
+			1:  2,  // kty: EC2
+			3:  -7, // alg: ES256
+			-1: 1,  // crv: P-256
+			-2: a.key.X.FillBytes(make([]byte, 32)),
+			-3: a.key.Y.FillBytes(make([]byte, 32)),
+		})
+		data = append(data, coseKey...)
+	}
+
+	return data
+}
+
+func clientData(t *testing.T, ceremony, challenge string) []byte {
+	data, err := json.Marshal(map[string]string{
+		"type":      ceremony,
+		"challenge": challenge,
+		"origin":    testOrigin,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func (a *softwareAuthenticator) create(t *testing.T, options map[string]any) []byte {
+	publicKey := options["publicKey"].(map[string]any)
+	user := publicKey["user"].(map[string]any)
+
+	handle, err := base64.RawURLEncoding.DecodeString(user["id"].(string))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.userHandle = handle
+
+	attestation, err := webauthncbor.Marshal(map[string]any{
+		"fmt":      "none",
+		"attStmt":  map[string]any{},
+		"authData": a.authData(publicKey["rp"].(map[string]any)["id"].(string), true),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b64 := base64.RawURLEncoding.EncodeToString
+	body, _ := json.Marshal(map[string]any{
+		"id":    b64(a.credentialID),
+		"rawId": b64(a.credentialID),
+		"type":  "public-key",
+		"response": map[string]string{
+			"clientDataJSON":    b64(clientData(t, "webauthn.create", publicKey["challenge"].(string))),
+			"attestationObject": b64(attestation),
+		},
+	})
+	return body
+}
+
+func (a *softwareAuthenticator) get(t *testing.T, options map[string]any) []byte {
+	publicKey := options["publicKey"].(map[string]any)
+
+	a.signCount++
+	authData := a.authData(publicKey["rpId"].(string), false)
+	clientDataJSON := clientData(t, "webauthn.get", publicKey["challenge"].(string))
+
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b64 := base64.RawURLEncoding.EncodeToString
+	body, _ := json.Marshal(map[string]any{
+		"id":    b64(a.credentialID),
+		"rawId": b64(a.credentialID),
+		"type":  "public-key",
+		"response": map[string]string{
+			"clientDataJSON":    b64(clientDataJSON),
+			"authenticatorData": b64(authData),
+			"signature":         b64(signature),
+			"userHandle":        b64(a.userHandle),
+		},
+	})
+	return body
+}
+
+func decodeData(t *testing.T, rr *httptest.ResponseRecorder) map[string]any {
+	var envelope struct {
+		Data map[string]any `json:"data"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&envelope); err != nil {
+		t.Fatal(err)
+	}
+	return envelope.Data
+}
+
+func TestPasskeys(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authenticator := newSoftwareAuthenticator(t)
+
+	post := func(path string, body []byte, authenticated bool) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodPost, path, bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if authenticated {
+			req.Header.Set("Authorization", "Bearer "+testToken)
+		}
+
+		return executeRequest(req, mux)
+	}
+
+	t.Run("should register a passkey", func(t *testing.T) {
+		rr := post("/v1/authentication/passkeys/register/begin", nil, true)
+		checkResponseCode(t, http.StatusOK, rr.Code)
+
+		body := authenticator.create(t, decodeData(t, rr))
+
+		rr = post("/v1/authentication/passkeys/register/finish?name=laptop", body, true)
+		checkResponseCode(t, http.StatusCreated, rr.Code)
+	})
+
+	t.Run("should login with the passkey", func(t *testing.T) {
+		rr := post("/v1/authentication/passkeys/login/begin", nil, false)
+		checkResponseCode(t, http.StatusOK, rr.Code)
+
+		body := authenticator.get(t, decodeData(t, rr))
+
+		rr = post("/v1/authentication/passkeys/login/finish", body, false)
+		checkResponseCode(t, http.StatusCreated, rr.Code)
+
+		if data := decodeData(t, rr); data["access_token"] == "" {
+			t.Errorf("expected an access token, got %v", data)
+		}
+	})
+
+	t.Run("should reject a replayed sign count", func(t *testing.T) {
+		rr := post("/v1/authentication/passkeys/login/begin", nil, false)
+		checkResponseCode(t, http.StatusOK, rr.Code)
+
+		authenticator.signCount-- // get() increments it back to the last used value
+		body := authenticator.get(t, decodeData(t, rr))
+
+		rr = post("/v1/authentication/passkeys/login/finish", body, false)
+		checkResponseCode(t, http.StatusUnauthorized, rr.Code)
+	})
+
+	t.Run("should not answer the same challenge twice", func(t *testing.T) {
+		rr := post("/v1/authentication/passkeys/login/begin", nil, false)
+		options := decodeData(t, rr)
+
+		rr = post("/v1/authentication/passkeys/login/finish", authenticator.get(t, options), false)
+		checkResponseCode(t, http.StatusCreated, rr.Code)
+
+		rr = post("/v1/authentication/passkeys/login/finish", authenticator.get(t, options), false)
+		checkResponseCode(t, http.StatusBadRequest, rr.Code)
+	})
+}
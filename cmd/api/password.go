@@ -0,0 +1,224 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"social/internal/mailer"
+	"social/internal/store"
+
+	"github.com/google/uuid"
+)
+
+type ForgotPasswordPayload struct {
+	Email string `json:"email" validate:"required,email,max=255"`
+}
+
+// ForgotPasswordHandler godoc
+//
+//	@Summary		Request a password reset
+//	@Description	Mails a single use password reset link if an active account uses the email
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		ForgotPasswordPayload	true	"Account email"
+//
+//	@success		202		{string}	string					"Reset requested"
+//	@failure		400		{object}	error
+//	@failure		500		{object}	error
+//	@Router			/authentication/forgot-password [post]
+func (app *application) forgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ForgotPasswordPayload
+	if err := readJson(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	// the response is the same whether the email exists or not
+	response := "if an account with that email exists, a reset link has been sent"
+
+	ctx := r.Context()
+
+	user, err := app.store.Users.GetByEmail(ctx, payload.Email)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			if err := app.jsonResponse(w, http.StatusAccepted, response); err != nil {
+				app.internalServerError(w, r, err)
+			}
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	plainToken := uuid.New().String()
+
+	if err := app.store.Users.CreatePasswordReset(ctx, user.ID, hashToken(plainToken), app.config.mail.resetExp); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	vars := struct {
+		Username  string
+		ResetURL  string
+		ExpiresIn string
+	}{
+		Username:  user.Username,
+		ResetURL:  fmt.Sprintf("%s/reset-password/%s", app.config.frontendURL, plainToken),
+		ExpiresIn: app.config.mail.resetExp.String(),
+	}
+
+	isProdEnv := app.config.env == "development"
+
+	// mail in the background so the response time doesn't tell whether the email exists
+	go func() {
+		err := app.mailer.SendEmail(mailer.PasswordResetTemplate, user.Username, user.Email, vars, !isProdEnv)
+		if err != nil {
+			app.logger.Errorw("error sending password reset email", "error", err)
+		}
+	}()
+
+	if err := app.jsonResponse(w, http.StatusAccepted, response); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+type ResetPasswordPayload struct {
+	Token    string `json:"token" validate:"required,max=255"`
+	Password string `json:"password" validate:"required,min=3,max=72"`
+}
+
+// ResetPasswordHandler godoc
+//
+//	@Summary		Reset a password
+//	@Description	Sets a new password with a reset token and signs the user out everywhere
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		ResetPasswordPayload	true	"Reset token and new password"
+//
+//	@success		204		{string}	string					"Password reset"
+//	@failure		400		{object}	error
+//	@failure		500		{object}	error
+//	@Router			/authentication/reset-password [post]
+func (app *application) resetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ResetPasswordPayload
+	if err := readJson(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := &store.User{}
+	if err := user.Password.Set(payload.Password); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.store.Users.ResetPassword(r.Context(), payload.Token, user); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.badRequestResponse(w, r, errors.New("invalid or expired reset token"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	// the refresh tokens are gone already, also cut the access tokens still out there
+	sessionIDs, err := app.store.Sessions.RevokeAll(r.Context(), user.ID, "")
+	if err != nil {
+		app.logger.Errorw("error revoking sessions after password reset", "user_id", user.ID, "error", err)
+	}
+	app.denySessions(r.Context(), sessionIDs...)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type ChangePasswordPayload struct {
+	CurrentPassword string `json:"current_password" validate:"required,max=72"`
+	NewPassword     string `json:"new_password" validate:"required,min=3,max=72"`
+}
+
+// ChangePasswordHandler godoc
+//
+//	@Summary		Change password
+//	@Description	Change the password of the current user, every other session is logged out and pending reset links stop working
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		ChangePasswordPayload	true	"Current and new password"
+//
+//	@Success		204		{string}	string					"Password changed"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/password [post]
+func (app *application) changePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ChangePasswordPayload
+	if err := readJson(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	user, ok := app.checkPassword(w, r, payload.CurrentPassword)
+	if !ok {
+		return
+	}
+
+	if err := user.Password.Set(payload.NewPassword); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	// the device making the change stays logged in
+	currentID := ""
+	if session := getSessionFromCtx(r); session != nil {
+		currentID = session.ID
+	}
+
+	sessionIDs, err := app.store.Users.ChangePassword(ctx, user, currentID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	app.denySessions(ctx, sessionIDs...)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// checkPassword reads the authenticated user from the store, as the cached
+// user has no password hash, and checks password against it. It writes the
+// error response and returns false if the password is wrong.
+func (app *application) checkPassword(w http.ResponseWriter, r *http.Request, password string) (*store.User, bool) {
+	user, err := app.store.Users.GetByID(r.Context(), getUserFromCtx(r).ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return nil, false
+	}
+
+	if err := user.Password.Compare(password); err != nil {
+		app.unauthorizedErrorResponse(w, r, err)
+		return nil, false
+	}
+
+	return user, true
+}
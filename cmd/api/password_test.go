@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestChangePassword(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should require the current password", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/v1/users/me/password", strings.NewReader(`{"current_password":"wrong","new_password":"new-password"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+testToken)
+
+		checkResponseCode(t, http.StatusUnauthorized, executeRequest(req, mux).Code)
+	})
+
+	t.Run("should reject a missing new password", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/v1/users/me/password", strings.NewReader(`{"current_password":"wrong"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+testToken)
+
+		checkResponseCode(t, http.StatusBadRequest, executeRequest(req, mux).Code)
+	})
+}
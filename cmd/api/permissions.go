@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"social/internal/store"
+)
+
+// Permissions are granted to roles in the role_permissions table. Owners of a
+// resource don't need them to change their own.
+const (
+	permPostsUpdate    = "posts:update"
+	permPostsDelete    = "posts:delete"
+	permCommentsDelete = "comments:delete"
+	permRolesManage    = "roles:manage"
+	permAuditRead      = "audit:read"
+
+	permUsersImpersonate = "users:impersonate"
+	permUsersModerate    = "users:moderate"
+)
+
+// requirePermission limits a route to users whose role grants the permission,
+// it runs after AuthenthicationMiddleware.
+func (app *application) requirePermission(permission string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			allowed, err := app.hasPermission(r.Context(), getUserFromCtx(r), permission)
+			if err != nil {
+				app.internalServerError(w, r, err)
+				return
+			}
+
+			if !allowed {
+				app.forbiddenResponse(w, r)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (app *application) hasPermission(ctx context.Context, user *store.User, permission string) (bool, error) {
+	permissions, err := app.getPermissions(ctx, user.ID)
+	if err != nil {
+		return false, err
+	}
+
+	return permissions.Has(permission), nil
+}
+
+// getPermissions reads the effective permissions of a user through the cache
+// when it is enabled, a cache failure falls back to the database.
+func (app *application) getPermissions(ctx context.Context, userID int64) (store.Permissions, error) {
+	if !app.config.redisCfg.enabled {
+		return app.store.Roles.GetPermissions(ctx, userID)
+	}
+
+	permissions, err := app.cacheStorage.Permissions.Get(ctx, userID)
+	if err != nil {
+		app.logger.Warnw("error reading cached permissions, falling back to the database", "user_id", userID, "error", err)
+	}
+
+	if permissions != nil {
+		return permissions, nil
+	}
+
+	permissions, err = app.store.Roles.GetPermissions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := app.cacheStorage.Permissions.Set(ctx, userID, permissions); err != nil {
+		app.logger.Warnw("error caching permissions", "user_id", userID, "error", err)
+	}
+
+	return permissions, nil
+}
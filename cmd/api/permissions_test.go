@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"social/internal/store"
+)
+
+func TestRequirePermission(t *testing.T) {
+	app := newTestApplication(t, config{})
+
+	handler := app.requirePermission(permPostsDelete)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	t.Run("should forbid a role without the permission", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodDelete, "/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ctx := context.WithValue(req.Context(), userCtx, &store.User{ID: 1})
+
+		checkResponseCode(t, http.StatusForbidden, executeRequest(req.WithContext(ctx), handler).Code)
+	})
+
+	t.Run("should allow a role with the permission", func(t *testing.T) {
+		roles := app.store.Roles.(*store.MockRoleStore)
+		roles.Permissions = store.Permissions{permPostsDelete}
+		defer func() { roles.Permissions = nil }()
+
+		req, err := http.NewRequest(http.MethodDelete, "/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ctx := context.WithValue(req.Context(), userCtx, &store.User{ID: 1})
+
+		checkResponseCode(t, http.StatusNoContent, executeRequest(req.WithContext(ctx), handler).Code)
+	})
+}
+
+func TestCheckPostOwnership(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roles := app.store.Roles.(*store.MockRoleStore)
+
+	request := func(t *testing.T, method, path string) int {
+		req, err := http.NewRequest(method, path, strings.NewReader(`{"title":"edited"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+testToken)
+
+		return executeRequest(req, mux).Code
+	}
+
+	// post 1 is by user 1, the user of the token, and post 2 by someone else
+	tests := []struct {
+		method     string
+		permission string
+		success    int
+	}{
+		{http.MethodPatch, permPostsUpdate, http.StatusOK},
+		{http.MethodDelete, permPostsDelete, http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.permission, func(t *testing.T) {
+			roles.Permissions = nil
+
+			t.Run("should let the author through", func(t *testing.T) {
+				checkResponseCode(t, tt.success, request(t, tt.method, "/v1/posts/1"))
+			})
+
+			t.Run("should forbid others without the permission", func(t *testing.T) {
+				checkResponseCode(t, http.StatusForbidden, request(t, tt.method, "/v1/posts/2"))
+			})
+
+			t.Run("should let others through with the permission", func(t *testing.T) {
+				roles.Permissions = store.Permissions{tt.permission}
+				defer func() { roles.Permissions = nil }()
+
+				checkResponseCode(t, tt.success, request(t, tt.method, "/v1/posts/2"))
+			})
+		})
+	}
+
+	t.Run("should not mix the permissions up", func(t *testing.T) {
+		roles.Permissions = store.Permissions{permPostsUpdate}
+		defer func() { roles.Permissions = nil }()
+
+		checkResponseCode(t, http.StatusForbidden, request(t, http.MethodDelete, "/v1/posts/2"))
+	})
+}
@@ -15,9 +15,16 @@ type postKey string
 const postCtx postKey = "post"
 
 type CreatePayload struct {
-	Title   string   `json:"title" validate:"required,max=100"`
-	Content string   `json:"content" validate:"required,max=1000"`
-	Tags    []string `json:"tags"`
+	Title   string             `json:"title" validate:"required,max=100"`
+	Content string             `json:"content" validate:"required,max=1000"`
+	Tags    []string           `json:"tags"`
+	Media   []PostMediaPayload `json:"media" validate:"max=4,unique=ID,dive"`
+}
+
+// PostMediaPayload attaches an upload of the author's to a post.
+type PostMediaPayload struct {
+	ID      string `json:"id" validate:"required,uuid"`
+	AltText string `json:"alt_text" validate:"max=1000"`
 }
 
 // CreatePostHandler godoc
@@ -31,11 +38,12 @@ type CreatePayload struct {
 //	@Param			payload	body		CreatePayload	true	"Post payload"
 //
 //	@Success		200		{object}	store.Post
-//	@Failure		400		{object}	error
+//	@Failure		400		{object}	error	"Invalid payload, or unknown media"
 //
 //	@Failure		401		{object}	error
 //
 //	@Failure		404		{object}	error
+//	@Failure		409		{object}	error	"Media attached twice"
 //	@Failure		500		{object}	error
 //	@Security		ApiKeyAuth
 //	@Router			/posts [post]
@@ -61,13 +69,26 @@ func (app *application) createPostHandler(w http.ResponseWriter, r *http.Request
 		UserID:  user.ID,
 	}
 
+	for _, m := range payload.Media {
+		post.Media = append(post.Media, store.Media{ID: m.ID, AltText: m.AltText})
+	}
+
 	ctx := r.Context()
 
 	if err := app.store.Posts.Create(ctx, post); err != nil {
-		app.internalServerError(w, r, err)
+		switch err {
+		case store.ErrUnknownMedia:
+			app.badRequestResponse(w, r, err)
+		case store.ErrorConflict:
+			app.conflictResponse(w, r, errors.New("the same media can't be attached twice"))
+		default:
+			app.internalServerError(w, r, err)
+		}
 		return
 	}
 
+	app.setPostMediaURLs(post)
+
 	if err := app.jsonResponse(w, http.StatusCreated, post); err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -115,7 +136,13 @@ func (app *application) getPostHandler(w http.ResponseWriter, r *http.Request) {
 	//with middleware
 	post := getPostFromCtx(r)
 
-	comments, err := app.store.Comments.GetByPostID(r.Context(), post.ID)
+	// a private account's posts don't exist for anyone but its approved followers
+	if !app.checkPostVisible(w, r, post) {
+		return
+	}
+
+	// only the newest page, the rest is listed by listCommentsHandler
+	comments, err := app.store.Comments.GetByPostID(r.Context(), post.ID, getUserFromCtx(r).ID, store.CommentQuery{Limit: 20, Sort: "desc"})
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -259,6 +286,8 @@ func (app *application) postsContextMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
+		app.setPostMediaURLs(post)
+
 		ctx = context.WithValue(ctx, postCtx, post)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
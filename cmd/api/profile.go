@@ -0,0 +1,116 @@
+package main
+
+import (
+	"net/http"
+	"social/internal/store"
+)
+
+// Account is the current user as they see themselves, email included.
+type Account struct {
+	*store.User
+	FollowersCount int64 `json:"followers_count"`
+	FollowingCount int64 `json:"following_count"`
+}
+
+// GetMe godoc
+//
+//	@Summary		Fetch the current user
+//	@Description	Fetch the account of the current user, with the email other users don't see
+//	@Tags			users
+//	@Produce		json
+//
+//	@Success		200	{object}	Account
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me [get]
+func (app *application) getMeHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	followers, following, err := app.store.Followers.Counts(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	account := Account{
+		User:           user,
+		FollowersCount: followers,
+		FollowingCount: following,
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, account); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// UpdateProfilePayload only changes the fields it has, an empty string clears
+// one.
+type UpdateProfilePayload struct {
+	DisplayName *string `json:"display_name" validate:"omitempty,max=50"`
+	Bio         *string `json:"bio" validate:"omitempty,max=300"`
+	Website     *string `json:"website" validate:"omitempty,max=255,http_url|len=0"`
+	Location    *string `json:"location" validate:"omitempty,max=100"`
+	AvatarURL   *string `json:"avatar_url" validate:"omitempty,max=255,http_url|len=0"`
+}
+
+// UpdateMe godoc
+//
+//	@Summary		Update the current user's profile
+//	@Description	Change the display name, bio, website, location or avatar URL of the current user
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		UpdateProfilePayload	true	"Profile fields to change"
+//
+//	@Success		200		{object}	store.User
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me [patch]
+func (app *application) updateMeHandler(w http.ResponseWriter, r *http.Request) {
+	var payload UpdateProfilePayload
+	if err := readJson(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := *getUserFromCtx(r)
+
+	if payload.DisplayName != nil {
+		user.DisplayName = *payload.DisplayName
+	}
+	if payload.Bio != nil {
+		user.Bio = *payload.Bio
+	}
+	if payload.Website != nil {
+		user.Website = *payload.Website
+	}
+	if payload.Location != nil {
+		user.Location = *payload.Location
+	}
+	if payload.AvatarURL != nil {
+		user.AvatarURL = *payload.AvatarURL
+	}
+
+	ctx := r.Context()
+
+	if err := app.store.Users.UpdateProfile(ctx, user.ID, user.Profile); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	// profiles are read through the user cache, and autocomplete shows them
+	app.forgetUser(ctx, user.ID)
+	app.forgetUserSearch(ctx, user.Username)
+
+	if err := app.jsonResponse(w, http.StatusOK, user); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestProfile(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should fetch the current user", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/users/me", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+testToken)
+
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusOK, rr.Code)
+
+		if !strings.Contains(rr.Body.String(), `"email"`) {
+			t.Errorf("expected the current user to see their email, got %s", rr.Body.String())
+		}
+	})
+
+	t.Run("should not show the email of other users", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/users/2", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+testToken)
+
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusOK, rr.Code)
+
+		if strings.Contains(rr.Body.String(), `"email"`) {
+			t.Errorf("expected no email in a public profile, got %s", rr.Body.String())
+		}
+	})
+
+	t.Run("should update the profile", func(t *testing.T) {
+		body := `{"display_name": "Gopher", "website": "https://go.dev", "avatar_url": ""}`
+		req, err := http.NewRequest(http.MethodPatch, "/v1/users/me", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+testToken)
+
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusOK, rr.Code)
+
+		if !strings.Contains(rr.Body.String(), `"display_name":"Gopher"`) {
+			t.Errorf("expected the new display name, got %s", rr.Body.String())
+		}
+	})
+
+	t.Run("should reject a website that isn't a web URL", func(t *testing.T) {
+		body := `{"website": "javascript:alert(1)"}`
+		req, err := http.NewRequest(http.MethodPatch, "/v1/users/me", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+testToken)
+
+		checkResponseCode(t, http.StatusBadRequest, executeRequest(req, mux).Code)
+	})
+}
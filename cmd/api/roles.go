@@ -0,0 +1,338 @@
+package main
+
+import (
+	"net/http"
+	"social/internal/store"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type CreateRolePayload struct {
+	Name        string   `json:"name" validate:"required,max=255"`
+	Description string   `json:"description" validate:"max=1000"`
+	Level       int      `json:"level" validate:"gte=0"`
+	Permissions []string `json:"permissions" validate:"unique"`
+}
+
+// UpdateRolePayload only changes the fields that are sent, permissions are
+// replaced as a whole.
+type UpdateRolePayload struct {
+	Name        *string  `json:"name" validate:"omitempty,min=1,max=255"`
+	Description *string  `json:"description" validate:"omitempty,max=1000"`
+	Level       *int     `json:"level" validate:"omitempty,gte=0"`
+	Permissions []string `json:"permissions" validate:"unique"`
+}
+
+type AssignRolePayload struct {
+	RoleID int64 `json:"role_id" validate:"required,gte=1"`
+}
+
+// ListPermissionsHandler godoc
+//
+//	@Summary		List permissions
+//	@Description	List the permissions roles can be granted
+//	@Tags			admin
+//	@Produce		json
+//
+//	@Success		200	{object}	[]store.Permission
+//	@Failure		403	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/permissions [get]
+func (app *application) listPermissionsHandler(w http.ResponseWriter, r *http.Request) {
+	permissions, err := app.store.Roles.GetAllPermissions(r.Context())
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, permissions); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// ListRolesHandler godoc
+//
+//	@Summary		List roles
+//	@Description	List the roles with their permissions
+//	@Tags			admin
+//	@Produce		json
+//
+//	@Success		200	{object}	[]store.Role
+//	@Failure		403	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/roles [get]
+func (app *application) listRolesHandler(w http.ResponseWriter, r *http.Request) {
+	roles, err := app.store.Roles.GetAll(r.Context())
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, roles); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// CreateRoleHandler godoc
+//
+//	@Summary		Create a role
+//	@Description	Create a role granting a set of permissions
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		CreateRolePayload	true	"Role"
+//
+//	@Success		201		{object}	store.Role
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		409		{object}	error	"Name already taken"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/roles [post]
+func (app *application) createRoleHandler(w http.ResponseWriter, r *http.Request) {
+	var payload CreateRolePayload
+	if err := readJson(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	role := &store.Role{
+		Name:        payload.Name,
+		Description: payload.Description,
+		Level:       payload.Level,
+		Permissions: payload.Permissions,
+	}
+
+	if err := app.store.Roles.Create(r.Context(), role); err != nil {
+		switch err {
+		case store.ErrorConflict:
+			app.conflictResponse(w, r, err)
+		case store.ErrUnknownPermission:
+			app.badRequestResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.audit(r, auditRolesCreate, "role", int64(role.ID), nil, role)
+
+	if err := app.jsonResponse(w, http.StatusCreated, role); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// UpdateRoleHandler godoc
+//
+//	@Summary		Update a role
+//	@Description	Update a role, the permissions sent replace the current ones. Default roles can't be renamed and admin keeps roles:manage
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			roleID	path		int					true	"Role ID"
+//	@Param			payload	body		UpdateRolePayload	true	"Fields to change"
+//
+//	@Success		200		{object}	store.Role
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/roles/{roleID} [patch]
+func (app *application) updateRoleHandler(w http.ResponseWriter, r *http.Request) {
+	roleID, err := strconv.ParseInt(chi.URLParam(r, "roleID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	var payload UpdateRolePayload
+	if err := readJson(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	role, err := app.store.Roles.GetByID(ctx, roleID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	before := *role
+
+	if payload.Name != nil {
+		role.Name = *payload.Name
+	}
+	if payload.Description != nil {
+		role.Description = *payload.Description
+	}
+	if payload.Level != nil {
+		role.Level = *payload.Level
+	}
+	if payload.Permissions != nil {
+		role.Permissions = payload.Permissions
+	}
+
+	if err := app.store.Roles.Update(ctx, role); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		case store.ErrorConflict, store.ErrDefaultRole, store.ErrAdminLockout:
+			app.conflictResponse(w, r, err)
+		case store.ErrUnknownPermission:
+			app.badRequestResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.audit(r, auditRolesUpdate, "role", roleID, before, role)
+
+	// every holder of the role may have its permissions cached
+	if app.config.redisCfg.enabled {
+		if err := app.cacheStorage.Permissions.DeleteAll(ctx); err != nil {
+			app.logger.Errorw("error clearing cached permissions", "role_id", roleID, "error", err)
+		}
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, role); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// ListRoleUsersHandler godoc
+//
+//	@Summary		List users by role
+//	@Description	List the users that have a role, oldest accounts first
+//	@Tags			admin
+//	@Produce		json
+//	@Param			roleID	path		int	true	"Role ID"
+//	@Param			limit	query		int	false	"Limit"
+//	@Param			offset	query		int	false	"Offset"
+//
+//	@Success		200		{object}	[]store.User
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/roles/{roleID}/users [get]
+func (app *application) listRoleUsersHandler(w http.ResponseWriter, r *http.Request) {
+	roleID, err := strconv.ParseInt(chi.URLParam(r, "roleID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	q := store.PaginatedQuery{
+		Limit:  20,
+		Offset: 0,
+	}
+
+	q, err = q.Parse(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(q); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	users, err := app.store.Roles.GetUsers(r.Context(), roleID, q)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, users); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// AssignRoleHandler godoc
+//
+//	@Summary		Assign a role
+//	@Description	Give a user a role, the last admin can't be demoted
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			userID	path		int					true	"User ID"
+//	@Param			payload	body		AssignRolePayload	true	"Role"
+//
+//	@Success		204		{string}	string				"Role assigned"
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error	"Unknown user or role"
+//	@Failure		409		{object}	error	"Last admin"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/users/{userID}/role [put]
+func (app *application) assignRoleHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	var payload AssignRolePayload
+	if err := readJson(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	previousID, err := app.store.Roles.Assign(ctx, userID, payload.RoleID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		case store.ErrLastAdmin:
+			app.conflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.audit(
+		r,
+		auditRolesAssign,
+		"user",
+		userID,
+		map[string]int64{"role_id": previousID},
+		map[string]int64{"role_id": payload.RoleID},
+	)
+
+	app.forgetUser(ctx, userID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
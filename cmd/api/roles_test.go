@@ -0,0 +1,67 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"social/internal/store"
+)
+
+func TestRoles(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roles := app.store.Roles.(*store.MockRoleStore)
+
+	t.Run("should forbid users without roles:manage", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/admin/roles", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+testToken)
+
+		checkResponseCode(t, http.StatusForbidden, executeRequest(req, mux).Code)
+	})
+
+	roles.Permissions = store.Permissions{permRolesManage}
+
+	t.Run("should list roles for admins", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/admin/roles", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+testToken)
+
+		checkResponseCode(t, http.StatusOK, executeRequest(req, mux).Code)
+	})
+
+	t.Run("should assign a role", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPut, "/v1/admin/users/2/role", strings.NewReader(`{"role_id":2}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+testToken)
+
+		checkResponseCode(t, http.StatusNoContent, executeRequest(req, mux).Code)
+	})
+
+	t.Run("should reject duplicate permissions", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPatch, "/v1/admin/roles/2", strings.NewReader(`{"permissions":["posts:update","posts:update"]}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+testToken)
+
+		checkResponseCode(t, http.StatusBadRequest, executeRequest(req, mux).Code)
+	})
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"social/internal/store"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// hotPrefixLength is the longest prefix whose matches are cached, longer
+	// ones match few users and are cheap to look up
+	hotPrefixLength = 3
+	// prefixCandidates is how many prefix matches are looked up, so enough
+	// remain once the users blocked with the caller are dropped
+	prefixCandidates = 40
+)
+
+// SearchUsersHandler godoc
+//
+//	@Summary		Search users
+//	@Description	Search active users by username and display name, closest matches first. With prefix=true only usernames starting with q match, for @mention autocomplete
+//	@Tags			users
+//	@Produce		json
+//	@Param			q		query		string	true	"Search text, a leading @ is ignored"
+//	@Param			prefix	query		bool	false	"Match the start of usernames only"
+//	@Param			limit	query		int		false	"Limit, at most 20"
+//
+//	@Success		200		{object}	[]store.UserSearchResult
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/search [get]
+func (app *application) searchUsersHandler(w http.ResponseWriter, r *http.Request) {
+	q := store.UserSearchQuery{Limit: 10}
+
+	q, err := q.Parse(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(q); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	viewerID := getUserFromCtx(r).ID
+
+	var results []store.UserSearchResult
+	if q.Prefix {
+		results, err = app.searchUsersByPrefix(ctx, viewerID, q.Q, q.Limit)
+	} else {
+		results, err = app.store.Users.Search(ctx, viewerID, q.Q, q.Limit)
+	}
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, results); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// searchUsersByPrefix drops the users blocked with the viewer from the prefix
+// matches, which are shared by every viewer.
+func (app *application) searchUsersByPrefix(ctx context.Context, viewerID int64, prefix string, limit int) ([]store.UserSearchResult, error) {
+	candidates, err := app.getPrefixMatches(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]int64, len(candidates))
+	for i, c := range candidates {
+		ids[i] = c.ID
+	}
+
+	blocked, err := app.store.Blocks.BlockedAmong(ctx, viewerID, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	hidden := make(map[int64]bool, len(blocked))
+	for _, id := range blocked {
+		hidden[id] = true
+	}
+
+	results := []store.UserSearchResult{}
+	for _, c := range candidates {
+		if hidden[c.ID] {
+			continue
+		}
+
+		results = append(results, c)
+		if len(results) == limit {
+			break
+		}
+	}
+
+	return results, nil
+}
+
+// getPrefixMatches reads the matches of hot prefixes through the cache, a
+// cache that can't be reached only makes the lookup slower.
+func (app *application) getPrefixMatches(ctx context.Context, prefix string) ([]store.UserSearchResult, error) {
+	// matched regardless of case, so cached once for every casing
+	prefix = strings.ToLower(prefix)
+
+	if !app.config.redisCfg.enabled || utf8.RuneCountInString(prefix) > hotPrefixLength {
+		return app.store.Users.SearchByPrefix(ctx, prefix, prefixCandidates)
+	}
+
+	results, err := app.cacheStorage.UserSearch.Get(ctx, prefix)
+	if err != nil {
+		app.logger.Warnw("error reading cached user search, falling back to the database", "error", err)
+	}
+
+	if results != nil {
+		return results, nil
+	}
+
+	results, err = app.store.Users.SearchByPrefix(ctx, prefix, prefixCandidates)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := app.cacheStorage.UserSearch.Set(ctx, prefix, results); err != nil {
+		app.logger.Warnw("error caching user search", "prefix", prefix, "error", err)
+	}
+
+	return results, nil
+}
+
+// forgetUserSearch drops the cached prefix matches a user shows up in, once
+// their standing or what the matches show of them changes. The users
+// reinstated in bulk are left for the cache to expire, UserSearchExpTime is
+// short.
+func (app *application) forgetUserSearch(ctx context.Context, username string) {
+	if !app.config.redisCfg.enabled {
+		return
+	}
+
+	if err := app.cacheStorage.UserSearch.Delete(ctx, hotPrefixes(username)...); err != nil {
+		app.logger.Warnw("error clearing cached user search", "username", username, "error", err)
+	}
+}
+
+// hotPrefixes are the cached prefixes a username matches.
+func hotPrefixes(username string) []string {
+	runes := []rune(strings.ToLower(username))
+
+	prefixes := make([]string, 0, hotPrefixLength)
+	for n := 1; n <= min(len(runes), hotPrefixLength); n++ {
+		prefixes = append(prefixes, string(runes[:n]))
+	}
+
+	return prefixes
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestSearchUsers(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should require a search", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/users/search", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+testToken)
+
+		checkResponseCode(t, http.StatusBadRequest, executeRequest(req, mux).Code)
+	})
+
+	t.Run("should leave out users blocked with the caller when autocompleting", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/users/search?q=@go&prefix=true", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+testToken)
+
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusOK, rr.Code)
+
+		body := rr.Body.String()
+		if !strings.Contains(body, `"username":"go2"`) || strings.Contains(body, `"username":"go4"`) {
+			t.Errorf("expected only go2 to match, got %s", body)
+		}
+	})
+}
+
+func TestHotPrefixes(t *testing.T) {
+	tests := []struct {
+		username string
+		want     []string
+	}{
+		{"Gopher", []string{"g", "go", "gop"}},
+		{"al", []string{"a", "al"}},
+		{"Ünal", []string{"ü", "ün", "üna"}},
+	}
+
+	for _, tt := range tests {
+		if got := hotPrefixes(tt.username); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("hotPrefixes(%q) = %v, want %v", tt.username, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"social/internal/store"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type sessionKey string
+
+const sessionCtx sessionKey = "session"
+
+var errSessionRevoked = errors.New("session has been revoked")
+
+type UserSession struct {
+	store.Session
+	Current bool `json:"current"` // the session making the request
+}
+
+// ListSessionsHandler godoc
+//
+//	@Summary		List sessions
+//	@Description	List the devices the current user is logged in on
+//	@Tags			users
+//	@Produce		json
+//
+//	@Success		200	{object}	[]UserSession
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/sessions [get]
+func (app *application) listSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	sessions, err := app.store.Sessions.GetByUserID(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	current := getSessionFromCtx(r)
+
+	response := make([]UserSession, len(sessions))
+	for i, s := range sessions {
+		response[i] = UserSession{
+			Session: s,
+			Current: current != nil && current.ID == s.ID,
+		}
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, response); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// RevokeSessionHandler godoc
+//
+//	@Summary		Revoke a session
+//	@Description	Log the current user out of a device, its tokens stop working immediately
+//	@Tags			users
+//	@Produce		json
+//	@Param			sessionID	path		string	true	"Session ID"
+//
+//	@Success		204			{string}	string	"Session revoked"
+//	@Failure		400			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/sessions/{sessionID} [delete]
+func (app *application) revokeSessionHandler(w http.ResponseWriter, r *http.Request) {
+	sessionID := chi.URLParam(r, "sessionID")
+	if err := Validate.Var(sessionID, "uuid"); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromCtx(r)
+
+	if err := app.store.Sessions.Revoke(r.Context(), user.ID, sessionID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.denySessions(r.Context(), sessionID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// checkSession makes sure the session of an access token hasn't been revoked.
+// The Redis denylist answers when it is enabled, Postgres otherwise or when
+// Redis can't be reached.
+func (app *application) checkSession(ctx context.Context, sessionID string, userID int64) (*store.Session, error) {
+	if app.config.redisCfg.enabled {
+		revoked, err := app.cacheStorage.Sessions.IsRevoked(ctx, sessionID)
+		if err == nil {
+			if revoked {
+				return nil, errSessionRevoked
+			}
+			return &store.Session{ID: sessionID, UserID: userID}, nil
+		}
+
+		app.logger.Warnw("error reading the session denylist, falling back to the database", "error", err)
+	}
+
+	session, err := app.store.Sessions.GetByID(ctx, sessionID)
+	if err != nil {
+		if err == store.ErrNotFound {
+			return nil, errSessionRevoked
+		}
+		return nil, err
+	}
+
+	if session.UserID != userID {
+		return nil, errSessionRevoked
+	}
+
+	return session, nil
+}
+
+// denySessions adds revoked sessions to the Redis denylist for as long as
+// their access tokens may still be presented.
+func (app *application) denySessions(ctx context.Context, sessionIDs ...string) {
+	if !app.config.redisCfg.enabled {
+		return
+	}
+
+	for _, id := range sessionIDs {
+		if err := app.cacheStorage.Sessions.Revoke(ctx, id, app.config.auth.token.exp); err != nil {
+			app.logger.Errorw("error adding session to the denylist", "session_id", id, "error", err)
+		}
+	}
+}
+
+// clientIP is the address of the client, RealIP has already resolved proxies.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func getSessionFromCtx(r *http.Request) *store.Session {
+	session, ok := r.Context().Value(sessionCtx).(*store.Session)
+	if !ok {
+		return nil
+	}
+	return session
+}
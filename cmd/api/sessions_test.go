@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestSessions(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the session of the token signed by the test authenticator
+	sessionID := "6f1c2b8e-3d4a-4c5b-9e7f-0a1b2c3d4e5f"
+
+	request := func(method, path string) int {
+		req, err := http.NewRequest(method, path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+testToken)
+
+		return executeRequest(req, mux).Code
+	}
+
+	t.Run("should list the sessions", func(t *testing.T) {
+		checkResponseCode(t, http.StatusOK, request(http.MethodGet, "/v1/users/me/sessions"))
+	})
+
+	t.Run("should reject a malformed session ID", func(t *testing.T) {
+		checkResponseCode(t, http.StatusBadRequest, request(http.MethodDelete, "/v1/users/me/sessions/nope"))
+	})
+
+	t.Run("should reject the tokens of a revoked session", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNoContent, request(http.MethodDelete, "/v1/users/me/sessions/"+sessionID))
+
+		checkResponseCode(t, http.StatusUnauthorized, request(http.MethodGet, "/v1/users/me/sessions"))
+	})
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"social/internal/store"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maxSuggestions is how many suggestions are ranked and cached per user, the
+// endpoint serves a prefix of them.
+const maxSuggestions = 50
+
+// ListSuggestionsHandler godoc
+//
+//	@Summary		Who to follow
+//	@Description	Suggest users to follow, ranked by how many of the users you follow follow them, the tags you both post and how active they are
+//	@Tags			users
+//	@Produce		json
+//	@Param			limit	query		int	false	"Limit, at most 50"
+//
+//	@Success		200		{object}	[]store.Suggestion
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/suggestions [get]
+func (app *application) listSuggestionsHandler(w http.ResponseWriter, r *http.Request) {
+	limit := 10
+	if l := r.URL.Query().Get("limit"); l != "" {
+		var err error
+		limit, err = strconv.Atoi(l)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+	}
+
+	if limit < 1 || limit > maxSuggestions {
+		app.badRequestResponse(w, r, errors.New("limit must be between 1 and 50"))
+		return
+	}
+
+	suggestions, err := app.getSuggestions(r.Context(), getUserFromCtx(r).ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, suggestions); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// getSuggestions reads the suggestions of a user through the cache, ranking
+// them on a miss.
+func (app *application) getSuggestions(ctx context.Context, userID int64) ([]store.Suggestion, error) {
+	if !app.config.redisCfg.enabled {
+		return app.store.Suggestions.Get(ctx, userID, maxSuggestions)
+	}
+
+	suggestions, err := app.cacheStorage.Suggestions.Get(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if suggestions == nil {
+		suggestions, err = app.store.Suggestions.Get(ctx, userID, maxSuggestions)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := app.cacheStorage.Suggestions.Set(ctx, userID, suggestions, app.config.suggestions.cacheExp); err != nil {
+			return nil, err
+		}
+	}
+
+	return suggestions, nil
+}
+
+// refreshSuggestions ranks the suggestions of the most recently seen users
+// ahead of time, so the endpoint is a cache hit for the users likely to call
+// it. A few rankings run at once, the others miss the cache and are ranked on
+// their next request.
+func (app *application) refreshSuggestions(ctx context.Context) {
+	cfg := app.config.suggestions
+	since := time.Now().Add(-cfg.activeWithin)
+
+	userIDs, err := app.store.Suggestions.GetRecentUserIDs(ctx, since, cfg.maxRefresh)
+	if err != nil {
+		app.logger.Errorw("error listing users to suggest to", "error", err)
+		return
+	}
+
+	ids := make(chan int64)
+	var (
+		wg        sync.WaitGroup
+		refreshed atomic.Int64
+	)
+
+	for range max(cfg.workers, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for id := range ids {
+				suggestions, err := app.store.Suggestions.Get(ctx, id, maxSuggestions)
+				if err != nil {
+					app.logger.Errorw("error ranking suggestions", "user_id", id, "error", err)
+					continue
+				}
+
+				if err := app.cacheStorage.Suggestions.Set(ctx, id, suggestions, cfg.cacheExp); err != nil {
+					app.logger.Errorw("error caching suggestions", "user_id", id, "error", err)
+					continue
+				}
+
+				refreshed.Add(1)
+			}
+		}()
+	}
+
+	for _, id := range userIDs {
+		ids <- id
+	}
+	close(ids)
+	wg.Wait()
+
+	app.logger.Infow("refreshed follow suggestions", "users", refreshed.Load())
+}
+
+// forgetSuggestions drops cached suggestions once who the users follow, or are
+// blocked with, changes what they rank.
+func (app *application) forgetSuggestions(ctx context.Context, userIDs ...int64) {
+	if !app.config.redisCfg.enabled {
+		return
+	}
+
+	for _, id := range userIDs {
+		if err := app.cacheStorage.Suggestions.Delete(ctx, id); err != nil {
+			app.logger.Errorw("error clearing cached suggestions", "user_id", id, "error", err)
+		}
+	}
+}
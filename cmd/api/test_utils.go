@@ -6,13 +6,19 @@ import (
 	"testing"
 
 	"social/internal/auth"
+	"social/internal/lockout"
+	"social/internal/mailer"
+	"social/internal/media"
 	"social/internal/ratelimiter"
 	"social/internal/store"
 	"social/internal/store/cache"
 
+	"github.com/go-webauthn/webauthn/webauthn"
 	"go.uber.org/zap"
 )
 
+const testOrigin = "http://localhost:4000"
+
 func newTestApplication(t *testing.T, cfg config) *application {
 	t.Helper()
 
@@ -30,6 +36,20 @@ func newTestApplication(t *testing.T, cfg config) *application {
 		cfg.rateLimiter.TimeFrame,
 	)
 
+	webAuthn, err := webauthn.New(&webauthn.Config{
+		RPID:          "localhost",
+		RPDisplayName: "GopherSocial",
+		RPOrigins:     []string{testOrigin},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blobStore, err := media.NewLocalStore(t.TempDir(), "http://localhost:8080/v1/media/files")
+	if err != nil {
+		t.Fatal(err)
+	}
+
 	return &application{
 		logger:        logger,
 		store:         mockStore,
@@ -37,6 +57,12 @@ func newTestApplication(t *testing.T, cfg config) *application {
 		authenticator: testAuth,
 		config:        cfg,
 		rateLimiter:   rateLimiter,
+		webauthn:      webAuthn,
+		mailer:        &mailer.MockClient{},
+		emailLockout:  lockout.NewMemoryTracker(cfg.lockout.email),
+		ipLockout:     lockout.NewMemoryTracker(cfg.lockout.ip),
+		mfaLockout:    lockout.NewMemoryTracker(cfg.lockout.mfa),
+		blobStore:     blobStore,
 	}
 }
 
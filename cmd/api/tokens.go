@@ -0,0 +1,171 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"social/internal/store"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type accessTokenKey string
+
+const accessTokenCtx accessTokenKey = "access_token"
+
+const (
+	personalAccessTokenPrefix = "gsp_"
+
+	scopePostsRead  = "posts:read"
+	scopePostsWrite = "posts:write"
+	scopeFeedRead   = "feed:read"
+	scopeUsersRead  = "users:read"
+	scopeUsersWrite = "users:write"
+
+	// scopeAccount guards credential and account management, it is never
+	// granted to a personal access token
+	scopeAccount = "account"
+)
+
+type CreateAccessTokenPayload struct {
+	Name          string   `json:"name" validate:"required,max=100"`
+	Scopes        []string `json:"scopes" validate:"required,min=1,dive,oneof=posts:read posts:write feed:read users:read users:write"`
+	ExpiresInDays int      `json:"expires_in_days" validate:"omitempty,min=1,max=365"`
+}
+
+type AccessTokenWithToken struct {
+	*store.PersonalAccessToken
+	Token string `json:"token"`
+}
+
+// CreateAccessTokenHandler godoc
+//
+//	@Summary		Create a personal access token
+//	@Description	Create a scoped token for scripts and bots, the token is only shown once
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		CreateAccessTokenPayload	true	"Token name, scopes and lifetime"
+//
+//	@Success		201		{object}	AccessTokenWithToken
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/tokens [post]
+func (app *application) createAccessTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var payload CreateAccessTokenPayload
+	if err := readJson(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if payload.ExpiresInDays == 0 {
+		payload.ExpiresInDays = 30
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	plainToken := personalAccessTokenPrefix + hex.EncodeToString(raw)
+
+	user := getUserFromCtx(r)
+
+	token := &store.PersonalAccessToken{
+		UserID:    user.ID,
+		Name:      payload.Name,
+		Token:     hashToken(plainToken),
+		Scopes:    payload.Scopes,
+		ExpiresAt: time.Now().Add(time.Hour * 24 * time.Duration(payload.ExpiresInDays)),
+	}
+
+	if err := app.store.PersonalAccessTokens.Create(r.Context(), token); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, &AccessTokenWithToken{token, plainToken}); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// ListAccessTokensHandler godoc
+//
+//	@Summary		List personal access tokens
+//	@Description	List the personal access tokens of the current user
+//	@Tags			users
+//	@Produce		json
+//
+//	@Success		200	{object}	[]store.PersonalAccessToken
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/tokens [get]
+func (app *application) listAccessTokensHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	tokens, err := app.store.PersonalAccessTokens.GetByUserID(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, tokens); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// DeleteAccessTokenHandler godoc
+//
+//	@Summary		Revoke a personal access token
+//	@Description	Revoke a personal access token of the current user
+//	@Tags			users
+//	@Produce		json
+//	@Param			tokenID	path		int		true	"Token ID"
+//
+//	@Success		204		{string}	string	"Token revoked"
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/tokens/{tokenID} [delete]
+func (app *application) deleteAccessTokenHandler(w http.ResponseWriter, r *http.Request) {
+	tokenID, err := strconv.ParseInt(chi.URLParam(r, "tokenID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromCtx(r)
+
+	if err := app.store.PersonalAccessTokens.Delete(r.Context(), user.ID, tokenID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getAccessTokenFromCtx returns the personal access token the request was
+// authenticated with, nil for a JWT.
+func getAccessTokenFromCtx(r *http.Request) *store.PersonalAccessToken {
+	pat, ok := r.Context().Value(accessTokenCtx).(*store.PersonalAccessToken)
+	if !ok {
+		return nil
+	}
+	return pat
+}
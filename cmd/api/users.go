@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"social/internal/store"
 	"strconv"
@@ -12,6 +14,31 @@ import (
 
 const userCtx postKey = "user"
 
+// PublicUser is what other users get to see of a user, never the email.
+type PublicUser struct {
+	ID        int64  `json:"id"`
+	Username  string `json:"username"`
+	CreatedAt string `json:"created_at"`
+	IsPrivate bool   `json:"is_private"`
+	store.Profile
+}
+
+func publicUser(user *store.User) PublicUser {
+	return PublicUser{
+		ID:        user.ID,
+		Username:  user.Username,
+		CreatedAt: user.CreatedAt,
+		IsPrivate: user.IsPrivate,
+		Profile:   user.Profile,
+	}
+}
+
+type UserProfile struct {
+	PublicUser
+	FollowersCount int64 `json:"followers_count"`
+	FollowingCount int64 `json:"following_count"`
+}
+
 // GetUser godoc
 //
 //	@Summary		Ferches a user profile by ID
@@ -20,7 +47,7 @@ const userCtx postKey = "user"
 //	@Accept			json
 //	@Produce		json
 //	@Param			id	path		int	true	"UserID"
-//	@Success		200	{object}	store.User
+//	@Success		200	{object}	UserProfile
 //	@Failure		400	{object}	error
 //	@Failure		404	{object}	error
 //	@Failure		500	{object}	error
@@ -35,18 +62,132 @@ func (app *application) getUserHandler(w http.ResponseWriter, r *http.Request) {
 
 	ctx := r.Context()
 
-	user, err := app.getUser(ctx, userID)
+	user, err := app.getVisibleUser(ctx, userID, getUserFromCtx(r).ID)
 	if err != nil {
 		switch err {
 		case store.ErrNotFound:
-			app.unauthorizedErrorResponse(w, r, err)
+			app.notFoundResponse(w, r, err)
 		default:
 			app.internalServerError(w, r, err)
 		}
 		return
 	}
 
-	if err := app.jsonResponse(w, http.StatusOK, user); err != nil {
+	followers, following, err := app.store.Followers.Counts(ctx, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	profile := UserProfile{
+		PublicUser:     publicUser(user),
+		FollowersCount: followers,
+		FollowingCount: following,
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, profile); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+type FollowPage struct {
+	Users      []store.FollowEntry `json:"users"`
+	NextCursor string              `json:"next_cursor,omitempty"` // empty on the last page
+}
+
+// ListFollowersHandler godoc
+//
+//	@Summary		List followers
+//	@Description	List the users following a user, newest first, flagged relative to the caller. Pass next_cursor back as cursor for the next page
+//	@Tags			users
+//	@Produce		json
+//	@Param			userID	path		int		true	"User ID"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			cursor	query		string	false	"Cursor"
+//	@Success		200		{object}	FollowPage
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/followers [get]
+func (app *application) listFollowersHandler(w http.ResponseWriter, r *http.Request) {
+	app.listFollows(w, r, app.store.Followers.GetFollowers)
+}
+
+// ListFollowingHandler godoc
+//
+//	@Summary		List followed users
+//	@Description	List the users a user follows, newest first, flagged relative to the caller. Pass next_cursor back as cursor for the next page
+//	@Tags			users
+//	@Produce		json
+//	@Param			userID	path		int		true	"User ID"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			cursor	query		string	false	"Cursor"
+//	@Success		200		{object}	FollowPage
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/following [get]
+func (app *application) listFollowingHandler(w http.ResponseWriter, r *http.Request) {
+	app.listFollows(w, r, app.store.Followers.GetFollowing)
+}
+
+type followListFunc func(ctx context.Context, userID, viewerID int64, q store.FollowQuery) ([]store.FollowEntry, error)
+
+func (app *application) listFollows(w http.ResponseWriter, r *http.Request, list followListFunc) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	q := store.FollowQuery{Limit: 20}
+
+	q, err = q.Parse(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(q); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	if _, err := app.getVisibleUser(ctx, userID, getUserFromCtx(r).ID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	// one extra entry tells whether there is a next page
+	q.Limit++
+
+	entries, err := list(ctx, userID, getUserFromCtx(r).ID, q)
+	if err != nil {
+		switch err {
+		case store.ErrInvalidCursor:
+			app.badRequestResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	page := FollowPage{Users: entries}
+	if len(entries) == q.Limit {
+		page.Users = entries[:len(entries)-1]
+		page.NextCursor = store.FollowCursor(page.Users[len(page.Users)-1])
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, page); err != nil {
 		app.internalServerError(w, r, err)
 	}
 }
@@ -54,14 +195,16 @@ func (app *application) getUserHandler(w http.ResponseWriter, r *http.Request) {
 // FollowUser godoc
 //
 //	@Summary		Follow a user
-//	@Description	Follow a user ID
+//	@Description	Follow a user ID, following a private account sends it a follow request instead
 //	@Tags			users
 //	@Accept			json
 //	@Produce		json
 //	@Param			userID	path		int		true	"User ID"
 //	@Success		204		{string}	string	"User followed"
+//	@Success		202		{string}	string	"Follow request sent"
 //	@Failure		400		{object}	error"user payload missing"
 //	@Failure		404		{object}	error"user not found"
+//	@Failure		409		{object}	error"already followed or requested"
 //	@Security		ApiKeyAuth
 //	@Router			/users/{userID}/follow [put]
 func (app *application) followUserHandler(w http.ResponseWriter, r *http.Request) {
@@ -75,11 +218,15 @@ func (app *application) followUserHandler(w http.ResponseWriter, r *http.Request
 
 	ctx := r.Context()
 
-	if err := app.store.Followers.Follow(ctx, followerUser.ID, followedID); err != nil {
+	requested, err := app.store.Followers.Follow(ctx, followerUser.ID, followedID)
+	if err != nil {
 		switch err {
 		case store.ErrorConflict:
 			app.conflictResponse(w, r, err)
 			return
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+			return
 		default:
 			app.internalServerError(w, r, err)
 			return
@@ -87,6 +234,15 @@ func (app *application) followUserHandler(w http.ResponseWriter, r *http.Request
 
 	}
 
+	app.forgetSuggestions(ctx, followerUser.ID)
+
+	if requested {
+		if err := app.jsonResponse(w, http.StatusAccepted, "the account is private, a follow request has been sent"); err != nil {
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
 	if err := app.jsonResponse(w, http.StatusNoContent, nil); err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -97,7 +253,7 @@ func (app *application) followUserHandler(w http.ResponseWriter, r *http.Request
 // UnfollowUser godoc
 //
 //	@Summary		Unfollow a user
-//	@Description	Unfollow a user ID
+//	@Description	Unfollow a user ID, or withdraw a follow request
 //	@Tags			users
 //	@Accept			json
 //	@Produce		json
@@ -123,6 +279,8 @@ func (app *application) unfollowUserHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	app.forgetSuggestions(ctx, followerUser.ID)
+
 	if err := app.jsonResponse(w, http.StatusNoContent, nil); err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -139,7 +297,8 @@ func (app *application) unfollowUserHandler(w http.ResponseWriter, r *http.Reque
 //	@Param			token	path		string	true	"Invitation token"
 //
 //	@success		204		{string}	string	"User Activated"
-//	@failure		404		{object}	error
+//	@failure		404		{object}	error	"Unknown token"
+//	@failure		410		{object}	error	"Expired token"
 //	@failure		500		{object}	error
 //	@Security		ApiKeyAuth
 //	@Router			/users/activate/{token} [put]
@@ -153,6 +312,8 @@ func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Reque
 		switch err {
 		case store.ErrNotFound:
 			app.notFoundResponse(w, r, err)
+		case store.ErrTokenExpired:
+			app.goneResponse(w, r, errors.New("activation link has expired, request a new one"))
 		default:
 			app.internalServerError(w, r, err)
 		}
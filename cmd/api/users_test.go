@@ -2,6 +2,7 @@ package main
 
 import (
 	"net/http"
+	"strings"
 	"testing"
 
 	"social/internal/store/cache"
@@ -106,3 +107,126 @@ func TestGetUser(t *testing.T) {
 		mockCacheStore.Calls = nil // Reset mock expectations
 	})
 }
+
+func TestActivateUser(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+
+	tests := []struct {
+		name  string
+		token string
+		code  int
+	}{
+		{"should activate the user", "valid", http.StatusNoContent},
+		{"should tell an expired invitation apart", "expired", http.StatusGone},
+		{"should not find an unknown invitation", "unknown", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPut, "/v1/users/activate/"+tt.token, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			checkResponseCode(t, tt.code, executeRequest(req, mux).Code)
+		})
+	}
+}
+
+func TestFollowLists(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should list followers", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/users/2/followers", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+testToken)
+
+		checkResponseCode(t, http.StatusOK, executeRequest(req, mux).Code)
+	})
+
+	t.Run("should reject an out of range limit", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/users/2/following?limit=0", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+testToken)
+
+		checkResponseCode(t, http.StatusBadRequest, executeRequest(req, mux).Code)
+	})
+}
+
+func TestPrivateAccounts(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should send a follow request to a private account", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPut, "/v1/users/3/follow", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+testToken)
+
+		checkResponseCode(t, http.StatusAccepted, executeRequest(req, mux).Code)
+	})
+
+	t.Run("should hide the posts of a private account", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/posts/3", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+testToken)
+
+		checkResponseCode(t, http.StatusNotFound, executeRequest(req, mux).Code)
+	})
+
+	t.Run("should show the posts of a public account", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/posts/2", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+testToken)
+
+		checkResponseCode(t, http.StatusOK, executeRequest(req, mux).Code)
+	})
+
+	t.Run("should not find a request that was never sent", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPut, "/v1/users/me/follow-requests/2/reject", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+testToken)
+
+		checkResponseCode(t, http.StatusNotFound, executeRequest(req, mux).Code)
+	})
+
+	t.Run("should require a privacy setting", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPut, "/v1/users/me/privacy", strings.NewReader(`{}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+testToken)
+
+		checkResponseCode(t, http.StatusBadRequest, executeRequest(req, mux).Code)
+	})
+}
@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS passkeys (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL,
+    credential_id bytea NOT NULL UNIQUE,
+    name VARCHAR(100) NOT NULL DEFAULT '',
+    sign_count bigint NOT NULL DEFAULT 0,
+    credential jsonb NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    last_used_at timestamp(0) with time zone,
+
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_passkeys_user_id ON passkeys (user_id);
+
+CREATE TABLE IF NOT EXISTS passkey_sessions (
+    challenge text PRIMARY KEY,
+    user_id bigint,
+    data jsonb NOT NULL,
+    expiry timestamp(0) with time zone NOT NULL,
+
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
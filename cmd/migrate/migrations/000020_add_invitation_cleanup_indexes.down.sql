@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_users_inactive_created_at;
+
+DROP INDEX IF EXISTS idx_user_invitations_expiry;
+
+DROP INDEX IF EXISTS idx_user_invitations_user_id;
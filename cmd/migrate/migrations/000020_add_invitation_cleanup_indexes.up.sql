@@ -0,0 +1,5 @@
+CREATE INDEX IF NOT EXISTS idx_user_invitations_user_id ON user_invitations (user_id);
+
+CREATE INDEX IF NOT EXISTS idx_user_invitations_expiry ON user_invitations (expiry);
+
+CREATE INDEX IF NOT EXISTS idx_users_inactive_created_at ON users (created_at) WHERE is_active = false;
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS email_changes (
+    token bytea PRIMARY KEY,
+    revert_token bytea NOT NULL UNIQUE,
+    user_id bigint NOT NULL,
+    old_email citext NOT NULL,
+    new_email citext NOT NULL,
+    expiry timestamp(0) with time zone NOT NULL,
+    revert_expiry timestamp(0) with time zone NOT NULL,
+    confirmed_at timestamp(0) with time zone,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_email_changes_user_id ON email_changes (user_id);
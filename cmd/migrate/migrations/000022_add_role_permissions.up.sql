@@ -0,0 +1,36 @@
+CREATE TABLE IF NOT EXISTS permissions (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL UNIQUE,
+    description TEXT
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role_id bigint NOT NULL,
+    permission_id bigint NOT NULL,
+
+    PRIMARY KEY (role_id, permission_id),
+    FOREIGN KEY (role_id) REFERENCES roles(id) ON DELETE CASCADE,
+    FOREIGN KEY (permission_id) REFERENCES permissions(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_role_permissions_permission_id ON role_permissions (permission_id);
+
+INSERT INTO
+    permissions (name, description)
+VALUES
+    ('posts:update', 'Update posts of other users'),
+    ('posts:delete', 'Delete posts of other users'),
+    ('comments:hide', 'Hide comments of other users'),
+    ('comments:delete', 'Delete comments of other users'),
+    ('users:delete', 'Delete user accounts');
+
+-- the seeded roles keep what their levels used to allow
+INSERT INTO
+    role_permissions (role_id, permission_id)
+SELECT
+    roles.id, permissions.id
+FROM
+    roles, permissions
+WHERE
+    (roles.name = 'moderator' AND permissions.name IN ('posts:update', 'comments:hide'))
+    OR roles.name = 'admin';
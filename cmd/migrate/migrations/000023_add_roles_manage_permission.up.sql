@@ -0,0 +1,13 @@
+INSERT INTO
+    permissions (name, description)
+VALUES
+    ('roles:manage', 'Manage roles and assign them to users');
+
+INSERT INTO
+    role_permissions (role_id, permission_id)
+SELECT
+    roles.id, permissions.id
+FROM
+    roles, permissions
+WHERE
+    roles.name = 'admin' AND permissions.name = 'roles:manage';
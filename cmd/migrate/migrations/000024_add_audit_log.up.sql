@@ -0,0 +1,45 @@
+CREATE TABLE IF NOT EXISTS audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    -- no foreign keys, entries outlive the users and targets they mention
+    actor_id bigint NOT NULL,
+    action VARCHAR(64) NOT NULL,
+    target_type VARCHAR(64) NOT NULL,
+    target_id bigint NOT NULL,
+    before jsonb,
+    after jsonb,
+    request_id TEXT NOT NULL DEFAULT '',
+    ip TEXT NOT NULL DEFAULT '',
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_audit_log_actor_id ON audit_log (actor_id, id);
+CREATE INDEX idx_audit_log_target ON audit_log (target_type, target_id, id);
+CREATE INDEX idx_audit_log_action ON audit_log (action, id);
+
+CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_log_no_change
+BEFORE UPDATE OR DELETE ON audit_log
+FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
+
+CREATE TRIGGER audit_log_no_truncate
+BEFORE TRUNCATE ON audit_log
+FOR EACH STATEMENT EXECUTE FUNCTION audit_log_append_only();
+
+INSERT INTO
+    permissions (name, description)
+VALUES
+    ('audit:read', 'Read and export the audit log');
+
+INSERT INTO
+    role_permissions (role_id, permission_id)
+SELECT
+    roles.id, permissions.id
+FROM
+    roles, permissions
+WHERE
+    roles.name = 'admin' AND permissions.name = 'audit:read';
@@ -0,0 +1,31 @@
+CREATE TABLE IF NOT EXISTS impersonations (
+    -- the jti claim of the impersonation token
+    id uuid PRIMARY KEY,
+    actor_id bigint NOT NULL,
+    user_id bigint NOT NULL,
+    reason TEXT NOT NULL,
+    write BOOLEAN NOT NULL DEFAULT false,
+    expiry timestamp(0) with time zone NOT NULL,
+    ended_at timestamp(0) with time zone,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+    FOREIGN KEY (actor_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_impersonations_actor_id ON impersonations (actor_id);
+CREATE INDEX idx_impersonations_user_id ON impersonations (user_id);
+
+INSERT INTO
+    permissions (name, description)
+VALUES
+    ('users:impersonate', 'Act as another user to reproduce what they see');
+
+INSERT INTO
+    role_permissions (role_id, permission_id)
+SELECT
+    roles.id, permissions.id
+FROM
+    roles, permissions
+WHERE
+    roles.name = 'admin' AND permissions.name = 'users:impersonate';
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_sessions_last_seen_at;
+
+DROP INDEX IF EXISTS idx_posts_created_at;
@@ -0,0 +1,4 @@
+-- who-to-follow suggestions look at recent posts and recently seen users
+CREATE INDEX IF NOT EXISTS idx_posts_created_at ON posts (created_at);
+
+CREATE INDEX IF NOT EXISTS idx_sessions_last_seen_at ON sessions (last_seen_at) WHERE revoked_at IS NULL;
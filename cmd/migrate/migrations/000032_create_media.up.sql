@@ -0,0 +1,28 @@
+CREATE TABLE IF NOT EXISTS media (
+    id uuid PRIMARY KEY,
+    user_id bigint NOT NULL,
+    content_type VARCHAR(50) NOT NULL,
+    size bigint NOT NULL,
+    width int NOT NULL,
+    height int NOT NULL,
+    -- blob key of the original and of every variant, by name
+    files jsonb NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_media_user_id ON media (user_id);
+
+CREATE TABLE IF NOT EXISTS post_media (
+    post_id bigint NOT NULL,
+    media_id uuid NOT NULL,
+    position int NOT NULL,
+    alt_text VARCHAR(1000) NOT NULL DEFAULT '',
+
+    PRIMARY KEY (post_id, media_id),
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+    FOREIGN KEY (media_id) REFERENCES media(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_post_media_media_id ON post_media (media_id);
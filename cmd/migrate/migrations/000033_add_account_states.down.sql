@@ -0,0 +1,9 @@
+DELETE FROM permissions WHERE name = 'users:moderate';
+
+DROP INDEX IF EXISTS idx_users_suspended_until;
+
+ALTER TABLE users
+DROP CONSTRAINT IF EXISTS users_suspended_until_check,
+DROP COLUMN IF EXISTS suspended_until,
+DROP COLUMN IF EXISTS state_reason,
+DROP COLUMN IF EXISTS account_state;
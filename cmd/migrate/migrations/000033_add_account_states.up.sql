@@ -0,0 +1,25 @@
+ALTER TABLE users
+ADD COLUMN IF NOT EXISTS account_state VARCHAR(20) NOT NULL DEFAULT 'active'
+    CHECK (account_state IN ('active', 'suspended', 'banned')),
+ADD COLUMN IF NOT EXISTS state_reason VARCHAR(500) NOT NULL DEFAULT '',
+-- a suspension lapses on its own once this passes
+ADD COLUMN IF NOT EXISTS suspended_until timestamp(0) with time zone,
+ADD CONSTRAINT users_suspended_until_check
+    CHECK ((account_state = 'suspended') = (suspended_until IS NOT NULL));
+
+CREATE INDEX idx_users_suspended_until ON users (suspended_until)
+WHERE account_state = 'suspended';
+
+INSERT INTO
+    permissions (name, description)
+VALUES
+    ('users:moderate', 'Suspend, ban and reinstate user accounts');
+
+INSERT INTO
+    role_permissions (role_id, permission_id)
+SELECT
+    roles.id, permissions.id
+FROM
+    roles, permissions
+WHERE
+    roles.name IN ('moderator', 'admin') AND permissions.name = 'users:moderate';
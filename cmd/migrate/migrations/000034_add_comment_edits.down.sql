@@ -0,0 +1,8 @@
+-- the moderator grant of comments:delete is left, an admin may have made it
+-- before the up migration did
+
+CREATE INDEX IF NOT EXISTS idx_comments_post_id ON comments (post_id);
+DROP INDEX IF EXISTS idx_comments_post_id_created_at;
+
+ALTER TABLE comments
+DROP COLUMN IF EXISTS edited_at;
@@ -0,0 +1,17 @@
+ALTER TABLE comments
+ADD COLUMN IF NOT EXISTS edited_at timestamp(0) with time zone;
+
+-- keyset pagination of the comments of a post, read either way
+CREATE INDEX IF NOT EXISTS idx_comments_post_id_created_at ON comments (post_id, created_at, id);
+DROP INDEX IF EXISTS idx_comments_post_id;
+
+-- moderators remove the comments of other users
+INSERT INTO
+    role_permissions (role_id, permission_id)
+SELECT
+    roles.id, permissions.id
+FROM
+    roles, permissions
+WHERE
+    roles.name = 'moderator' AND permissions.name = 'comments:delete'
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_comments_parent_id_created_at;
+
+-- replies have nothing to hang from once flattened
+DELETE FROM comments WHERE deleted_at IS NOT NULL;
+
+ALTER TABLE comments
+DROP COLUMN IF EXISTS deleted_at,
+DROP COLUMN IF EXISTS parent_id;
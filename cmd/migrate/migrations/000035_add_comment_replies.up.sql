@@ -0,0 +1,8 @@
+ALTER TABLE comments
+ADD COLUMN IF NOT EXISTS parent_id bigint REFERENCES comments(id) ON DELETE CASCADE,
+-- a deleted comment with replies stays as a placeholder so they keep their place
+ADD COLUMN IF NOT EXISTS deleted_at timestamp(0) with time zone;
+
+-- replies are read by parent, oldest first
+CREATE INDEX IF NOT EXISTS idx_comments_parent_id_created_at ON comments (parent_id, created_at, id)
+WHERE parent_id IS NOT NULL;
@@ -0,0 +1,18 @@
+INSERT INTO
+    permissions (name, description)
+VALUES
+    ('comments:hide', 'Hide comments of other users'),
+    ('users:delete', 'Delete user accounts')
+ON CONFLICT DO NOTHING;
+
+-- the moderator grant of comments:delete is left, it may predate the up migration
+INSERT INTO
+    role_permissions (role_id, permission_id)
+SELECT
+    roles.id, permissions.id
+FROM
+    roles, permissions
+WHERE
+    (roles.name = 'moderator' AND permissions.name = 'comments:hide')
+    OR (roles.name = 'admin' AND permissions.name IN ('comments:hide', 'users:delete'))
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,13 @@
+-- nothing checks these, the grants did nothing
+DELETE FROM permissions WHERE name IN ('comments:hide', 'users:delete');
+
+-- moderators that could hide comments moderate them by deleting them
+INSERT INTO
+    role_permissions (role_id, permission_id)
+SELECT
+    roles.id, permissions.id
+FROM
+    roles, permissions
+WHERE
+    roles.name = 'moderator' AND permissions.name = 'comments:delete'
+ON CONFLICT DO NOTHING;
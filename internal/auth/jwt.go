@@ -7,16 +7,30 @@ import (
 )
 
 type JWTAuthenticator struct {
-	secret string // keep it secret
-	aud    string // audience
-	iss    string // issuer
+	secret string  // keep it secret
+	aud    string  // audience
+	iss    string  // issuer
+	keys   *KeySet // when set, tokens are signed asymmetrically instead of with the secret
 }
 
 func NewJWTAuthenticator(secret, aud, iss string) *JWTAuthenticator {
-	return &JWTAuthenticator{secret, aud, iss}
+	return &JWTAuthenticator{secret: secret, aud: aud, iss: iss}
+}
+
+func NewJWTKeySetAuthenticator(keys *KeySet, aud, iss string) *JWTAuthenticator {
+	return &JWTAuthenticator{keys: keys, aud: aud, iss: iss}
 }
 
 func (a *JWTAuthenticator) GenerateToken(claims jwt.Claims) (string, error) {
+	if a.keys != nil {
+		key := a.keys.SigningKey()
+
+		token := jwt.NewWithClaims(key.Method, claims)
+		token.Header["kid"] = key.ID
+
+		return token.SignedString(key.Private)
+	}
+
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	tokenString, err := token.SignedString([]byte(a.secret))
 	if err != nil {
@@ -26,6 +40,15 @@ func (a *JWTAuthenticator) GenerateToken(claims jwt.Claims) (string, error) {
 }
 
 func (a *JWTAuthenticator) ValidateToken(token string) (*jwt.Token, error) {
+	if a.keys != nil {
+		return jwt.Parse(token, a.keyFunc,
+			jwt.WithExpirationRequired(),
+			jwt.WithAudience(a.aud),
+			jwt.WithIssuer(a.iss),
+			jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}),
+		)
+	}
+
 	return jwt.Parse(token, func(t *jwt.Token) (any, error) {
 		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
@@ -39,3 +62,27 @@ func (a *JWTAuthenticator) ValidateToken(token string) (*jwt.Token, error) {
 		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Name}),
 	)
 }
+
+// JWKS returns the public keys that validate tokens, empty when using a shared secret.
+func (a *JWTAuthenticator) JWKS() *JSONWebKeySet {
+	if a.keys == nil {
+		return &JSONWebKeySet{Keys: []JSONWebKey{}}
+	}
+
+	return a.keys.JWKS()
+}
+
+func (a *JWTAuthenticator) keyFunc(t *jwt.Token) (any, error) {
+	kid, _ := t.Header["kid"].(string)
+
+	key, ok := a.keys.Get(kid)
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key: %q", kid)
+	}
+
+	if t.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %v for key %q", t.Header["alg"], kid)
+	}
+
+	return key.Public, nil
+}
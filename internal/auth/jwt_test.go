@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func writePrivateKey(t *testing.T, dir, kid string, key any) {
+	t.Helper()
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(filepath.Join(dir, kid+".pem"), data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func claims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"sub": 1,
+		"exp": time.Now().Add(time.Hour).Unix(),
+		"iss": "test",
+		"aud": "test",
+	}
+}
+
+func TestKeySetRotation(t *testing.T) {
+	dir := t.TempDir()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePrivateKey(t, dir, "2025-01-01", rsaKey)
+
+	keys, err := LoadKeySet(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authenticator := NewJWTKeySetAuthenticator(keys, "test", "test")
+
+	oldToken, err := authenticator.GenerateToken(claims())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePrivateKey(t, dir, "2025-02-01", edKey)
+
+	if err := keys.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should sign with the newest key", func(t *testing.T) {
+		token, err := authenticator.GenerateToken(claims())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		parsed, err := authenticator.ValidateToken(token)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if parsed.Header["kid"] != "2025-02-01" || parsed.Method.Alg() != "EdDSA" {
+			t.Errorf("expected an EdDSA token signed by 2025-02-01, got %v %v", parsed.Method.Alg(), parsed.Header["kid"])
+		}
+	})
+
+	t.Run("should keep validating tokens of the previous key", func(t *testing.T) {
+		if _, err := authenticator.ValidateToken(oldToken); err != nil {
+			t.Errorf("expected the old token to validate, got %v", err)
+		}
+	})
+
+	t.Run("should publish both keys", func(t *testing.T) {
+		set := authenticator.JWKS()
+		if len(set.Keys) != 2 || set.Keys[0].KeyType != "RSA" || set.Keys[1].KeyType != "OKP" {
+			t.Errorf("unexpected key set %+v", set.Keys)
+		}
+	})
+
+	t.Run("should reject a token signed with a removed key", func(t *testing.T) {
+		if err := os.Remove(filepath.Join(dir, "2025-01-01.pem")); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := keys.Reload(); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := authenticator.ValidateToken(oldToken); err == nil {
+			t.Error("expected the token of the removed key to be rejected")
+		}
+	})
+}
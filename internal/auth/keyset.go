@@ -0,0 +1,219 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var ErrNoSigningKey = errors.New("key set has no signing key")
+
+// Key is a single entry of a KeySet. Keys that were loaded from a public key
+// file have no private part and can only be used to validate tokens.
+type Key struct {
+	ID      string
+	Method  jwt.SigningMethod
+	Private crypto.Signer
+	Public  crypto.PublicKey
+}
+
+// KeySet holds every key that may still validate tokens, indexed by kid, and
+// the kid of the one used to sign new tokens.
+//
+// Keys are loaded from a directory of PEM files named <kid>.pem. To rotate, add
+// the new private key, point the signing kid to it and keep the old file around
+// until the last token it signed has expired.
+type KeySet struct {
+	sync.RWMutex
+	dir        string
+	kid        string // configured signing kid, empty to pick the newest key
+	signingKID string
+	keys       map[string]*Key
+}
+
+func LoadKeySet(dir, signingKID string) (*KeySet, error) {
+	ks := &KeySet{
+		dir: dir,
+		kid: signingKID,
+	}
+
+	if err := ks.Reload(); err != nil {
+		return nil, err
+	}
+
+	return ks, nil
+}
+
+// Reload re-reads the key directory. The current keys are kept if the directory
+// can't be loaded.
+func (ks *KeySet) Reload() error {
+	files, err := filepath.Glob(filepath.Join(ks.dir, "*.pem"))
+	if err != nil {
+		return err
+	}
+
+	keys := make(map[string]*Key, len(files))
+	for _, file := range files {
+		kid := strings.TrimSuffix(filepath.Base(file), ".pem")
+
+		key, err := loadKey(kid, file)
+		if err != nil {
+			return fmt.Errorf("loading key %q: %w", kid, err)
+		}
+
+		keys[kid] = key
+	}
+
+	signingKID := ks.kid
+	if signingKID == "" {
+		// without an explicit kid, sign with the newest private key assuming
+		// kids sort by creation, e.g. 2025-01-01.pem
+		kids := make([]string, 0, len(keys))
+		for kid, key := range keys {
+			if key.Private != nil {
+				kids = append(kids, kid)
+			}
+		}
+		sort.Strings(kids)
+
+		if len(kids) > 0 {
+			signingKID = kids[len(kids)-1]
+		}
+	}
+
+	signing, ok := keys[signingKID]
+	if !ok || signing.Private == nil {
+		return ErrNoSigningKey
+	}
+
+	ks.Lock()
+	ks.keys = keys
+	ks.signingKID = signingKID
+	ks.Unlock()
+
+	return nil
+}
+
+func (ks *KeySet) SigningKey() *Key {
+	ks.RLock()
+	defer ks.RUnlock()
+
+	return ks.keys[ks.signingKID]
+}
+
+func (ks *KeySet) Get(kid string) (*Key, bool) {
+	ks.RLock()
+	defer ks.RUnlock()
+
+	key, ok := ks.keys[kid]
+	return key, ok
+}
+
+// JWKS returns the public part of every key in the set, ordered by kid.
+func (ks *KeySet) JWKS() *JSONWebKeySet {
+	ks.RLock()
+	defer ks.RUnlock()
+
+	set := &JSONWebKeySet{Keys: []JSONWebKey{}}
+	for _, key := range ks.keys {
+		set.Keys = append(set.Keys, key.JWK())
+	}
+
+	sort.Slice(set.Keys, func(i, j int) bool {
+		return set.Keys[i].KeyID < set.Keys[j].KeyID
+	})
+
+	return set
+}
+
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+type JSONWebKey struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+}
+
+func (k *Key) JWK() JSONWebKey {
+	jwk := JSONWebKey{
+		KeyID:     k.ID,
+		Use:       "sig",
+		Algorithm: k.Method.Alg(),
+	}
+
+	switch pub := k.Public.(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+	}
+
+	return jwk
+}
+
+func loadKey(kid, file string) (*Key, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var parsed any
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key := &Key{ID: kid}
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.Method, key.Private, key.Public = jwt.SigningMethodRS256, k, &k.PublicKey
+	case ed25519.PrivateKey:
+		key.Method, key.Private, key.Public = jwt.SigningMethodEdDSA, k, k.Public()
+	case *rsa.PublicKey:
+		key.Method, key.Public = jwt.SigningMethodRS256, k
+	case ed25519.PublicKey:
+		key.Method, key.Public = jwt.SigningMethodEdDSA, k
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", parsed)
+	}
+
+	return key, nil
+}
@@ -14,11 +14,18 @@ var testClaims = jwt.MapClaims{
 	"aud": "test-aud",
 	"iss": "test-aud",
 	"sub": int64(1),
+	"jti": "6f1c2b8e-3d4a-4c5b-9e7f-0a1b2c3d4e5f",
 	"exp": time.Now().Add(time.Hour).Unix(),
 }
 
+// GenerateToken signs the claims it is given, or the fixed test claims when
+// there are none.
 func (a *TestAuthenticator) GenerateToken(claims jwt.Claims) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims)
+	if claims == nil {
+		claims = testClaims
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 
 	tokenString, _ := token.SignedString([]byte(secret))
 	return tokenString, nil
@@ -29,3 +36,7 @@ func (a *TestAuthenticator) ValidateToken(token string) (*jwt.Token, error) {
 		return []byte(secret), nil
 	})
 }
+
+func (a *TestAuthenticator) JWKS() *JSONWebKeySet {
+	return &JSONWebKeySet{Keys: []JSONWebKey{}}
+}
@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHOTP(t *testing.T) {
+	// RFC 6238 appendix B, SHA1
+	key := []byte("12345678901234567890")
+
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1234567890, "89005924"},
+		{20000000000, "65353130"},
+	}
+
+	for _, tt := range tests {
+		got := hotp(key, uint64(tt.unix/30), 8)
+		if got != tt.want {
+			t.Errorf("hotp at %d: expected %s, got %s", tt.unix, tt.want, got)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, _ := b32.DecodeString(secret)
+	now := time.Unix(1700000000, 0)
+	step := now.Unix() / 30
+
+	t.Run("should accept the current and adjacent codes", func(t *testing.T) {
+		for _, s := range []int64{step - 1, step, step + 1} {
+			got, ok := ValidateTOTP(secret, hotp(key, uint64(s), TOTPDigits), now)
+			if !ok || got != s {
+				t.Errorf("expected step %d to validate, got %d %v", s, got, ok)
+			}
+		}
+	})
+
+	t.Run("should reject codes outside the skew", func(t *testing.T) {
+		if _, ok := ValidateTOTP(secret, hotp(key, uint64(step+2), TOTPDigits), now); ok {
+			t.Error("expected a code two steps ahead to be rejected")
+		}
+	})
+
+	t.Run("should build a provisioning uri", func(t *testing.T) {
+		uri := TOTPProvisioningURI(secret, "GopherSocial", "gopher@example.com")
+		if !strings.HasPrefix(uri, "otpauth://totp/GopherSocial:gopher@example.com?") || !strings.Contains(uri, "secret="+secret) {
+			t.Errorf("unexpected uri %s", uri)
+		}
+	})
+}
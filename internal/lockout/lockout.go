@@ -0,0 +1,39 @@
+package lockout
+
+import (
+	"context"
+	"time"
+)
+
+// Tracker counts failed attempts per key and locks the key out once they
+// exceed the configured budget.
+type Tracker interface {
+	// Locked returns how long the key stays locked, 0 when it isn't.
+	Locked(ctx context.Context, key string) (time.Duration, error)
+	// Fail records a failed attempt, it returns the failures counted so far
+	// and the lockout they triggered, if any.
+	Fail(ctx context.Context, key string) (int, time.Duration, error)
+	// Reset forgets the failures of the key and lifts its lockout.
+	Reset(ctx context.Context, key string) error
+}
+
+type Config struct {
+	MaxAttempts int           // failures allowed before the first lockout, 0 disables it
+	BaseDelay   time.Duration // first lockout, doubled by every failure after it
+	MaxDelay    time.Duration
+	Window      time.Duration // failures are forgotten after this long without a new one
+}
+
+// LockoutFor is the lockout earned by a number of consecutive failures.
+func (c Config) LockoutFor(failures int) time.Duration {
+	if c.MaxAttempts <= 0 || failures < c.MaxAttempts {
+		return 0
+	}
+
+	delay := c.BaseDelay
+	for i := c.MaxAttempts; i < failures && delay < c.MaxDelay; i++ {
+		delay *= 2
+	}
+
+	return min(delay, c.MaxDelay)
+}
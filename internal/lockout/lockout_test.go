@@ -0,0 +1,60 @@
+package lockout
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLockoutFor(t *testing.T) {
+	cfg := Config{MaxAttempts: 3, BaseDelay: time.Minute, MaxDelay: time.Minute * 10}
+
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{2, 0},
+		{3, time.Minute},
+		{4, time.Minute * 2},
+		{5, time.Minute * 4},
+		{7, time.Minute * 10},
+		{1000, time.Minute * 10},
+	}
+
+	for _, tt := range tests {
+		if got := cfg.LockoutFor(tt.failures); got != tt.want {
+			t.Errorf("LockoutFor(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+
+	if got := (Config{}).LockoutFor(100); got != 0 {
+		t.Errorf("expected a zero config to never lock, got %v", got)
+	}
+}
+
+func TestMemoryTracker(t *testing.T) {
+	ctx := context.Background()
+	tracker := NewMemoryTracker(Config{MaxAttempts: 2, BaseDelay: time.Minute, MaxDelay: time.Hour, Window: time.Hour})
+
+	if _, lockout, _ := tracker.Fail(ctx, "a"); lockout != 0 {
+		t.Fatalf("expected no lockout after the first failure, got %v", lockout)
+	}
+
+	if _, lockout, _ := tracker.Fail(ctx, "a"); lockout != time.Minute {
+		t.Fatalf("expected a lockout of a minute, got %v", lockout)
+	}
+
+	if locked, _ := tracker.Locked(ctx, "a"); locked <= 0 {
+		t.Error("expected the key to be locked")
+	}
+
+	if locked, _ := tracker.Locked(ctx, "b"); locked != 0 {
+		t.Error("expected other keys not to be locked")
+	}
+
+	tracker.Reset(ctx, "a")
+
+	if locked, _ := tracker.Locked(ctx, "a"); locked != 0 {
+		t.Error("expected the reset to lift the lockout")
+	}
+}
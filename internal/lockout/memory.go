@@ -0,0 +1,93 @@
+package lockout
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryTracker keeps the counters in the process, for a single instance
+// deployment without Redis.
+type MemoryTracker struct {
+	mu       sync.Mutex
+	cfg      Config
+	attempts map[string]*attempts
+}
+
+type attempts struct {
+	failures    int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+func NewMemoryTracker(cfg Config) *MemoryTracker {
+	t := &MemoryTracker{
+		cfg:      cfg,
+		attempts: make(map[string]*attempts),
+	}
+
+	go t.sweep()
+
+	return t
+}
+
+func (t *MemoryTracker) Locked(ctx context.Context, key string) (time.Duration, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	a, ok := t.attempts[key]
+	if !ok {
+		return 0, nil
+	}
+
+	return max(time.Until(a.lockedUntil), 0), nil
+}
+
+func (t *MemoryTracker) Fail(ctx context.Context, key string) (int, time.Duration, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+
+	a, ok := t.attempts[key]
+	if !ok || now.Sub(a.lastFailure) > t.cfg.Window {
+		a = &attempts{}
+		t.attempts[key] = a
+	}
+
+	a.failures++
+	a.lastFailure = now
+
+	lockout := t.cfg.LockoutFor(a.failures)
+	if lockout > 0 {
+		a.lockedUntil = now.Add(lockout)
+	}
+
+	return a.failures, lockout, nil
+}
+
+func (t *MemoryTracker) Reset(ctx context.Context, key string) error {
+	t.mu.Lock()
+	delete(t.attempts, key)
+	t.mu.Unlock()
+
+	return nil
+}
+
+// sweep drops the keys whose failures are forgotten, so spraying the endpoint
+// with new keys doesn't grow the map forever.
+func (t *MemoryTracker) sweep() {
+	if t.cfg.Window <= 0 {
+		return
+	}
+
+	for range time.Tick(t.cfg.Window) {
+		t.mu.Lock()
+		for key, a := range t.attempts {
+			if time.Since(a.lastFailure) > t.cfg.Window && time.Now().After(a.lockedUntil) {
+				delete(t.attempts, key)
+			}
+		}
+		t.mu.Unlock()
+	}
+}
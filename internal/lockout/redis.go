@@ -0,0 +1,68 @@
+package lockout
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// RedisTracker shares the counters between every instance of the API.
+type RedisTracker struct {
+	rdb    *redis.Client
+	prefix string
+	cfg    Config
+}
+
+// NewRedisTracker namespaces its keys with prefix, trackers with different
+// configs can share the same Redis.
+func NewRedisTracker(rdb *redis.Client, prefix string, cfg Config) *RedisTracker {
+	return &RedisTracker{rdb: rdb, prefix: prefix, cfg: cfg}
+}
+
+func (t *RedisTracker) failuresKey(key string) string {
+	return fmt.Sprintf("lockout:%s:%s:failures", t.prefix, key)
+}
+
+func (t *RedisTracker) lockedKey(key string) string {
+	return fmt.Sprintf("lockout:%s:%s:locked", t.prefix, key)
+}
+
+func (t *RedisTracker) Locked(ctx context.Context, key string) (time.Duration, error) {
+	ttl, err := t.rdb.PTTL(ctx, t.lockedKey(key)).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	// negative when the key doesn't exist
+	return max(ttl, 0), nil
+}
+
+func (t *RedisTracker) Fail(ctx context.Context, key string) (int, time.Duration, error) {
+	var incr *redis.IntCmd
+
+	_, err := t.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		incr = pipe.Incr(ctx, t.failuresKey(key))
+		pipe.Expire(ctx, t.failuresKey(key), t.cfg.Window)
+		return nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	failures := int(incr.Val())
+
+	lockout := t.cfg.LockoutFor(failures)
+	if lockout > 0 {
+		if err := t.rdb.Set(ctx, t.lockedKey(key), failures, lockout).Err(); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	return failures, lockout, nil
+}
+
+func (t *RedisTracker) Reset(ctx context.Context, key string) error {
+	return t.rdb.Del(ctx, t.failuresKey(key), t.lockedKey(key)).Err()
+}
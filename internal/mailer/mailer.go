@@ -3,9 +3,13 @@ package mailer
 import "embed"
 
 const (
-	FromName            = "Social"
-	maxRetries          = 3
-	UserWelcomeTemplate = "user_invitation.tmpl"
+	FromName              = "Social"
+	maxRetries            = 3
+	UserWelcomeTemplate   = "user_invitation.tmpl"
+	PasswordResetTemplate = "password_reset.tmpl"
+	AccountLockedTemplate = "account_locked.tmpl"
+	EmailChangeTemplate   = "email_change_confirm.tmpl"
+	EmailNoticeTemplate   = "email_change_notice.tmpl"
 )
 
 //go:embed "templates"
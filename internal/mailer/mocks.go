@@ -0,0 +1,7 @@
+package mailer
+
+type MockClient struct{}
+
+func (m *MockClient) SendEmail(templateFile, username, email string, data any, isSandbox bool) error {
+	return nil
+}
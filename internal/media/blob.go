@@ -0,0 +1,47 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path"
+	"strings"
+)
+
+var (
+	ErrNotFound   = errors.New("blob not found")
+	ErrInvalidKey = errors.New("invalid blob key")
+)
+
+// BlobStore keeps the bytes of uploaded media. Keys are slash separated
+// relative paths, such as "<media id>/thumb.jpg".
+type BlobStore interface {
+	// Put stores r under key, replacing whatever was there. Content type is
+	// for stores that serve blobs themselves, like S3.
+	Put(ctx context.Context, key, contentType string, r io.Reader) error
+	// Get returns ErrNotFound for an unknown key.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete is a no-op for an unknown key.
+	Delete(ctx context.Context, key string) error
+	// URL is where clients fetch the blob from.
+	URL(key string) string
+}
+
+// validKey refuses keys that could escape the store, like "../users.db".
+func validKey(key string) bool {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, `\`) {
+		return false
+	}
+
+	if path.Clean(key) != key {
+		return false
+	}
+
+	for _, part := range strings.Split(key, "/") {
+		if part == ".." || part == "." {
+			return false
+		}
+	}
+
+	return true
+}
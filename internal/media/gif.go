@@ -0,0 +1,82 @@
+package media
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+var errMalformedGIF = errors.New("malformed GIF")
+
+// gifPixels adds up the pixels of the frames of a GIF by walking its blocks,
+// without decompressing them, so an animation can be refused before
+// gif.DecodeAll allocates every frame. It stops counting once past limit.
+func gifPixels(data []byte, limit int) (int, error) {
+	// header and logical screen descriptor
+	if len(data) < 13 {
+		return 0, errMalformedGIF
+	}
+
+	i := 13
+	if data[10]&0x80 != 0 {
+		i += 3 << (data[10]&0x07 + 1) // global color table
+	}
+
+	total := 0
+	for i < len(data) {
+		var err error
+
+		switch data[i] {
+		case 0x21: // extension, its label then its data
+			i, err = skipSubBlocks(data, i+2)
+		case 0x2C: // image descriptor
+			if i+10 > len(data) {
+				return 0, errMalformedGIF
+			}
+
+			width := int(binary.LittleEndian.Uint16(data[i+5:]))
+			height := int(binary.LittleEndian.Uint16(data[i+7:]))
+			flags := data[i+9]
+
+			total += width * height
+			if total > limit {
+				return total, nil
+			}
+
+			i += 10
+			if flags&0x80 != 0 {
+				i += 3 << (flags&0x07 + 1) // local color table
+			}
+
+			// the LZW minimum code size, then the compressed frame
+			i, err = skipSubBlocks(data, i+1)
+		case 0x3B: // trailer
+			return total, nil
+		default:
+			return 0, errMalformedGIF
+		}
+
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return total, nil
+}
+
+// skipSubBlocks returns where the data sub-blocks starting at i end.
+func skipSubBlocks(data []byte, i int) (int, error) {
+	for {
+		if i >= len(data) {
+			return 0, errMalformedGIF
+		}
+
+		size := int(data[i])
+		i++
+
+		if size == 0 {
+			return i, nil
+		}
+
+		i += size
+	}
+}
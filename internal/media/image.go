@@ -0,0 +1,240 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+)
+
+var (
+	ErrTooLarge        = errors.New("file is too large")
+	ErrUnsupportedType = errors.New("unsupported file type, upload a JPEG, PNG or GIF image")
+	ErrTooManyPixels   = errors.New("image dimensions are too large")
+)
+
+// MaxPixels caps the decoded size of an image, all its frames together for an
+// animated GIF. A tiny file can claim huge dimensions, or thousands of frames,
+// and exhaust memory when decoded.
+const MaxPixels = 40_000_000
+
+// Variant is a downscaled copy of an image that fits in MaxSide by MaxSide.
+type Variant struct {
+	Name    string
+	MaxSide int
+}
+
+// Variants are made for every uploaded image, largest first since each one is
+// scaled down from the previous.
+var Variants = []Variant{
+	{Name: "large", MaxSide: 1280},
+	{Name: "small", MaxSide: 480},
+	{Name: "thumb", MaxSide: 150},
+}
+
+// File is one encoded rendition of an upload.
+type File struct {
+	Name        string // "original" or a variant name
+	ContentType string
+	Ext         string
+	Data        []byte
+}
+
+// Image is a processed upload, every file has been re-encoded so no metadata
+// of the upload, EXIF included, survives.
+type Image struct {
+	ContentType string // of the original
+	Width       int
+	Height      int
+	Files       []File
+}
+
+var extensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+// ProcessImage reads an upload of at most maxBytes, sniffs its type rather
+// than trusting the client, and encodes the original and its Variants.
+func ProcessImage(r io.Reader, maxBytes int64) (*Image, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(data)) > maxBytes {
+		return nil, ErrTooLarge
+	}
+
+	contentType := http.DetectContentType(data)
+	if _, ok := extensions[contentType]; !ok {
+		return nil, ErrUnsupportedType
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedType
+	}
+
+	if cfg.Width*cfg.Height > MaxPixels {
+		return nil, ErrTooManyPixels
+	}
+
+	var (
+		src      *image.RGBA
+		original File
+	)
+
+	switch contentType {
+	case "image/gif":
+		// the canvas fits, but every frame of it is about to be decoded
+		pixels, err := gifPixels(data, MaxPixels)
+		if err != nil {
+			return nil, ErrUnsupportedType
+		}
+
+		if pixels > MaxPixels {
+			return nil, ErrTooManyPixels
+		}
+
+		// re-encoded frame by frame to keep the animation
+		g, err := gif.DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			return nil, ErrUnsupportedType
+		}
+
+		var buf bytes.Buffer
+		if err := gif.EncodeAll(&buf, g); err != nil {
+			return nil, err
+		}
+
+		original = File{Name: "original", ContentType: contentType, Ext: ".gif", Data: buf.Bytes()}
+
+		// a frame can cover only part of the canvas, the stills are of the
+		// first frame in place on the whole of it
+		src = image.NewRGBA(image.Rect(0, 0, cfg.Width, cfg.Height))
+		draw.Draw(src, g.Image[0].Bounds(), g.Image[0], g.Image[0].Bounds().Min, draw.Src)
+	default:
+		img, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, ErrUnsupportedType
+		}
+
+		// EXIF is about to be dropped, its orientation has to be applied first
+		src = toRGBA(img)
+		if contentType == "image/jpeg" {
+			src = orient(src, jpegOrientation(data))
+		}
+
+		original, err = encode("original", contentType, src)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	processed := &Image{
+		ContentType: contentType,
+		Width:       src.Bounds().Dx(),
+		Height:      src.Bounds().Dy(),
+		Files:       []File{original},
+	}
+
+	// animated variants aren't worth their size, they are stills
+	variantType := contentType
+	if variantType == "image/gif" {
+		variantType = "image/png"
+	}
+
+	for _, v := range Variants {
+		src = resize(src, v.MaxSide)
+
+		f, err := encode(v.Name, variantType, src)
+		if err != nil {
+			return nil, err
+		}
+		processed.Files = append(processed.Files, f)
+	}
+
+	return processed, nil
+}
+
+func encode(name, contentType string, img image.Image) (File, error) {
+	var buf bytes.Buffer
+
+	var err error
+	switch contentType {
+	case "image/jpeg":
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85})
+	default:
+		contentType = "image/png"
+		err = png.Encode(&buf, img)
+	}
+	if err != nil {
+		return File{}, err
+	}
+
+	return File{Name: name, ContentType: contentType, Ext: extensions[contentType], Data: buf.Bytes()}, nil
+}
+
+func toRGBA(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok && rgba.Bounds().Min == (image.Point{}) {
+		return rgba
+	}
+
+	b := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, b.Min, draw.Src)
+	return rgba
+}
+
+// resize scales src down to fit in maxSide by maxSide, averaging the source
+// pixels each destination pixel covers. Smaller images are returned as is.
+func resize(src *image.RGBA, maxSide int) *image.RGBA {
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	if w <= maxSide && h <= maxSide {
+		return src
+	}
+
+	nw, nh := maxSide, h*maxSide/w
+	if h > w {
+		nw, nh = w*maxSide/h, maxSide
+	}
+	nw, nh = max(nw, 1), max(nh, 1)
+
+	dst := image.NewRGBA(image.Rect(0, 0, nw, nh))
+
+	for y := 0; y < nh; y++ {
+		sy0, sy1 := y*h/nh, max((y+1)*h/nh, y*h/nh+1)
+
+		for x := 0; x < nw; x++ {
+			sx0, sx1 := x*w/nw, max((x+1)*w/nw, x*w/nw+1)
+
+			var r, g, b, a, n int
+			for sy := sy0; sy < sy1; sy++ {
+				i := src.PixOffset(sx0, sy)
+				for sx := sx0; sx < sx1; sx++ {
+					r += int(src.Pix[i])
+					g += int(src.Pix[i+1])
+					b += int(src.Pix[i+2])
+					a += int(src.Pix[i+3])
+					n++
+					i += 4
+				}
+			}
+
+			j := dst.PixOffset(x, y)
+			dst.Pix[j] = uint8(r / n)
+			dst.Pix[j+1] = uint8(g / n)
+			dst.Pix[j+2] = uint8(b / n)
+			dst.Pix[j+3] = uint8(a / n)
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,184 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"strings"
+	"testing"
+)
+
+// jpegWithOrientation encodes a w by h JPEG carrying an EXIF orientation.
+func jpegWithOrientation(t *testing.T, w, h, orientation int) []byte {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// big endian TIFF header with a single IFD entry
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08\x00\x01")
+	tiff = binary.BigEndian.AppendUint16(tiff, 0x0112)
+	tiff = binary.BigEndian.AppendUint16(tiff, 3) // SHORT
+	tiff = binary.BigEndian.AppendUint32(tiff, 1)
+	tiff = binary.BigEndian.AppendUint16(tiff, uint16(orientation))
+	tiff = append(tiff, 0, 0, 0, 0, 0, 0)
+
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	app1 := []byte{0xFF, 0xE1}
+	app1 = binary.BigEndian.AppendUint16(app1, uint16(len(segment)+2))
+	app1 = append(app1, segment...)
+
+	data := buf.Bytes()
+	return append(append([]byte{0xFF, 0xD8}, app1...), data[2:]...)
+}
+
+func TestProcessImage(t *testing.T) {
+	data := jpegWithOrientation(t, 300, 200, 6)
+
+	if o := jpegOrientation(data); o != 6 {
+		t.Fatalf("expected orientation 6, got %d", o)
+	}
+
+	img, err := ProcessImage(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a quarter turn swaps the sides
+	if img.Width != 200 || img.Height != 300 {
+		t.Errorf("expected 200x300, got %dx%d", img.Width, img.Height)
+	}
+
+	if len(img.Files) != len(Variants)+1 {
+		t.Fatalf("expected the original and %d variants, got %d files", len(Variants), len(img.Files))
+	}
+
+	for _, f := range img.Files {
+		if bytes.Contains(f.Data, []byte("Exif")) {
+			t.Errorf("expected EXIF stripped from %s", f.Name)
+		}
+	}
+
+	thumb, _, err := image.DecodeConfig(bytes.NewReader(img.Files[len(img.Files)-1].Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if thumb.Width != 100 || thumb.Height != 150 {
+		t.Errorf("expected a 100x150 thumbnail, got %dx%d", thumb.Width, thumb.Height)
+	}
+}
+
+func TestProcessImageRefusals(t *testing.T) {
+	data := jpegWithOrientation(t, 10, 10, 1)
+
+	if _, err := ProcessImage(bytes.NewReader(data), int64(len(data)-1)); err != ErrTooLarge {
+		t.Errorf("expected %v, got %v", ErrTooLarge, err)
+	}
+
+	if _, err := ProcessImage(strings.NewReader("<html>not an image</html>"), 1024); err != ErrUnsupportedType {
+		t.Errorf("expected %v, got %v", ErrUnsupportedType, err)
+	}
+}
+
+// gifWithFrames builds a GIF of w by h frames that hold no pixel data, the way
+// an upload can claim far more pixels than it carries.
+func gifWithFrames(w, h, frames int) []byte {
+	data := []byte("GIF89a")
+	data = binary.LittleEndian.AppendUint16(data, uint16(w))
+	data = binary.LittleEndian.AppendUint16(data, uint16(h))
+	data = append(data, 0x80, 0, 0)             // a global color table of 2 colors
+	data = append(data, 0, 0, 0, 255, 255, 255) // black and white
+
+	for range frames {
+		data = append(data, 0x2C, 0, 0, 0, 0)
+		data = binary.LittleEndian.AppendUint16(data, uint16(w))
+		data = binary.LittleEndian.AppendUint16(data, uint16(h))
+		data = append(data, 0)
+
+		// minimum code size 2, then a clear code and an end code
+		data = append(data, 2, 1, 0x2C, 0)
+	}
+
+	return append(data, 0x3B)
+}
+
+func TestProcessAnimatedImage(t *testing.T) {
+	t.Run("should refuse frames adding up to too many pixels", func(t *testing.T) {
+		// each 6000x6000 frame fits on its own, two don't
+		data := gifWithFrames(6000, 6000, 2)
+
+		if _, err := ProcessImage(bytes.NewReader(data), int64(len(data))); err != ErrTooManyPixels {
+			t.Errorf("expected %v, got %v", ErrTooManyPixels, err)
+		}
+	})
+
+	t.Run("should keep the frames of a small animation", func(t *testing.T) {
+		palette := color.Palette{color.Black, color.White}
+		g := &gif.GIF{}
+		for i := range 3 {
+			frame := image.NewPaletted(image.Rect(0, 0, 20, 10), palette)
+			frame.SetColorIndex(i, 0, 1)
+			g.Image = append(g.Image, frame)
+			g.Delay = append(g.Delay, 10)
+		}
+
+		var buf bytes.Buffer
+		if err := gif.EncodeAll(&buf, g); err != nil {
+			t.Fatal(err)
+		}
+
+		if pixels, err := gifPixels(buf.Bytes(), MaxPixels); err != nil || pixels != 3*20*10 {
+			t.Fatalf("expected %d pixels, got %d (%v)", 3*20*10, pixels, err)
+		}
+
+		img, err := ProcessImage(&buf, int64(buf.Len()))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		original, err := gif.DecodeAll(bytes.NewReader(img.Files[0].Data))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(original.Image) != 3 {
+			t.Errorf("expected 3 frames, got %d", len(original.Image))
+		}
+	})
+
+	t.Run("should measure the canvas, not the first frame", func(t *testing.T) {
+		palette := color.Palette{color.Black, color.White}
+		g := &gif.GIF{
+			Image:  []*image.Paletted{image.NewPaletted(image.Rect(5, 5, 15, 10), palette)},
+			Delay:  []int{10},
+			Config: image.Config{ColorModel: palette, Width: 40, Height: 30},
+		}
+
+		var buf bytes.Buffer
+		if err := gif.EncodeAll(&buf, g); err != nil {
+			t.Fatal(err)
+		}
+
+		img, err := ProcessImage(&buf, int64(buf.Len()))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if img.Width != 40 || img.Height != 30 {
+			t.Errorf("expected 40x30, got %dx%d", img.Width, img.Height)
+		}
+	})
+}
@@ -0,0 +1,50 @@
+package media
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestLocalStore(t *testing.T) {
+	ctx := context.Background()
+
+	s, err := NewLocalStore(t.TempDir(), "http://localhost:8080/v1/media/files/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Put(ctx, "abc/thumb.jpg", "image/jpeg", strings.NewReader("pixels")); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := s.Get(ctx, "abc/thumb.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(f)
+	f.Close()
+
+	if string(data) != "pixels" {
+		t.Errorf("expected pixels, got %q", data)
+	}
+
+	if url := s.URL("abc/thumb.jpg"); url != "http://localhost:8080/v1/media/files/abc/thumb.jpg" {
+		t.Errorf("unexpected url %s", url)
+	}
+
+	if err := s.Delete(ctx, "abc/thumb.jpg"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Get(ctx, "abc/thumb.jpg"); err != ErrNotFound {
+		t.Errorf("expected %v, got %v", ErrNotFound, err)
+	}
+
+	for _, key := range []string{"../escape", "/abs", "a/../../b", "a//b", ""} {
+		if err := s.Put(ctx, key, "", strings.NewReader("")); err != ErrInvalidKey {
+			t.Errorf("expected %q to be refused, got %v", key, err)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// AuditEntry records a privileged action, like a moderator editing someone
+// else's post. Before and After are JSON snapshots of the target, either may
+// be empty.
+type AuditEntry struct {
+	ID         int64           `json:"id"`
+	ActorID    int64           `json:"actor_id"`
+	Action     string          `json:"action"`
+	TargetType string          `json:"target_type"`
+	TargetID   int64           `json:"target_id"`
+	Before     json.RawMessage `json:"before,omitempty" swaggertype:"object"`
+	After      json.RawMessage `json:"after,omitempty" swaggertype:"object"`
+	RequestID  string          `json:"request_id"`
+	IP         string          `json:"ip"`
+	CreatedAt  string          `json:"created_at"`
+}
+
+// AuditQuery filters the audit log, newest entries first. Cursor is the ID of
+// the last entry of the previous page.
+type AuditQuery struct {
+	Limit      int        `json:"limit" validate:"gte=1,lte=100"`
+	Cursor     int64      `json:"cursor" validate:"gte=0"`
+	ActorID    int64      `json:"actor_id" validate:"gte=0"`
+	Action     string     `json:"action" validate:"max=64"`
+	TargetType string     `json:"target_type" validate:"max=64"`
+	TargetID   int64      `json:"target_id" validate:"gte=0"`
+	Since      *time.Time `json:"since"`
+	Until      *time.Time `json:"until"`
+}
+
+func (q AuditQuery) Parse(r *http.Request) (AuditQuery, error) {
+	qs := r.URL.Query()
+
+	ints := map[string]*int64{
+		"cursor":    &q.Cursor,
+		"actor_id":  &q.ActorID,
+		"target_id": &q.TargetID,
+	}
+	for name, dst := range ints {
+		if v := qs.Get(name); v != "" {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return q, err
+			}
+			*dst = n
+		}
+	}
+
+	if limit := qs.Get("limit"); limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return q, err
+		}
+		q.Limit = l
+	}
+
+	times := map[string]**time.Time{
+		"since": &q.Since,
+		"until": &q.Until,
+	}
+	for name, dst := range times {
+		if v := qs.Get(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return q, err
+			}
+			*dst = &t
+		}
+	}
+
+	q.Action = qs.Get("action")
+	q.TargetType = qs.Get("target_type")
+
+	return q, nil
+}
+
+type AuditStore struct {
+	db *sql.DB
+}
+
+func (s *AuditStore) Create(ctx context.Context, entry *AuditEntry) error {
+	query := `
+	INSERT INTO audit_log (actor_id, action, target_type, target_id, before, after, request_id, ip)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id, created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return s.db.QueryRowContext(
+		ctx,
+		query,
+		entry.ActorID,
+		entry.Action,
+		entry.TargetType,
+		entry.TargetID,
+		nullJSON(entry.Before),
+		nullJSON(entry.After),
+		entry.RequestID,
+		entry.IP,
+	).Scan(&entry.ID, &entry.CreatedAt)
+}
+
+func (s *AuditStore) Get(ctx context.Context, q AuditQuery) ([]AuditEntry, error) {
+	query := `
+	SELECT id, actor_id, action, target_type, target_id, before, after, request_id, ip, created_at
+	FROM audit_log
+	WHERE ($1 = 0 OR id < $1)
+		AND ($2 = 0 OR actor_id = $2)
+		AND ($3 = '' OR action = $3)
+		AND ($4 = '' OR target_type = $4)
+		AND ($5 = 0 OR target_id = $5)
+		AND ($6::timestamptz IS NULL OR created_at >= $6)
+		AND ($7::timestamptz IS NULL OR created_at <= $7)
+	ORDER BY id DESC
+	LIMIT $8
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(
+		ctx,
+		query,
+		q.Cursor,
+		q.ActorID,
+		q.Action,
+		q.TargetType,
+		q.TargetID,
+		q.Since,
+		q.Until,
+		q.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []AuditEntry{}
+	for rows.Next() {
+		var e AuditEntry
+		var before, after []byte
+		err := rows.Scan(
+			&e.ID,
+			&e.ActorID,
+			&e.Action,
+			&e.TargetType,
+			&e.TargetID,
+			&before,
+			&after,
+			&e.RequestID,
+			&e.IP,
+			&e.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		e.Before = before
+		e.After = after
+		entries = append(entries, e)
+	}
+
+	return entries, rows.Err()
+}
+
+// nullJSON stores an empty snapshot as NULL rather than invalid jsonb.
+func nullJSON(data json.RawMessage) any {
+	if len(data) == 0 {
+		return nil
+	}
+	return []byte(data)
+}
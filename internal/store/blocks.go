@@ -0,0 +1,212 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// RelatedUser is a user in a block or mute list.
+type RelatedUser struct {
+	ID       int64     `json:"id"`
+	Username string    `json:"username"`
+	Since    time.Time `json:"since"`
+}
+
+// BlockStore keeps the blocks and mutes between users. A block works both
+// ways: neither user can follow, comment on or look up the other. A mute only
+// keeps the muted user's posts out of the feed, and they aren't told.
+type BlockStore struct {
+	db *sql.DB
+}
+
+// blockedSQL is a condition that holds when either user has blocked the other.
+// Queries that need to respect blocks share it so they agree on what a block
+// hides.
+func blockedSQL(a, b string) string {
+	return `EXISTS (
+		SELECT 1 FROM blocks bl
+		WHERE (bl.user_id = ` + a + ` AND bl.blocked_id = ` + b + `)
+			OR (bl.user_id = ` + b + ` AND bl.blocked_id = ` + a + `)
+	)`
+}
+
+// Block blocks blockedID and drops the follows and follow requests between
+// the two users. Blocking twice is ErrorConflict, an unknown user ErrNotFound.
+func (s *BlockStore) Block(ctx context.Context, userID, blockedID int64) error {
+	return withTX(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		// Follow holds a share lock on the user it follows, so no follow can
+		// slip in between the block and the clean up
+		query := `SELECT id FROM users WHERE id IN ($1, $2) FOR NO KEY UPDATE`
+		if _, err := tx.ExecContext(ctx, query, userID, blockedID); err != nil {
+			return err
+		}
+
+		query = `INSERT INTO blocks (user_id, blocked_id) VALUES ($1, $2)`
+		if _, err := tx.ExecContext(ctx, query, userID, blockedID); err != nil {
+			return relationError(err)
+		}
+
+		query = `
+		DELETE FROM followers
+		WHERE (user_id = $1 AND follower_id = $2) OR (user_id = $2 AND follower_id = $1)
+		`
+		if _, err := tx.ExecContext(ctx, query, userID, blockedID); err != nil {
+			return err
+		}
+
+		query = `
+		DELETE FROM follow_requests
+		WHERE (user_id = $1 AND follower_id = $2) OR (user_id = $2 AND follower_id = $1)
+		`
+		_, err := tx.ExecContext(ctx, query, userID, blockedID)
+		return err
+	})
+}
+
+// Unblock doesn't bring back the follows the block dropped.
+func (s *BlockStore) Unblock(ctx context.Context, userID, blockedID int64) error {
+	return s.deleteRelation(ctx, `DELETE FROM blocks WHERE user_id = $1 AND blocked_id = $2`, userID, blockedID)
+}
+
+// GetBlocked lists the users userID has blocked, most recent first.
+func (s *BlockStore) GetBlocked(ctx context.Context, userID int64, q PaginatedQuery) ([]RelatedUser, error) {
+	return s.getRelated(ctx, "blocks", "blocked_id", userID, q)
+}
+
+// IsBlocked reports whether either user has blocked the other.
+func (s *BlockStore) IsBlocked(ctx context.Context, userID, otherID int64) (bool, error) {
+	query := `SELECT ` + blockedSQL("$1", "$2")
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var blocked bool
+	if err := s.db.QueryRowContext(ctx, query, userID, otherID).Scan(&blocked); err != nil {
+		return false, err
+	}
+
+	return blocked, nil
+}
+
+// BlockedAmong returns which of userIDs userID is blocked with, either way.
+func (s *BlockStore) BlockedAmong(ctx context.Context, userID int64, userIDs []int64) ([]int64, error) {
+	query := `
+	SELECT u.id
+	FROM unnest($2::bigint[]) AS u(id)
+	WHERE ` + blockedSQL("u.id", "$1") + `
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, pq.Array(userIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	blocked := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		blocked = append(blocked, id)
+	}
+
+	return blocked, rows.Err()
+}
+
+// Mute hides the posts of mutedID from the feed of userID. Muting twice is
+// ErrorConflict, an unknown user ErrNotFound.
+func (s *BlockStore) Mute(ctx context.Context, userID, mutedID int64) error {
+	query := `INSERT INTO mutes (user_id, muted_id) VALUES ($1, $2)`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	if _, err := s.db.ExecContext(ctx, query, userID, mutedID); err != nil {
+		return relationError(err)
+	}
+
+	return nil
+}
+
+func (s *BlockStore) Unmute(ctx context.Context, userID, mutedID int64) error {
+	return s.deleteRelation(ctx, `DELETE FROM mutes WHERE user_id = $1 AND muted_id = $2`, userID, mutedID)
+}
+
+// GetMuted lists the users userID has muted, most recent first.
+func (s *BlockStore) GetMuted(ctx context.Context, userID int64, q PaginatedQuery) ([]RelatedUser, error) {
+	return s.getRelated(ctx, "mutes", "muted_id", userID, q)
+}
+
+func (s *BlockStore) deleteRelation(ctx context.Context, query string, userID, otherID int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userID, otherID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *BlockStore) getRelated(ctx context.Context, table, column string, userID int64, q PaginatedQuery) ([]RelatedUser, error) {
+	query := `
+	SELECT u.id, u.username, r.created_at
+	FROM ` + table + ` r
+	JOIN users u ON u.id = r.` + column + `
+	WHERE r.user_id = $1
+	ORDER BY r.created_at DESC, u.id DESC
+	LIMIT $2 OFFSET $3
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, q.Limit, q.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []RelatedUser{}
+	for rows.Next() {
+		var u RelatedUser
+		if err := rows.Scan(&u.ID, &u.Username, &u.Since); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+
+	return users, rows.Err()
+}
+
+func relationError(err error) error {
+	if pqErr, ok := err.(*pq.Error); ok {
+		switch pqErr.Code {
+		case "23505":
+			return ErrorConflict
+		case "23503":
+			return ErrNotFound
+		}
+	}
+	return err
+}
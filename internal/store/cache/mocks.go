@@ -2,6 +2,7 @@ package cache
 
 import (
 	"context"
+	"time"
 
 	"social/internal/store"
 
@@ -10,7 +11,11 @@ import (
 
 func NewMockStore() Storage {
 	return Storage{
-		Users: &MockUserStore{},
+		Users:       &MockUserStore{},
+		Sessions:    &MockSessionStore{},
+		Permissions: &MockPermissionStore{},
+		Suggestions: &MockSuggestionStore{},
+		UserSearch:  &MockUserSearchStore{},
 	}
 }
 
@@ -28,6 +33,68 @@ func (m *MockUserStore) Set(ctx context.Context, user *store.User) error {
 	return args.Error(0)
 }
 
-func (m *MockUserStore) Delete(ctx context.Context, userID int64) {
-	m.Called(userID)
+func (m *MockUserStore) Delete(ctx context.Context, userID int64) error {
+	args := m.Called(userID)
+	return args.Error(0)
+}
+
+// MockSessionStore never has anything denied, the tests revoke sessions in the
+// Postgres mock.
+type MockSessionStore struct{}
+
+func (m *MockSessionStore) IsRevoked(ctx context.Context, sessionID string) (bool, error) {
+	return false, nil
+}
+
+func (m *MockSessionStore) Revoke(ctx context.Context, sessionID string, exp time.Duration) error {
+	return nil
+}
+
+// MockPermissionStore always misses, permissions come from the store mock.
+type MockPermissionStore struct{}
+
+func (m *MockPermissionStore) Get(ctx context.Context, userID int64) (store.Permissions, error) {
+	return nil, nil
+}
+
+func (m *MockPermissionStore) Set(ctx context.Context, userID int64, permissions store.Permissions) error {
+	return nil
+}
+
+func (m *MockPermissionStore) Delete(ctx context.Context, userID int64) error {
+	return nil
+}
+
+func (m *MockPermissionStore) DeleteAll(ctx context.Context) error {
+	return nil
+}
+
+// MockSuggestionStore always misses, suggestions come from the store mock.
+type MockSuggestionStore struct{}
+
+func (m *MockSuggestionStore) Get(ctx context.Context, userID int64) ([]store.Suggestion, error) {
+	return nil, nil
+}
+
+func (m *MockSuggestionStore) Set(ctx context.Context, userID int64, suggestions []store.Suggestion, exp time.Duration) error {
+	return nil
+}
+
+func (m *MockSuggestionStore) Delete(ctx context.Context, userID int64) error {
+	return nil
+}
+
+// MockUserSearchStore always misses, results come from the store mock.
+type MockUserSearchStore struct{}
+
+func (m *MockUserSearchStore) Get(ctx context.Context, prefix string) ([]store.UserSearchResult, error) {
+	return nil, nil
+}
+
+func (m *MockUserSearchStore) Set(ctx context.Context, prefix string, results []store.UserSearchResult) error {
+	return nil
+}
+
+func (m *MockUserSearchStore) Delete(ctx context.Context, prefixes ...string) error {
+	return nil
 }
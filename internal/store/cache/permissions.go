@@ -0,0 +1,66 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"social/internal/store"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// PermissionStore caches the effective permissions of users, they are checked
+// on every guarded request but rarely change.
+type PermissionStore struct {
+	rdb *redis.Client
+}
+
+const PermissionExpTime = 5 * time.Minute
+
+func (s *PermissionStore) Get(ctx context.Context, userID int64) (store.Permissions, error) {
+	cacheKey := fmt.Sprintf("permissions:%v", userID)
+
+	data, err := s.rdb.Get(ctx, cacheKey).Result()
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	permissions := store.Permissions{}
+	if err := json.Unmarshal([]byte(data), &permissions); err != nil {
+		return nil, err
+	}
+
+	return permissions, nil
+}
+
+func (s *PermissionStore) Set(ctx context.Context, userID int64, permissions store.Permissions) error {
+	cacheKey := fmt.Sprintf("permissions:%v", userID)
+
+	json, err := json.Marshal(permissions)
+	if err != nil {
+		return err
+	}
+
+	return s.rdb.SetEX(ctx, cacheKey, json, PermissionExpTime).Err()
+}
+
+func (s *PermissionStore) Delete(ctx context.Context, userID int64) error {
+	cacheKey := fmt.Sprintf("permissions:%v", userID)
+
+	return s.rdb.Del(ctx, cacheKey).Err()
+}
+
+// DeleteAll drops every cached set, for when a role's permissions change.
+func (s *PermissionStore) DeleteAll(ctx context.Context) error {
+	iter := s.rdb.Scan(ctx, 0, "permissions:*", 100).Iterator()
+	for iter.Next(ctx) {
+		if err := s.rdb.Del(ctx, iter.Val()).Err(); err != nil {
+			return err
+		}
+	}
+
+	return iter.Err()
+}
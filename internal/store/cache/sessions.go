@@ -0,0 +1,32 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// SessionStore is a denylist of revoked sessions. An entry only has to outlive
+// the access tokens of the session, refreshing is always checked in Postgres.
+type SessionStore struct {
+	rdb *redis.Client
+}
+
+func (s *SessionStore) IsRevoked(ctx context.Context, sessionID string) (bool, error) {
+	cacheKey := fmt.Sprintf("session-revoked:%v", sessionID)
+
+	n, err := s.rdb.Exists(ctx, cacheKey).Result()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+func (s *SessionStore) Revoke(ctx context.Context, sessionID string, exp time.Duration) error {
+	cacheKey := fmt.Sprintf("session-revoked:%v", sessionID)
+
+	return s.rdb.SetEX(ctx, cacheKey, 1, exp).Err()
+}
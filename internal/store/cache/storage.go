@@ -3,6 +3,7 @@ package cache
 import (
 	"context"
 	"social/internal/store"
+	"time"
 
 	"github.com/go-redis/redis/v8"
 )
@@ -11,11 +12,36 @@ type Storage struct {
 	Users interface {
 		Get(context.Context, int64) (*store.User, error)
 		Set(context.Context, *store.User) error
+		Delete(context.Context, int64) error
+	}
+	Sessions interface {
+		IsRevoked(context.Context, string) (bool, error)
+		Revoke(context.Context, string, time.Duration) error
+	}
+	Permissions interface {
+		Get(context.Context, int64) (store.Permissions, error)
+		Set(context.Context, int64, store.Permissions) error
+		Delete(context.Context, int64) error
+		DeleteAll(context.Context) error
+	}
+	Suggestions interface {
+		Get(context.Context, int64) ([]store.Suggestion, error)
+		Set(context.Context, int64, []store.Suggestion, time.Duration) error
+		Delete(context.Context, int64) error
+	}
+	UserSearch interface {
+		Get(context.Context, string) ([]store.UserSearchResult, error)
+		Set(context.Context, string, []store.UserSearchResult) error
+		Delete(context.Context, ...string) error
 	}
 }
 
 func NewRedisStorage(rdb *redis.Client) Storage {
 	return Storage{
-		Users: &UserStore{rdb: rdb},
+		Users:       &UserStore{rdb: rdb},
+		Sessions:    &SessionStore{rdb: rdb},
+		Permissions: &PermissionStore{rdb: rdb},
+		Suggestions: &SuggestionStore{rdb: rdb},
+		UserSearch:  &UserSearchStore{rdb: rdb},
 	}
 }
@@ -0,0 +1,52 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"social/internal/store"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// SuggestionStore holds the who-to-follow lists, they are expensive to rank
+// and computed ahead of time for recently seen users.
+type SuggestionStore struct {
+	rdb *redis.Client
+}
+
+func (s *SuggestionStore) Get(ctx context.Context, userID int64) ([]store.Suggestion, error) {
+	cacheKey := fmt.Sprintf("suggestions:%v", userID)
+
+	data, err := s.rdb.Get(ctx, cacheKey).Result()
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	suggestions := []store.Suggestion{}
+	if err := json.Unmarshal([]byte(data), &suggestions); err != nil {
+		return nil, err
+	}
+
+	return suggestions, nil
+}
+
+func (s *SuggestionStore) Set(ctx context.Context, userID int64, suggestions []store.Suggestion, exp time.Duration) error {
+	cacheKey := fmt.Sprintf("suggestions:%v", userID)
+
+	json, err := json.Marshal(suggestions)
+	if err != nil {
+		return err
+	}
+
+	return s.rdb.SetEX(ctx, cacheKey, json, exp).Err()
+}
+
+func (s *SuggestionStore) Delete(ctx context.Context, userID int64) error {
+	cacheKey := fmt.Sprintf("suggestions:%v", userID)
+
+	return s.rdb.Del(ctx, cacheKey).Err()
+}
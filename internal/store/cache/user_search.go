@@ -0,0 +1,72 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"social/internal/store"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// UserSearchStore caches the users matching short username prefixes. Those
+// are what every @mention autocomplete asks for first, and match the most
+// users.
+type UserSearchStore struct {
+	rdb *redis.Client
+}
+
+// UserSearchExpTime is short, new users don't show up in cached prefixes
+// until it passes.
+const UserSearchExpTime = time.Minute
+
+// userSearchCacheKey is the same for every casing of a prefix, the matches
+// are.
+func userSearchCacheKey(prefix string) string {
+	return fmt.Sprintf("user-search:%v", strings.ToLower(prefix))
+}
+
+func (s *UserSearchStore) Get(ctx context.Context, prefix string) ([]store.UserSearchResult, error) {
+	cacheKey := userSearchCacheKey(prefix)
+
+	data, err := s.rdb.Get(ctx, cacheKey).Result()
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	results := []store.UserSearchResult{}
+	if err := json.Unmarshal([]byte(data), &results); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+func (s *UserSearchStore) Set(ctx context.Context, prefix string, results []store.UserSearchResult) error {
+	cacheKey := userSearchCacheKey(prefix)
+
+	json, err := json.Marshal(results)
+	if err != nil {
+		return err
+	}
+
+	return s.rdb.SetEX(ctx, cacheKey, json, UserSearchExpTime).Err()
+}
+
+// Delete drops the matches of the prefixes, after a user they hold changed.
+func (s *UserSearchStore) Delete(ctx context.Context, prefixes ...string) error {
+	if len(prefixes) == 0 {
+		return nil
+	}
+
+	cacheKeys := make([]string, len(prefixes))
+	for i, prefix := range prefixes {
+		cacheKeys[i] = userSearchCacheKey(prefix)
+	}
+
+	return s.rdb.Del(ctx, cacheKeys...).Err()
+}
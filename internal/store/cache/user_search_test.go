@@ -0,0 +1,13 @@
+package cache
+
+import "testing"
+
+func TestUserSearchCacheKey(t *testing.T) {
+	if got := userSearchCacheKey("Al"); got != "user-search:al" {
+		t.Errorf(`expected "user-search:al", got %q`, got)
+	}
+
+	if userSearchCacheKey("AL") != userSearchCacheKey("al") {
+		t.Error("expected every casing of a prefix to share a key")
+	}
+}
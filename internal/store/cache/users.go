@@ -16,8 +16,14 @@ type UserStore struct {
 
 const UserExpTime = time.Minute
 
+// userCacheKey is shared by every method, Get and Set once built it apart and
+// the cache was never read back.
+func userCacheKey(userID int64) string {
+	return fmt.Sprintf("user:%v", userID)
+}
+
 func (s *UserStore) Get(ctx context.Context, userID int64) (*store.User, error) {
-	cacheKey := fmt.Sprintf("user-%v", userID)
+	cacheKey := userCacheKey(userID)
 
 	data, err := s.rdb.Get(ctx, cacheKey).Result()
 	if err == redis.Nil{
@@ -36,7 +42,7 @@ func (s *UserStore) Get(ctx context.Context, userID int64) (*store.User, error)
 }
 
 func (s *UserStore) Set(ctx context.Context, user *store.User) error {
-	cacheKey := fmt.Sprintf("user:%v", user.ID)
+	cacheKey := userCacheKey(user.ID)
 
 	json, err := json.Marshal(user)
 	if err != nil {
@@ -45,3 +51,9 @@ func (s *UserStore) Set(ctx context.Context, user *store.User) error {
 
 	return s.rdb.SetEX(ctx, cacheKey, json, UserExpTime).Err()
 }
+
+func (s *UserStore) Delete(ctx context.Context, userID int64) error {
+	cacheKey := userCacheKey(userID)
+
+	return s.rdb.Del(ctx, cacheKey).Err()
+}
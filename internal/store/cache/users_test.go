@@ -0,0 +1,13 @@
+package cache
+
+import "testing"
+
+func TestUserCacheKey(t *testing.T) {
+	if got := userCacheKey(42); got != "user:42" {
+		t.Errorf(`expected "user:42", got %q`, got)
+	}
+
+	if userCacheKey(4) == userCacheKey(42) {
+		t.Error("expected users to have their own keys")
+	}
+}
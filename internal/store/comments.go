@@ -3,32 +3,174 @@ package store
 import (
 	"context"
 	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
 )
 
+// DeletedComment stands in for the content of a deleted comment that is kept
+// for its replies.
+const DeletedComment = "[deleted]"
+
 type Comment struct {
-	ID        int64  `json:"id"`
-	PostID    int64  `json:"post_id"`
-	UserID    int64  `json:"user_id"`
-	Content   string `json:"content"`
-	CreatedAt string `json:"created_at"`
-	User      User   `json:"user"`
+	ID        int64      `json:"id"`
+	PostID    int64      `json:"post_id"`
+	ParentID  *int64     `json:"parent_id"` // null on top level comments
+	UserID    int64      `json:"user_id"`
+	Content   string     `json:"content"`
+	CreatedAt string     `json:"created_at"`
+	EditedAt  *time.Time `json:"edited_at"` // null until the author edits it
+	Deleted   bool       `json:"deleted"`   // a placeholder, without content or author
+	User      User       `json:"user"`
+	Thread
+}
+
+// Thread is where a comment stands among its replies. Replies only holds the
+// ones that were loaded, RepliesCursor pages through the rest.
+type Thread struct {
+	RepliesCount  int       `json:"replies_count"`
+	Replies       []Comment `json:"replies,omitempty"`
+	RepliesCursor string    `json:"replies_cursor,omitempty"`
+}
+
+// CommentQuery pages through comments, top level ones oldest first unless
+// Sort is desc. Cursor is the next_cursor of the previous page, read with the
+// same Sort. Depth is how many levels of replies come along.
+type CommentQuery struct {
+	Limit  int    `json:"limit" validate:"gte=1,lte=100"`
+	Sort   string `json:"sort" validate:"oneof=asc desc"`
+	Cursor string `json:"cursor" validate:"max=100"`
+	Depth  int    `json:"depth" validate:"gte=0,lte=5"`
+}
+
+func (q CommentQuery) Parse(r *http.Request) (CommentQuery, error) {
+	qs := r.URL.Query()
+
+	if limit := qs.Get("limit"); limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return q, err
+		}
+		q.Limit = l
+	}
+
+	if sort := qs.Get("sort"); sort != "" {
+		q.Sort = sort
+	}
+
+	q.Cursor = qs.Get("cursor")
+
+	if depth := qs.Get("depth"); depth != "" {
+		d, err := strconv.Atoi(depth)
+		if err != nil {
+			return q, err
+		}
+		q.Depth = d
+	}
+
+	return q, nil
+}
+
+// CommentCursor points after a comment, for either sort.
+func CommentCursor(c Comment) string {
+	createdAt, _ := time.Parse(time.RFC3339, c.CreatedAt)
+	return keysetCursor(createdAt, c.ID)
 }
 
 type CommentStore struct {
 	db *sql.DB
 }
 
-func (s *CommentStore) GetByPostID(ctx context.Context, postID int64) ([]Comment, error) {
+// visibleCommentSQL is the condition that the comment aliased c, written by
+// the user aliased u, shows to viewer: its author is in good standing and not
+// blocked with them.
+func visibleCommentSQL(c, u, viewer string) string {
+	return goodStandingSQL(u) + ` AND NOT ` + blockedSQL(c+".user_id", viewer)
+}
+
+// commentColumns are what scanComment reads from comments c joined with their
+// author u. The replies counted are the ones viewer can see.
+func commentColumns(viewer string) string {
+	return `c.id, c.post_id, c.parent_id, c.user_id, c.content, c.created_at, c.edited_at,
+		c.deleted_at IS NOT NULL,
+		(
+			SELECT COUNT(*) FROM comments r JOIN users ru ON ru.id = r.user_id
+			WHERE r.parent_id = c.id AND ` + visibleCommentSQL("r", "ru", viewer) + `
+		),
+		u.username, u.id`
+}
+
+func scanComment(row rowScanner, extra ...any) (*Comment, error) {
+	c := &Comment{}
+
+	dest := append([]any{
+		&c.ID,
+		&c.PostID,
+		&c.ParentID,
+		&c.UserID,
+		&c.Content,
+		&c.CreatedAt,
+		&c.EditedAt,
+		&c.Deleted,
+		&c.RepliesCount,
+		&c.User.Username,
+		&c.User.ID,
+	}, extra...)
+
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+
+	// whoever deleted it doesn't want it tied to them anymore
+	if c.Deleted {
+		c.UserID = 0
+		c.User = User{}
+		c.Content = DeletedComment
+		c.EditedAt = nil
+	}
+
+	return c, nil
+}
+
+// GetByPostID pages through the top level comments of a post, leaving out
+// those of suspended users and of the users viewerID is blocked with.
+func (s *CommentStore) GetByPostID(ctx context.Context, postID, viewerID int64, q CommentQuery) ([]Comment, error) {
+	return s.list(ctx, "c.post_id = $1 AND c.parent_id IS NULL", postID, viewerID, q)
+}
+
+// GetReplies pages through the replies of a comment like GetByPostID.
+func (s *CommentStore) GetReplies(ctx context.Context, parentID, viewerID int64, q CommentQuery) ([]Comment, error) {
+	return s.list(ctx, "c.parent_id = $1", parentID, viewerID, q)
+}
+
+func (s *CommentStore) list(ctx context.Context, where string, id, viewerID int64, q CommentQuery) ([]Comment, error) {
+	after, afterID, err := decodeKeysetCursor(q.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	// q.Sort is validated, the comparison follows it
+	op := ">"
+	if q.Sort == "desc" {
+		op = "<"
+	}
 
 	query := `
-	SELECT c.id, c.post_id, c.user_id, c.content, c.created_at, users.username, users.id
-	FROM comments c 
-	JOIN users on users.id = c.user_id
-	WHERE c.post_id = $1
-	ORDER BY c.created_at DESC;
+	SELECT ` + commentColumns("$2") + `
+	FROM comments c
+	JOIN users u ON u.id = c.user_id
+	WHERE ` + where + ` AND ` + visibleCommentSQL("c", "u", "$2") + `
+		AND ($3::timestamptz IS NULL OR (c.created_at, c.id) ` + op + ` ($3, $4))
+	ORDER BY c.created_at ` + q.Sort + `, c.id ` + q.Sort + `
+	LIMIT $5
 	`
 
-	rows, err := s.db.QueryContext(ctx, query, postID)
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, id, viewerID, after, afterID, q.Limit)
 	if err != nil {
 		return nil, err
 	}
@@ -37,32 +179,99 @@ func (s *CommentStore) GetByPostID(ctx context.Context, postID int64) ([]Comment
 
 	comments := []Comment{}
 	for rows.Next() {
-		var c Comment
-		c.User = User{}
-		err := rows.Scan(
-			&c.ID,
-			&c.PostID,
-			&c.UserID,
-			&c.Content,
-			&c.CreatedAt,
-			&c.User.Username,
-			&c.User.ID,
-		)
+		c, err := scanComment(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		comments = append(comments, *c)
+	}
+	return comments, rows.Err()
+}
+
+// GetRepliesOf returns the first replies of each of the parents, oldest first
+// and at most limit of them per parent, for loading comment trees a level at
+// a time.
+func (s *CommentStore) GetRepliesOf(ctx context.Context, parentIDs []int64, viewerID int64, limit int) (map[int64][]Comment, error) {
+	query := `
+	SELECT * FROM (
+		SELECT ` + commentColumns("$2") + `,
+			ROW_NUMBER() OVER (PARTITION BY c.parent_id ORDER BY c.created_at, c.id) AS n
+		FROM comments c
+		JOIN users u ON u.id = c.user_id
+		WHERE c.parent_id = ANY($1) AND ` + visibleCommentSQL("c", "u", "$2") + `
+	) replies
+	WHERE n <= $3
+	ORDER BY n
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
 
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(parentIDs), viewerID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	replies := map[int64][]Comment{}
+	for rows.Next() {
+		var n int
+		c, err := scanComment(rows, &n)
 		if err != nil {
 			return nil, err
 		}
 
-		comments = append(comments, c)
+		replies[*c.ParentID] = append(replies[*c.ParentID], *c)
 	}
-	return comments, nil
+
+	return replies, rows.Err()
 }
 
+// GetByID returns deleted comments too, as placeholders, since their replies
+// are still reached through them.
+func (s *CommentStore) GetByID(ctx context.Context, id int64) (*Comment, error) {
+	// counted for nobody in particular, there is no user 0 to be blocked with
+	query := `
+	SELECT ` + commentColumns("0") + `
+	FROM comments c
+	JOIN users u ON u.id = c.user_id
+	WHERE c.id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	c, err := scanComment(s.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// Create returns ErrNotFound when the post is gone, or its author and the
+// commenter are blocked with each other. A reply also needs its parent to be
+// on the same post, not deleted, and its author not blocked with the
+// commenter.
 func (s *CommentStore) Create(ctx context.Context, comment *Comment) error {
 
 	query := `
-	INSERT INTO comments (post_id, user_id, content)
-	VALUES ($1, $2, $3) RETURNING id, created_at
+	INSERT INTO comments (post_id, user_id, content, parent_id)
+	SELECT p.id, $2, $3, $4
+	FROM posts p
+	WHERE p.id = $1 AND NOT ` + blockedSQL("p.user_id", "$2") + `
+		AND ($4::bigint IS NULL OR EXISTS (
+			SELECT 1 FROM comments pc
+			WHERE pc.id = $4 AND pc.post_id = p.id AND pc.deleted_at IS NULL
+				AND NOT ` + blockedSQL("pc.user_id", "$2") + `
+		))
+	RETURNING id, created_at
 	`
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 
@@ -74,13 +283,111 @@ func (s *CommentStore) Create(ctx context.Context, comment *Comment) error {
 		comment.PostID,
 		comment.UserID,
 		comment.Content,
+		comment.ParentID,
 	).Scan(
 		&comment.ID,
 		&comment.CreatedAt,
 	)
 	if err != nil {
-		return err
+		// the parent was deleted while the reply was written
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+			return ErrNotFound
+		}
+
+		switch err {
+		case sql.ErrNoRows:
+			return ErrNotFound
+		default:
+			return err
+		}
 	}
 
 	return nil
 }
+
+// Update saves the content of a comment and marks it as edited.
+func (s *CommentStore) Update(ctx context.Context, comment *Comment) error {
+	query := `
+	UPDATE comments
+	SET content = $1, edited_at = NOW()
+	WHERE id = $2 AND deleted_at IS NULL
+	RETURNING edited_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(ctx, query, comment.Content, comment.ID).Scan(&comment.EditedAt)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return ErrNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Delete removes a comment, or blanks it into a placeholder when it has
+// replies. Placeholders left without replies go with it.
+func (s *CommentStore) Delete(ctx context.Context, id int64) error {
+	return withTX(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		// a reply waits on the lock through its foreign key, so it is either
+		// seen below or refused
+		var parentID *int64
+		query := `SELECT parent_id FROM comments WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`
+
+		if err := tx.QueryRowContext(ctx, query, id).Scan(&parentID); err != nil {
+			switch err {
+			case sql.ErrNoRows:
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		var hasReplies bool
+		query = `SELECT EXISTS (SELECT 1 FROM comments WHERE parent_id = $1)`
+
+		if err := tx.QueryRowContext(ctx, query, id).Scan(&hasReplies); err != nil {
+			return err
+		}
+
+		if hasReplies {
+			_, err := tx.ExecContext(ctx, `UPDATE comments SET content = '', deleted_at = NOW() WHERE id = $1`, id)
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM comments WHERE id = $1`, id); err != nil {
+			return err
+		}
+
+		// walk up the placeholders that were only kept for this branch
+		query = `
+		DELETE FROM comments c
+		WHERE c.id = $1 AND c.deleted_at IS NOT NULL
+			AND NOT EXISTS (SELECT 1 FROM comments r WHERE r.parent_id = c.id)
+		RETURNING c.parent_id
+		`
+
+		for parentID != nil {
+			var next *int64
+			err := tx.QueryRowContext(ctx, query, *parentID).Scan(&next)
+			if err == sql.ErrNoRows {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+			parentID = next
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,20 @@
+package store
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCommentCursor(t *testing.T) {
+	createdAt := time.Unix(1700000000, 0)
+	comment := Comment{ID: 7, CreatedAt: createdAt.Format(time.RFC3339Nano)}
+
+	after, id, err := decodeKeysetCursor(CommentCursor(comment))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !after.Equal(createdAt) || id != comment.ID {
+		t.Errorf("expected %v and %d, got %v and %d", createdAt, comment.ID, after, id)
+	}
+}
@@ -0,0 +1,197 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// EmailChange is a pending or confirmed change of a user's email. The new
+// address confirms it with Token, the old one can undo it with RevertToken.
+type EmailChange struct {
+	UserID          int64
+	OldEmail        string
+	NewEmail        string
+	Token           string // sha256 hashes of the tokens mailed out
+	RevertToken     string
+	ExpiresAt       time.Time
+	RevertExpiresAt time.Time
+	ConfirmedAt     *time.Time
+}
+
+// CreateEmailChange replaces any change the user has pending. It returns
+// ErrDuplicateEmail when the new address is already taken.
+func (s *UserStore) CreateEmailChange(ctx context.Context, change *EmailChange) error {
+	return withTX(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		var taken bool
+		err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM users WHERE email = $1)`, change.NewEmail).Scan(&taken)
+		if err != nil {
+			return err
+		}
+
+		if taken {
+			return ErrDuplicateEmail
+		}
+
+		query := `DELETE FROM email_changes WHERE user_id = $1 AND confirmed_at IS NULL`
+		if _, err := tx.ExecContext(ctx, query, change.UserID); err != nil {
+			return err
+		}
+
+		query = `
+		INSERT INTO email_changes (token, revert_token, user_id, old_email, new_email, expiry, revert_expiry)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		`
+
+		_, err = tx.ExecContext(
+			ctx,
+			query,
+			change.Token,
+			change.RevertToken,
+			change.UserID,
+			change.OldEmail,
+			change.NewEmail,
+			change.ExpiresAt,
+			change.RevertExpiresAt,
+		)
+		return err
+	})
+}
+
+// ConfirmEmailChange switches the user to the new address.
+func (s *UserStore) ConfirmEmailChange(ctx context.Context, token string) (*EmailChange, error) {
+	var change *EmailChange
+
+	err := withTX(s.db, ctx, func(tx *sql.Tx) error {
+		var err error
+		change, err = s.getEmailChange(ctx, tx, "token", token)
+		if err != nil {
+			return err
+		}
+
+		if change.ConfirmedAt != nil {
+			return ErrNotFound
+		}
+
+		if time.Now().After(change.ExpiresAt) {
+			return ErrTokenExpired
+		}
+
+		if err := s.updateEmail(ctx, tx, change.UserID, change.OldEmail, change.NewEmail); err != nil {
+			return err
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		_, err = tx.ExecContext(ctx, `UPDATE email_changes SET confirmed_at = NOW() WHERE token = $1`, token)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return change, nil
+}
+
+// RevertEmailChange cancels a pending change, or puts the old address back if
+// it was already confirmed.
+func (s *UserStore) RevertEmailChange(ctx context.Context, revertToken string) (*EmailChange, error) {
+	var change *EmailChange
+
+	err := withTX(s.db, ctx, func(tx *sql.Tx) error {
+		var err error
+		change, err = s.getEmailChange(ctx, tx, "revert_token", revertToken)
+		if err != nil {
+			return err
+		}
+
+		if time.Now().After(change.RevertExpiresAt) {
+			return ErrTokenExpired
+		}
+
+		if change.ConfirmedAt != nil {
+			if err := s.updateEmail(ctx, tx, change.UserID, change.NewEmail, change.OldEmail); err != nil {
+				return err
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		_, err = tx.ExecContext(ctx, `DELETE FROM email_changes WHERE revert_token = $1`, revertToken)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return change, nil
+}
+
+func (s *UserStore) getEmailChange(ctx context.Context, tx *sql.Tx, column, token string) (*EmailChange, error) {
+	query := `
+	SELECT token, revert_token, user_id, old_email, new_email, expiry, revert_expiry, confirmed_at
+	FROM email_changes
+	WHERE ` + column + ` = $1
+	FOR UPDATE
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	change := &EmailChange{}
+	err := tx.QueryRowContext(ctx, query, token).Scan(
+		&change.Token,
+		&change.RevertToken,
+		&change.UserID,
+		&change.OldEmail,
+		&change.NewEmail,
+		&change.ExpiresAt,
+		&change.RevertExpiresAt,
+		&change.ConfirmedAt,
+	)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return change, nil
+}
+
+// updateEmail only applies while the user still has the address the change
+// started from, an interleaved change makes it ErrNotFound.
+func (s *UserStore) updateEmail(ctx context.Context, tx *sql.Tx, userID int64, from, to string) error {
+	query := `UPDATE users SET email = $1 WHERE id = $2 AND email = $3`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := tx.ExecContext(ctx, query, to, userID, from)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return ErrDuplicateEmail
+		}
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
@@ -3,6 +3,9 @@ package store
 import (
 	"context"
 	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/lib/pq"
 )
@@ -17,37 +20,344 @@ type FollowerStore struct {
 	db *sql.DB
 }
 
-func (s *FollowerStore) Follow(ctx context.Context, followerID, userID int64) error {
+// FollowRequest is a pending follow of a private account.
+type FollowRequest struct {
+	UserID      int64     `json:"user_id"` // the user asking to follow
+	Username    string    `json:"username"`
+	RequestedAt time.Time `json:"requested_at"`
+}
 
-	query := `INSERT INTO followers (user_id, follower_id) VALUES ($1, $2)`
+// Follow follows userID, or asks to when the account is private, in which
+// case it returns true. Following twice, or asking twice, is ErrorConflict,
+// and a block either way is ErrNotFound.
+func (s *FollowerStore) Follow(ctx context.Context, followerID, userID int64) (bool, error) {
+	var requested bool
 
-	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	err := withTX(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
 
-	defer cancel()
+		// locked so the account can't go public, or block the follower, between
+		// the check and the insert. Blocked users look like they don't exist
+		query := `
+		SELECT is_private FROM users
+		WHERE id = $1 AND is_active = true AND NOT ` + blockedSQL("$1", "$2") + `
+		FOR SHARE
+		`
 
-	_, err := s.db.ExecContext(ctx, query, userID, followerID)
-	if err != nil {
-		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
-			return ErrorConflict
+		var private bool
+		if err := tx.QueryRowContext(ctx, query, userID, followerID).Scan(&private); err != nil {
+			switch err {
+			case sql.ErrNoRows:
+				return ErrNotFound
+			default:
+				return err
+			}
 		}
+
+		if private {
+			var following bool
+			query = `SELECT EXISTS (SELECT 1 FROM followers WHERE user_id = $1 AND follower_id = $2)`
+			if err := tx.QueryRowContext(ctx, query, userID, followerID).Scan(&following); err != nil {
+				return err
+			}
+
+			if following {
+				return ErrorConflict
+			}
+
+			requested = true
+			query = `INSERT INTO follow_requests (user_id, follower_id) VALUES ($1, $2)`
+		} else {
+			query = `INSERT INTO followers (user_id, follower_id) VALUES ($1, $2)`
+		}
+
+		if _, err := tx.ExecContext(ctx, query, userID, followerID); err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+				return ErrorConflict
+			}
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return false, err
 	}
 
-	return nil
+	return requested, nil
 }
 
+// UnFollow also withdraws a pending request.
 func (s *FollowerStore) UnFollow(ctx context.Context, followerID, userID int64) error {
+	return withTX(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
 
-	query := `DELETE FROM followers 
-	WHERE user_id = $1 AND follower_id = $2`
+		query := `DELETE FROM followers 
+		WHERE user_id = $1 AND follower_id = $2`
+
+		if _, err := tx.ExecContext(ctx, query, userID, followerID); err != nil {
+			return err
+		}
+
+		query = `DELETE FROM follow_requests WHERE user_id = $1 AND follower_id = $2`
+
+		_, err := tx.ExecContext(ctx, query, userID, followerID)
+		return err
+	})
+}
+
+// GetRequests lists the pending requests to follow userID, oldest first.
+func (s *FollowerStore) GetRequests(ctx context.Context, userID int64, q PaginatedQuery) ([]FollowRequest, error) {
+	query := `
+	SELECT u.id, u.username, fr.created_at
+	FROM follow_requests fr
+	JOIN users u ON u.id = fr.follower_id
+	WHERE fr.user_id = $1 AND u.is_active = true
+	ORDER BY fr.created_at, u.id
+	LIMIT $2 OFFSET $3
+	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, q.Limit, q.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	requests := []FollowRequest{}
+	for rows.Next() {
+		var fr FollowRequest
+		if err := rows.Scan(&fr.UserID, &fr.Username, &fr.RequestedAt); err != nil {
+			return nil, err
+		}
+		requests = append(requests, fr)
+	}
+
+	return requests, rows.Err()
+}
+
+// ApproveRequest turns the request of followerID into a follow of userID.
+func (s *FollowerStore) ApproveRequest(ctx context.Context, userID, followerID int64) error {
+	return withTX(s.db, ctx, func(tx *sql.Tx) error {
+		if err := s.deleteRequest(ctx, tx, userID, followerID); err != nil {
+			return err
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		query := `
+		INSERT INTO followers (user_id, follower_id) VALUES ($1, $2)
+		ON CONFLICT DO NOTHING
+		`
+
+		_, err := tx.ExecContext(ctx, query, userID, followerID)
+		return err
+	})
+}
+
+// RejectRequest drops the request of followerID, who isn't told.
+func (s *FollowerStore) RejectRequest(ctx context.Context, userID, followerID int64) error {
+	return withTX(s.db, ctx, func(tx *sql.Tx) error {
+		return s.deleteRequest(ctx, tx, userID, followerID)
+	})
+}
+
+func (s *FollowerStore) deleteRequest(ctx context.Context, tx *sql.Tx, userID, followerID int64) error {
+	query := `DELETE FROM follow_requests WHERE user_id = $1 AND follower_id = $2`
 
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	_, err := s.db.ExecContext(ctx, query, userID, followerID)
+	res, err := tx.ExecContext(ctx, query, userID, followerID)
 	if err != nil {
 		return err
 	}
 
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
 	return nil
 }
+
+// SetPrivate changes whether the posts of userID only show to approved
+// followers. Going public approves every pending request.
+func (s *FollowerStore) SetPrivate(ctx context.Context, userID int64, private bool) error {
+	return withTX(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		query := `UPDATE users SET is_private = $1 WHERE id = $2`
+		if _, err := tx.ExecContext(ctx, query, private, userID); err != nil {
+			return err
+		}
+
+		if private {
+			return nil
+		}
+
+		query = `
+		WITH approved AS (
+			DELETE FROM follow_requests WHERE user_id = $1
+			RETURNING user_id, follower_id
+		)
+		INSERT INTO followers (user_id, follower_id)
+		SELECT user_id, follower_id FROM approved
+		ON CONFLICT DO NOTHING
+		`
+
+		_, err := tx.ExecContext(ctx, query, userID)
+		return err
+	})
+}
+
+// CanView reports whether viewerID may see the posts of userID: neither has
+// blocked the other, and the account is public or viewerID is the owner or an
+// approved follower.
+func (s *FollowerStore) CanView(ctx context.Context, userID, viewerID int64) (bool, error) {
+	query := `
+	SELECT (NOT u.is_private OR u.id = $2 OR EXISTS (
+		SELECT 1 FROM followers f WHERE f.user_id = u.id AND f.follower_id = $2
+	)) AND NOT ` + blockedSQL("u.id", "$2") + `
+		-- suspended and banned users only see their own
+		AND (u.id = $2 OR ` + goodStandingSQL("u") + `)
+	FROM users u
+	WHERE u.id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var allowed bool
+	if err := s.db.QueryRowContext(ctx, query, userID, viewerID).Scan(&allowed); err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return false, nil
+		default:
+			return false, err
+		}
+	}
+
+	return allowed, nil
+}
+
+// FollowEntry is a user in a followers or following list, with how they
+// relate to the user looking at the list.
+type FollowEntry struct {
+	ID            int64     `json:"id"`
+	Username      string    `json:"username"`
+	FollowedAt    time.Time `json:"followed_at"`
+	FollowedByYou bool      `json:"followed_by_you"`
+	FollowsYou    bool      `json:"follows_you"`
+}
+
+// FollowQuery pages through a follow list, newest first. Cursor is the
+// next_cursor of the previous page.
+type FollowQuery struct {
+	Limit  int    `json:"limit" validate:"gte=1,lte=100"`
+	Cursor string `json:"cursor" validate:"max=100"`
+}
+
+func (q FollowQuery) Parse(r *http.Request) (FollowQuery, error) {
+	qs := r.URL.Query()
+
+	if limit := qs.Get("limit"); limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return q, err
+		}
+		q.Limit = l
+	}
+
+	q.Cursor = qs.Get("cursor")
+
+	return q, nil
+}
+
+// FollowCursor points after an entry, the user ID breaks the ties.
+func FollowCursor(e FollowEntry) string {
+	return keysetCursor(e.FollowedAt, e.ID)
+}
+
+// GetFollowers lists the users following userID, flagged relative to viewerID.
+func (s *FollowerStore) GetFollowers(ctx context.Context, userID, viewerID int64, q FollowQuery) ([]FollowEntry, error) {
+	return s.getFollowList(ctx, "follower_id", "user_id", userID, viewerID, q)
+}
+
+// GetFollowing lists the users userID follows, flagged relative to viewerID.
+func (s *FollowerStore) GetFollowing(ctx context.Context, userID, viewerID int64, q FollowQuery) ([]FollowEntry, error) {
+	return s.getFollowList(ctx, "user_id", "follower_id", userID, viewerID, q)
+}
+
+// getFollowList lists the listed column of the follows whose owner column is
+// userID, leaving out the users viewerID is blocked with.
+func (s *FollowerStore) getFollowList(ctx context.Context, listed, owner string, userID, viewerID int64, q FollowQuery) ([]FollowEntry, error) {
+	before, beforeID, err := decodeKeysetCursor(q.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+	SELECT u.id, u.username, f.created_at,
+		EXISTS (SELECT 1 FROM followers v WHERE v.user_id = u.id AND v.follower_id = $2),
+		EXISTS (SELECT 1 FROM followers v WHERE v.user_id = $2 AND v.follower_id = u.id)
+	FROM followers f
+	JOIN users u ON u.id = f.` + listed + `
+	WHERE f.` + owner + ` = $1 AND u.is_active = true AND ` + goodStandingSQL("u") + `
+		AND NOT ` + blockedSQL("u.id", "$2") + `
+		AND ($3::timestamptz IS NULL OR (f.created_at, u.id) < ($3, $4))
+	ORDER BY f.created_at DESC, u.id DESC
+	LIMIT $5
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, viewerID, before, beforeID, q.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []FollowEntry{}
+	for rows.Next() {
+		var e FollowEntry
+		if err := rows.Scan(&e.ID, &e.Username, &e.FollowedAt, &e.FollowedByYou, &e.FollowsYou); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, rows.Err()
+}
+
+// Counts returns how many active users follow userID and how many it follows.
+func (s *FollowerStore) Counts(ctx context.Context, userID int64) (int64, int64, error) {
+	query := `
+	SELECT
+		(SELECT COUNT(*) FROM followers f JOIN users u ON u.id = f.follower_id
+			WHERE f.user_id = $1 AND u.is_active = true),
+		(SELECT COUNT(*) FROM followers f JOIN users u ON u.id = f.user_id
+			WHERE f.follower_id = $1 AND u.is_active = true)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var followers, following int64
+	if err := s.db.QueryRowContext(ctx, query, userID).Scan(&followers, &following); err != nil {
+		return 0, 0, err
+	}
+
+	return followers, following, nil
+}
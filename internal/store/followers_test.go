@@ -0,0 +1,23 @@
+package store
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFollowCursor(t *testing.T) {
+	entry := FollowEntry{ID: 42, FollowedAt: time.Unix(1700000000, 0)}
+
+	before, id, err := decodeKeysetCursor(FollowCursor(entry))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !before.Equal(entry.FollowedAt) || id != entry.ID {
+		t.Errorf("expected %v and %d, got %v and %d", entry.FollowedAt, entry.ID, before, id)
+	}
+
+	if _, _, err := decodeKeysetCursor("not a cursor"); err != ErrInvalidCursor {
+		t.Errorf("expected %v, got %v", ErrInvalidCursor, err)
+	}
+}
@@ -0,0 +1,186 @@
+package store
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrPasswordMismatch    = errors.New("password does not match")
+	ErrUnknownPasswordHash = errors.New("password hash uses an unknown algorithm")
+
+	errMalformedArgon2id = errors.New("malformed argon2id hash")
+)
+
+// PasswordHasher is a password hashing algorithm. Every hash it encodes starts
+// with its prefix, which is how a stored hash finds its algorithm back.
+type PasswordHasher interface {
+	Prefix() string
+	Hash(text string) ([]byte, error)
+	Compare(hash []byte, text string) error
+	// Outdated reports whether the hash was made with weaker parameters than
+	// the hasher would use today.
+	Outdated(hash []byte) bool
+}
+
+// DefaultPasswordHasher hashes new passwords. Hashes made by any of
+// PasswordHashers are still accepted, and upgraded on the next login.
+var (
+	DefaultPasswordHasher PasswordHasher = &Argon2idHasher{
+		// OWASP recommended minimums
+		Memory:      19 * 1024,
+		Iterations:  2,
+		Parallelism: 1,
+		SaltLength:  16,
+		KeyLength:   32,
+	}
+
+	PasswordHashers = []PasswordHasher{
+		DefaultPasswordHasher,
+		&BcryptHasher{Cost: bcrypt.DefaultCost},
+	}
+)
+
+func passwordHasherFor(hash []byte) (PasswordHasher, error) {
+	for _, h := range PasswordHashers {
+		if bytes.HasPrefix(hash, []byte(h.Prefix())) {
+			return h, nil
+		}
+	}
+	return nil, ErrUnknownPasswordHash
+}
+
+type BcryptHasher struct {
+	Cost int
+}
+
+// Prefix is shared by the $2a$ and $2b$ variants bcrypt writes.
+func (h *BcryptHasher) Prefix() string {
+	return "$2"
+}
+
+func (h *BcryptHasher) Hash(text string) ([]byte, error) {
+	return bcrypt.GenerateFromPassword([]byte(text), h.Cost)
+}
+
+func (h *BcryptHasher) Compare(hash []byte, text string) error {
+	if err := bcrypt.CompareHashAndPassword(hash, []byte(text)); err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return ErrPasswordMismatch
+		}
+		return err
+	}
+	return nil
+}
+
+func (h *BcryptHasher) Outdated(hash []byte) bool {
+	cost, err := bcrypt.Cost(hash)
+	return err != nil || cost < h.Cost
+}
+
+// Argon2idHasher encodes hashes in the PHC string format,
+// $argon2id$v=19$m=19456,t=2,p=1$<salt>$<key>.
+type Argon2idHasher struct {
+	Memory      uint32 // KiB
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+type argon2idParams struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+	salt        []byte
+	key         []byte
+}
+
+func (h *Argon2idHasher) Prefix() string {
+	return "$argon2id$"
+}
+
+func (h *Argon2idHasher) Hash(text string) ([]byte, error) {
+	salt := make([]byte, h.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	key := argon2.IDKey([]byte(text), salt, h.Iterations, h.Memory, h.Parallelism, h.KeyLength)
+
+	encoded := fmt.Sprintf(
+		"$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version,
+		h.Memory,
+		h.Iterations,
+		h.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	)
+
+	return []byte(encoded), nil
+}
+
+func (h *Argon2idHasher) Compare(hash []byte, text string) error {
+	p, err := h.decode(hash)
+	if err != nil {
+		return err
+	}
+
+	// the parameters of the stored hash, not the current ones
+	key := argon2.IDKey([]byte(text), p.salt, p.iterations, p.memory, p.parallelism, uint32(len(p.key)))
+
+	if subtle.ConstantTimeCompare(key, p.key) != 1 {
+		return ErrPasswordMismatch
+	}
+	return nil
+}
+
+func (h *Argon2idHasher) Outdated(hash []byte) bool {
+	p, err := h.decode(hash)
+	if err != nil {
+		return true
+	}
+
+	return p.memory < h.Memory ||
+		p.iterations < h.Iterations ||
+		p.parallelism < h.Parallelism ||
+		uint32(len(p.key)) < h.KeyLength
+}
+
+func (h *Argon2idHasher) decode(hash []byte) (*argon2idParams, error) {
+	parts := strings.Split(string(hash), "$")
+	if len(parts) != 6 {
+		return nil, errMalformedArgon2id
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, errMalformedArgon2id
+	}
+
+	p := &argon2idParams{}
+
+	_, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.memory, &p.iterations, &p.parallelism)
+	if err != nil {
+		return nil, errMalformedArgon2id
+	}
+
+	if p.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return nil, errMalformedArgon2id
+	}
+
+	if p.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return nil, errMalformedArgon2id
+	}
+
+	return p, nil
+}
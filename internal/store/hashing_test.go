@@ -0,0 +1,59 @@
+package store
+
+import (
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestArgon2idHasher(t *testing.T) {
+	h := &Argon2idHasher{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+	hash, err := h.Hash("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.Compare(hash, "secret"); err != nil {
+		t.Errorf("expected the password to match, got %v", err)
+	}
+
+	if err := h.Compare(hash, "wrong"); err != ErrPasswordMismatch {
+		t.Errorf("expected %v, got %v", ErrPasswordMismatch, err)
+	}
+
+	if h.Outdated(hash) {
+		t.Error("expected a fresh hash to be current")
+	}
+
+	stronger := *h
+	stronger.Iterations = 2
+	if !stronger.Outdated(hash) {
+		t.Error("expected a hash with fewer iterations to be outdated")
+	}
+}
+
+func TestPasswordNeedsRehash(t *testing.T) {
+	legacy, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := password{hash: legacy}
+
+	if err := p.Compare("secret"); err != nil {
+		t.Fatalf("expected a bcrypt hash to still match, got %v", err)
+	}
+
+	if !p.NeedsRehash() {
+		t.Error("expected a bcrypt hash to need a rehash")
+	}
+
+	if err := p.Set("secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	if p.NeedsRehash() {
+		t.Error("expected a new hash to be current")
+	}
+}
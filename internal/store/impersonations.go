@@ -0,0 +1,106 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Impersonation lets an admin act as a user, read-only unless Write is set.
+// Its ID is the jti claim of the impersonation token.
+type Impersonation struct {
+	ID        string     `json:"id"`
+	ActorID   int64      `json:"actor_id"`
+	UserID    int64      `json:"user_id"`
+	Reason    string     `json:"reason"`
+	Write     bool       `json:"write"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	EndedAt   *time.Time `json:"ended_at,omitempty"`
+	CreatedAt string     `json:"created_at"`
+}
+
+type ImpersonationStore struct {
+	db *sql.DB
+}
+
+func (s *ImpersonationStore) Create(ctx context.Context, imp *Impersonation) error {
+	query := `
+	INSERT INTO impersonations (id, actor_id, user_id, reason, write, expiry)
+	VALUES ($1, $2, $3, $4, $5, $6) RETURNING created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return s.db.QueryRowContext(
+		ctx,
+		query,
+		imp.ID,
+		imp.ActorID,
+		imp.UserID,
+		imp.Reason,
+		imp.Write,
+		imp.ExpiresAt,
+	).Scan(&imp.CreatedAt)
+}
+
+// GetByID returns an impersonation that hasn't ended or expired.
+func (s *ImpersonationStore) GetByID(ctx context.Context, id string) (*Impersonation, error) {
+	query := `
+	SELECT id, actor_id, user_id, reason, write, expiry, ended_at, created_at
+	FROM impersonations
+	WHERE id = $1 AND ended_at IS NULL AND expiry > NOW()
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	imp := &Impersonation{}
+	err := s.db.QueryRowContext(ctx, query, id).Scan(
+		&imp.ID,
+		&imp.ActorID,
+		&imp.UserID,
+		&imp.Reason,
+		&imp.Write,
+		&imp.ExpiresAt,
+		&imp.EndedAt,
+		&imp.CreatedAt,
+	)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return imp, nil
+}
+
+// End stops a live impersonation, its token is refused from then on.
+func (s *ImpersonationStore) End(ctx context.Context, id string) error {
+	query := `
+	UPDATE impersonations SET ended_at = NOW()
+	WHERE id = $1 AND ended_at IS NULL AND expiry > NOW()
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,239 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var ErrUnknownMedia = errors.New("media not found, or uploaded by someone else")
+
+// Media is an uploaded image. Its bytes live in a media.BlobStore, Files has
+// the blob key of the original and of every variant by name.
+type Media struct {
+	ID          string            `json:"id"`
+	UserID      int64             `json:"user_id"`
+	ContentType string            `json:"content_type"`
+	Size        int64             `json:"size"`
+	Width       int               `json:"width"`
+	Height      int               `json:"height"`
+	AltText     string            `json:"alt_text,omitempty"` // only on the media of a post
+	Files       map[string]string `json:"-"`
+	URLs        map[string]string `json:"urls,omitempty"` // filled in from Files by the API
+	CreatedAt   string            `json:"created_at"`
+}
+
+type MediaStore struct {
+	db *sql.DB
+}
+
+func (s *MediaStore) Create(ctx context.Context, m *Media) error {
+	files, err := json.Marshal(m.Files)
+	if err != nil {
+		return err
+	}
+
+	query := `
+	INSERT INTO media (id, user_id, content_type, size, width, height, files)
+	VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return s.db.QueryRowContext(
+		ctx,
+		query,
+		m.ID,
+		m.UserID,
+		m.ContentType,
+		m.Size,
+		m.Width,
+		m.Height,
+		files,
+	).Scan(&m.CreatedAt)
+}
+
+func (s *MediaStore) GetByID(ctx context.Context, id string) (*Media, error) {
+	query := `
+	SELECT id, user_id, content_type, size, width, height, files, created_at
+	FROM media
+	WHERE id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	m, err := scanMedia(s.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+// Delete also detaches the media from the posts it was on.
+func (s *MediaStore) Delete(ctx context.Context, id string) error {
+	query := `DELETE FROM media WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// PurgeUnattached deletes the media created before the given time that are
+// on no post, and returns them so their blobs can be deleted.
+func (s *MediaStore) PurgeUnattached(ctx context.Context, before time.Time) ([]Media, error) {
+	query := `
+	DELETE FROM media m
+	WHERE m.created_at < $1
+		AND NOT EXISTS (SELECT 1 FROM post_media pm WHERE pm.media_id = m.id)
+	RETURNING id, user_id, content_type, size, width, height, files, created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, before)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var uploads []Media
+	for rows.Next() {
+		m, err := scanMedia(rows)
+		if err != nil {
+			return nil, err
+		}
+		uploads = append(uploads, *m)
+	}
+
+	return uploads, rows.Err()
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanMedia(row rowScanner, extra ...any) (*Media, error) {
+	m := &Media{}
+
+	var files []byte
+	dest := append([]any{
+		&m.ID,
+		&m.UserID,
+		&m.ContentType,
+		&m.Size,
+		&m.Width,
+		&m.Height,
+		&files,
+		&m.CreatedAt,
+	}, extra...)
+
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(files, &m.Files); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// attachMedia attaches post.Media, uploads of the post's author picked by ID
+// with their alt text, to the post in order.
+func (s *PostStore) attachMedia(ctx context.Context, tx *sql.Tx, post *Post) error {
+	query := `
+	INSERT INTO post_media (post_id, media_id, position, alt_text)
+	SELECT $1, id, $3, $4 FROM media WHERE id = $2 AND user_id = $5
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	for i, m := range post.Media {
+		res, err := tx.ExecContext(ctx, query, post.ID, m.ID, i, m.AltText, post.UserID)
+		if err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+				return ErrorConflict
+			}
+			return err
+		}
+
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rows == 0 {
+			return ErrUnknownMedia
+		}
+	}
+
+	return nil
+}
+
+// getMedia returns the media of each post, in the order they were attached.
+func (s *PostStore) getMedia(ctx context.Context, postIDs []int64) (map[int64][]Media, error) {
+	query := `
+	SELECT m.id, m.user_id, m.content_type, m.size, m.width, m.height, m.files, m.created_at,
+		pm.alt_text, pm.post_id
+	FROM post_media pm
+	JOIN media m ON m.id = pm.media_id
+	WHERE pm.post_id = ANY($1)
+	ORDER BY pm.post_id, pm.position
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(postIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	media := map[int64][]Media{}
+	for rows.Next() {
+		var (
+			altText string
+			postID  int64
+		)
+
+		m, err := scanMedia(rows, &altText, &postID)
+		if err != nil {
+			return nil, err
+		}
+
+		m.AltText = altText
+		media[postID] = append(media[postID], *m)
+	}
+
+	return media, rows.Err()
+}
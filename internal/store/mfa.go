@@ -0,0 +1,203 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+type MFA struct {
+	UserID      int64      `json:"user_id"`
+	TOTPSecret  string     `json:"-"`
+	LastStep    int64      `json:"-"`
+	ConfirmedAt *time.Time `json:"confirmed_at"`
+	CreatedAt   string     `json:"created_at"`
+}
+
+func (m *MFA) Enabled() bool {
+	return m != nil && m.ConfirmedAt != nil
+}
+
+type MFAStore struct {
+	db *sql.DB
+}
+
+func (s *MFAStore) GetByUserID(ctx context.Context, userID int64) (*MFA, error) {
+	query := `
+	SELECT user_id, totp_secret, last_step, confirmed_at, created_at
+	FROM user_mfa
+	WHERE user_id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	mfa := &MFA{}
+	err := s.db.QueryRowContext(ctx, query, userID).Scan(
+		&mfa.UserID,
+		&mfa.TOTPSecret,
+		&mfa.LastStep,
+		&mfa.ConfirmedAt,
+		&mfa.CreatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return mfa, nil
+}
+
+// Enroll stores a new, unconfirmed secret. It replaces a pending enrollment but
+// returns ErrorConflict once 2FA is confirmed.
+func (s *MFAStore) Enroll(ctx context.Context, userID int64, secret string) error {
+	query := `
+	INSERT INTO user_mfa (user_id, totp_secret)
+	VALUES ($1, $2)
+	ON CONFLICT (user_id) DO UPDATE
+	SET totp_secret = EXCLUDED.totp_secret, last_step = 0, created_at = NOW()
+	WHERE user_mfa.confirmed_at IS NULL
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userID, secret)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrorConflict
+	}
+
+	return nil
+}
+
+// Confirm enables 2FA with the step of the first valid code and replaces the
+// recovery codes with the given hashes.
+func (s *MFAStore) Confirm(ctx context.Context, userID int64, step int64, recoveryCodes []string) error {
+	return withTX(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+		UPDATE user_mfa SET confirmed_at = NOW(), last_step = $2
+		WHERE user_id = $1 AND confirmed_at IS NULL
+		`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		res, err := tx.ExecContext(ctx, query, userID, step)
+		if err != nil {
+			return err
+		}
+
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rows == 0 {
+			return ErrNotFound
+		}
+
+		if err := s.deleteRecoveryCodes(ctx, tx, userID); err != nil {
+			return err
+		}
+
+		for _, code := range recoveryCodes {
+			_, err := tx.ExecContext(ctx, `INSERT INTO mfa_recovery_codes (user_id, code) VALUES ($1, $2)`, userID, code)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// UseStep records a TOTP step as used, ErrNotFound means it (or a later one) was already used.
+func (s *MFAStore) UseStep(ctx context.Context, userID int64, step int64) error {
+	query := `
+	UPDATE user_mfa SET last_step = $2
+	WHERE user_id = $1 AND last_step < $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userID, step)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// UseRecoveryCode burns a recovery code given its hash.
+func (s *MFAStore) UseRecoveryCode(ctx context.Context, userID int64, code string) error {
+	query := `
+	UPDATE mfa_recovery_codes SET used_at = NOW()
+	WHERE user_id = $1 AND code = $2 AND used_at IS NULL
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userID, code)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *MFAStore) Disable(ctx context.Context, userID int64) error {
+	return withTX(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM user_mfa WHERE user_id = $1`, userID); err != nil {
+			return err
+		}
+
+		return s.deleteRecoveryCodes(ctx, tx, userID)
+	})
+}
+
+func (s *MFAStore) deleteRecoveryCodes(ctx context.Context, tx *sql.Tx, userID int64) error {
+	query := `DELETE FROM mfa_recovery_codes WHERE user_id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, userID)
+	return err
+}
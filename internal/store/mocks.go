@@ -1,25 +1,45 @@
 package store
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
+	"sync"
 	"time"
 )
 
 func NewMockStore() Storage {
 	return Storage{
-		Users: &MockUserStore{},
+		Posts:                &MockPostStore{},
+		Users:                &MockUserStore{},
+		Comments:             &MockCommentStore{},
+		Roles:                &MockRoleStore{},
+		Followers:            &MockFollowerStore{},
+		Blocks:               &MockBlockStore{},
+		Suggestions:          &MockSuggestionStore{},
+		Media:                &MockMediaStore{},
+		RefreshTokens:        &MockRefreshTokenStore{},
+		MFA:                  &MockMFAStore{},
+		Passkeys:             &MockPasskeyStore{sessions: map[string]mockPasskeySession{}},
+		PersonalAccessTokens: &MockPersonalAccessTokenStore{},
+		Sessions:             &MockSessionStore{revoked: map[string]bool{}},
+		Audit:                &MockAuditStore{},
+		Impersonations:       &MockImpersonationStore{impersonations: map[string]*Impersonation{}},
 	}
 }
 
-type MockUserStore struct{}
+// MockUserStore has every user, in good standing unless Statuses says
+// otherwise.
+type MockUserStore struct {
+	Statuses map[int64]AccountStatus
+}
 
 func (m *MockUserStore) Create(ctx context.Context, tx *sql.Tx, u *User) error {
 	return nil
 }
 
 func (m *MockUserStore) GetByID(ctx context.Context, userID int64) (*User, error) {
-	return &User{ID: userID}, nil
+	return &User{ID: userID, AccountStatus: m.Statuses[userID]}, nil
 }
 
 func (m *MockUserStore) GetByEmail(context.Context, string) (*User, error) {
@@ -30,10 +50,574 @@ func (m *MockUserStore) CreateAndInvite(ctx context.Context, user *User, token s
 	return nil
 }
 
+// Activate knows every invitation token but "expired" and "unknown".
 func (m *MockUserStore) Activate(ctx context.Context, t string) error {
+	switch t {
+	case "expired":
+		return ErrTokenExpired
+	case "unknown":
+		return ErrNotFound
+	}
+
 	return nil
 }
 
+func (m *MockUserStore) RotateInvitation(ctx context.Context, email string, token string, exp time.Duration) (*User, error) {
+	return &User{ID: 1, Email: email}, nil
+}
+
+func (m *MockUserStore) PurgeInactive(ctx context.Context, grace time.Duration) (int64, int64, error) {
+	return 0, 0, nil
+}
+
 func (m *MockUserStore) Delete(ctx context.Context, id int64) error {
 	return nil
 }
+
+func (m *MockUserStore) CreatePasswordReset(ctx context.Context, userID int64, token string, exp time.Duration) error {
+	return nil
+}
+
+func (m *MockUserStore) ResetPassword(ctx context.Context, token string, user *User) error {
+	return nil
+}
+
+func (m *MockUserStore) UpdatePassword(ctx context.Context, user *User) error {
+	return nil
+}
+
+func (m *MockUserStore) ChangePassword(ctx context.Context, user *User, exceptSessionID string) ([]string, error) {
+	return nil, nil
+}
+
+func (m *MockUserStore) CreateEmailChange(ctx context.Context, change *EmailChange) error {
+	return nil
+}
+
+func (m *MockUserStore) ConfirmEmailChange(ctx context.Context, token string) (*EmailChange, error) {
+	return &EmailChange{UserID: 1}, nil
+}
+
+func (m *MockUserStore) RevertEmailChange(ctx context.Context, revertToken string) (*EmailChange, error) {
+	return &EmailChange{UserID: 1}, nil
+}
+
+func (m *MockUserStore) UpdateProfile(ctx context.Context, userID int64, profile Profile) error {
+	return nil
+}
+
+func (m *MockUserStore) Search(ctx context.Context, viewerID int64, q string, limit int) ([]UserSearchResult, error) {
+	return []UserSearchResult{}, nil
+}
+
+// SearchByPrefix matches users 2 and 4, and user 4 blocks everyone.
+func (m *MockUserStore) SearchByPrefix(ctx context.Context, prefix string, limit int) ([]UserSearchResult, error) {
+	return []UserSearchResult{{ID: 2, Username: prefix + "2"}, {ID: 4, Username: prefix + "4"}}, nil
+}
+
+func (m *MockUserStore) SetStatus(ctx context.Context, userID int64, status AccountStatus) (*AccountStatus, error) {
+	previous := m.Statuses[userID]
+	return &previous, nil
+}
+
+func (m *MockUserStore) ReinstateExpired(ctx context.Context) ([]int64, error) {
+	return []int64{}, nil
+}
+
+// MockPostStore has a post for every ID, written by the user with the same ID.
+type MockPostStore struct{}
+
+func (m *MockPostStore) Create(ctx context.Context, post *Post) error {
+	return nil
+}
+
+func (m *MockPostStore) GetByID(ctx context.Context, id int64) (*Post, error) {
+	return &Post{ID: id, UserID: id}, nil
+}
+
+func (m *MockPostStore) DeletePostByID(ctx context.Context, id int64) error {
+	return nil
+}
+
+func (m *MockPostStore) UpdatePost(ctx context.Context, post *Post) error {
+	return nil
+}
+
+func (m *MockPostStore) GetUserFeed(ctx context.Context, userID int64, fq PaginatedFeedQuery) ([]PostWithMetadata, error) {
+	return []PostWithMetadata{}, nil
+}
+
+// MockCommentStore has every comment, written by the user with its ID on
+// post 1, except comment 9 which is a deleted placeholder. Post 1 has comment
+// 1, and every comment has mockRepliesCount replies numbered after it.
+type MockCommentStore struct{}
+
+const mockRepliesCount = 5
+
+func (m *MockCommentStore) Create(ctx context.Context, comment *Comment) error {
+	return nil
+}
+
+func (m *MockCommentStore) GetByPostID(ctx context.Context, postID, viewerID int64, q CommentQuery) ([]Comment, error) {
+	if postID != 1 {
+		return []Comment{}, nil
+	}
+	return []Comment{{ID: 1, PostID: 1, UserID: 1, Thread: Thread{RepliesCount: mockRepliesCount}}}, nil
+}
+
+func (m *MockCommentStore) GetReplies(ctx context.Context, parentID, viewerID int64, q CommentQuery) ([]Comment, error) {
+	return []Comment{}, nil
+}
+
+func (m *MockCommentStore) GetRepliesOf(ctx context.Context, parentIDs []int64, viewerID int64, limit int) (map[int64][]Comment, error) {
+	replies := map[int64][]Comment{}
+	for _, id := range parentIDs {
+		for i := int64(1); i <= min(mockRepliesCount, int64(limit)); i++ {
+			replies[id] = append(replies[id], Comment{
+				ID:        id*10 + i,
+				PostID:    1,
+				ParentID:  &id,
+				CreatedAt: time.Unix(1700000000+i, 0).Format(time.RFC3339),
+				Thread:    Thread{RepliesCount: mockRepliesCount},
+			})
+		}
+	}
+	return replies, nil
+}
+
+func (m *MockCommentStore) GetByID(ctx context.Context, id int64) (*Comment, error) {
+	if id == 9 {
+		return &Comment{ID: id, PostID: 1, Deleted: true, Content: DeletedComment}, nil
+	}
+	return &Comment{ID: id, PostID: 1, UserID: id}, nil
+}
+
+func (m *MockCommentStore) Update(ctx context.Context, comment *Comment) error {
+	return nil
+}
+
+func (m *MockCommentStore) Delete(ctx context.Context, id int64) error {
+	return nil
+}
+
+// MockFollowerStore treats user 3 as a private account nobody follows.
+type MockFollowerStore struct{}
+
+func (m *MockFollowerStore) Follow(ctx context.Context, followerID, userID int64) (bool, error) {
+	return userID == 3, nil
+}
+
+func (m *MockFollowerStore) UnFollow(ctx context.Context, followerID, userID int64) error {
+	return nil
+}
+
+func (m *MockFollowerStore) GetFollowers(ctx context.Context, userID, viewerID int64, q FollowQuery) ([]FollowEntry, error) {
+	return []FollowEntry{}, nil
+}
+
+func (m *MockFollowerStore) GetFollowing(ctx context.Context, userID, viewerID int64, q FollowQuery) ([]FollowEntry, error) {
+	return []FollowEntry{}, nil
+}
+
+func (m *MockFollowerStore) GetRequests(ctx context.Context, userID int64, q PaginatedQuery) ([]FollowRequest, error) {
+	return []FollowRequest{}, nil
+}
+
+func (m *MockFollowerStore) ApproveRequest(ctx context.Context, userID, followerID int64) error {
+	return nil
+}
+
+func (m *MockFollowerStore) RejectRequest(ctx context.Context, userID, followerID int64) error {
+	return ErrNotFound
+}
+
+func (m *MockFollowerStore) SetPrivate(ctx context.Context, userID int64, private bool) error {
+	return nil
+}
+
+func (m *MockFollowerStore) CanView(ctx context.Context, userID, viewerID int64) (bool, error) {
+	return userID != 3 || viewerID == 3, nil
+}
+
+func (m *MockFollowerStore) Counts(ctx context.Context, userID int64) (int64, int64, error) {
+	return 0, 0, nil
+}
+
+// MockRoleStore grants its Permissions to user 1, the user of the test token,
+// and none to anyone else.
+type MockRoleStore struct {
+	Permissions Permissions
+}
+
+func (m *MockRoleStore) GetByName(ctx context.Context, name string) (*Role, error) {
+	return &Role{Name: name}, nil
+}
+
+func (m *MockRoleStore) GetByID(ctx context.Context, id int64) (*Role, error) {
+	return &Role{ID: int(id), Name: "moderator"}, nil
+}
+
+func (m *MockRoleStore) GetAll(ctx context.Context) ([]Role, error) {
+	return []Role{}, nil
+}
+
+func (m *MockRoleStore) Create(ctx context.Context, role *Role) error {
+	return nil
+}
+
+func (m *MockRoleStore) Update(ctx context.Context, role *Role) error {
+	return nil
+}
+
+func (m *MockRoleStore) GetAllPermissions(ctx context.Context) ([]Permission, error) {
+	return []Permission{}, nil
+}
+
+func (m *MockRoleStore) GetPermissions(ctx context.Context, userID int64) (Permissions, error) {
+	if userID != 1 {
+		return Permissions{}, nil
+	}
+	return append(Permissions{}, m.Permissions...), nil
+}
+
+func (m *MockRoleStore) Assign(ctx context.Context, userID, roleID int64) (int64, error) {
+	return 1, nil
+}
+
+func (m *MockRoleStore) GetUsers(ctx context.Context, roleID int64, q PaginatedQuery) ([]User, error) {
+	return []User{}, nil
+}
+
+type MockRefreshTokenStore struct{}
+
+func (m *MockRefreshTokenStore) Create(ctx context.Context, token *RefreshToken) error {
+	return nil
+}
+
+func (m *MockRefreshTokenStore) Rotate(ctx context.Context, token string, next *RefreshToken) error {
+	next.UserID = 1
+	return nil
+}
+
+func (m *MockRefreshTokenStore) RevokeFamily(ctx context.Context, token string) (string, error) {
+	return "", nil
+}
+
+// MockMFAStore has 2FA enabled for the users in Secrets, with their TOTP
+// secret, and for nobody else.
+type MockMFAStore struct {
+	Secrets map[int64]string
+}
+
+func (m *MockMFAStore) GetByUserID(ctx context.Context, userID int64) (*MFA, error) {
+	secret, ok := m.Secrets[userID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	confirmedAt := time.Now()
+	return &MFA{UserID: userID, TOTPSecret: secret, ConfirmedAt: &confirmedAt}, nil
+}
+
+func (m *MockMFAStore) Enroll(ctx context.Context, userID int64, secret string) error {
+	return nil
+}
+
+func (m *MockMFAStore) Confirm(ctx context.Context, userID int64, step int64, recoveryCodes []string) error {
+	return nil
+}
+
+func (m *MockMFAStore) UseStep(ctx context.Context, userID int64, step int64) error {
+	return nil
+}
+
+func (m *MockMFAStore) UseRecoveryCode(ctx context.Context, userID int64, code string) error {
+	return nil
+}
+
+func (m *MockMFAStore) Disable(ctx context.Context, userID int64) error {
+	return nil
+}
+
+// MockPasskeyStore keeps passkeys in memory so a ceremony can be run end to end.
+type MockPasskeyStore struct {
+	sync.Mutex
+	passkeys []Passkey
+	sessions map[string]mockPasskeySession
+}
+
+type mockPasskeySession struct {
+	userID int64
+	data   []byte
+}
+
+func (m *MockPasskeyStore) Create(ctx context.Context, passkey *Passkey) error {
+	m.Lock()
+	defer m.Unlock()
+
+	passkey.ID = int64(len(m.passkeys) + 1)
+	m.passkeys = append(m.passkeys, *passkey)
+	return nil
+}
+
+func (m *MockPasskeyStore) GetByUserID(ctx context.Context, userID int64) ([]Passkey, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	passkeys := []Passkey{}
+	for _, p := range m.passkeys {
+		if p.UserID == userID {
+			passkeys = append(passkeys, p)
+		}
+	}
+	return passkeys, nil
+}
+
+func (m *MockPasskeyStore) UpdateAfterLogin(ctx context.Context, passkey *Passkey) error {
+	m.Lock()
+	defer m.Unlock()
+
+	for i, p := range m.passkeys {
+		if bytes.Equal(p.CredentialID, passkey.CredentialID) {
+			m.passkeys[i].SignCount = passkey.SignCount
+			m.passkeys[i].Credential = passkey.Credential
+		}
+	}
+	return nil
+}
+
+func (m *MockPasskeyStore) Delete(ctx context.Context, userID, passkeyID int64) error {
+	return nil
+}
+
+func (m *MockPasskeyStore) CreateSession(ctx context.Context, challenge string, userID int64, data []byte, exp time.Duration) error {
+	m.Lock()
+	defer m.Unlock()
+
+	m.sessions[challenge] = mockPasskeySession{userID, data}
+	return nil
+}
+
+func (m *MockPasskeyStore) ConsumeSession(ctx context.Context, challenge string) (int64, []byte, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	session, ok := m.sessions[challenge]
+	if !ok {
+		return 0, nil, ErrNotFound
+	}
+
+	delete(m.sessions, challenge)
+	return session.userID, session.data, nil
+}
+
+func (m *MockPasskeyStore) PurgeSessions(ctx context.Context) (int64, error) {
+	return 0, nil
+}
+
+// MockPersonalAccessTokenStore knows a single token, with the feed:read scope.
+type MockPersonalAccessTokenStore struct{}
+
+func (m *MockPersonalAccessTokenStore) Create(ctx context.Context, token *PersonalAccessToken) error {
+	return nil
+}
+
+func (m *MockPersonalAccessTokenStore) GetByUserID(ctx context.Context, userID int64) ([]PersonalAccessToken, error) {
+	return []PersonalAccessToken{}, nil
+}
+
+func (m *MockPersonalAccessTokenStore) GetByToken(ctx context.Context, token string) (*PersonalAccessToken, error) {
+	return &PersonalAccessToken{ID: 1, UserID: 1, Scopes: []string{"feed:read"}}, nil
+}
+
+func (m *MockPersonalAccessTokenStore) Touch(ctx context.Context, tokenID int64) error {
+	return nil
+}
+
+func (m *MockPersonalAccessTokenStore) Delete(ctx context.Context, userID, tokenID int64) error {
+	return nil
+}
+
+// MockSessionStore sees every session as live for user 1 until it is revoked.
+type MockSessionStore struct {
+	sync.Mutex
+	revoked map[string]bool
+}
+
+func (m *MockSessionStore) Create(ctx context.Context, session *Session) error {
+	return nil
+}
+
+func (m *MockSessionStore) GetByID(ctx context.Context, id string) (*Session, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	if m.revoked[id] {
+		return nil, ErrNotFound
+	}
+	return &Session{ID: id, UserID: 1}, nil
+}
+
+func (m *MockSessionStore) GetByUserID(ctx context.Context, userID int64) ([]Session, error) {
+	return []Session{}, nil
+}
+
+func (m *MockSessionStore) Touch(ctx context.Context, id string) error {
+	return nil
+}
+
+func (m *MockSessionStore) Revoke(ctx context.Context, userID int64, id string) error {
+	m.Lock()
+	defer m.Unlock()
+
+	if m.revoked[id] {
+		return ErrNotFound
+	}
+	m.revoked[id] = true
+	return nil
+}
+
+func (m *MockSessionStore) RevokeAll(ctx context.Context, userID int64, exceptID string) ([]string, error) {
+	return nil, nil
+}
+
+// MockAuditStore keeps the entries so tests can check what was recorded.
+type MockAuditStore struct {
+	mu      sync.Mutex
+	Entries []AuditEntry
+}
+
+func (m *MockAuditStore) Create(ctx context.Context, entry *AuditEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry.ID = int64(len(m.Entries) + 1)
+	m.Entries = append(m.Entries, *entry)
+	return nil
+}
+
+func (m *MockAuditStore) Get(ctx context.Context, q AuditQuery) ([]AuditEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entries := []AuditEntry{}
+	for i := len(m.Entries) - 1; i >= 0 && len(entries) < q.Limit; i-- {
+		if q.Cursor == 0 || m.Entries[i].ID < q.Cursor {
+			entries = append(entries, m.Entries[i])
+		}
+	}
+	return entries, nil
+}
+
+type MockImpersonationStore struct {
+	mu             sync.Mutex
+	impersonations map[string]*Impersonation
+}
+
+func (m *MockImpersonationStore) Create(ctx context.Context, imp *Impersonation) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored := *imp
+	m.impersonations[imp.ID] = &stored
+	return nil
+}
+
+func (m *MockImpersonationStore) GetByID(ctx context.Context, id string) (*Impersonation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	imp, ok := m.impersonations[id]
+	if !ok || imp.EndedAt != nil || time.Now().After(imp.ExpiresAt) {
+		return nil, ErrNotFound
+	}
+
+	found := *imp
+	return &found, nil
+}
+
+func (m *MockImpersonationStore) End(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	imp, ok := m.impersonations[id]
+	if !ok || imp.EndedAt != nil {
+		return ErrNotFound
+	}
+
+	now := time.Now()
+	imp.EndedAt = &now
+	return nil
+}
+
+// MockBlockStore treats user 4 as having blocked everyone, nobody else has
+// blocked or muted anyone.
+type MockBlockStore struct{}
+
+func (m *MockBlockStore) Block(ctx context.Context, userID, blockedID int64) error {
+	return nil
+}
+
+func (m *MockBlockStore) Unblock(ctx context.Context, userID, blockedID int64) error {
+	return ErrNotFound
+}
+
+func (m *MockBlockStore) GetBlocked(ctx context.Context, userID int64, q PaginatedQuery) ([]RelatedUser, error) {
+	return []RelatedUser{}, nil
+}
+
+func (m *MockBlockStore) IsBlocked(ctx context.Context, userID, otherID int64) (bool, error) {
+	return userID == 4 || otherID == 4, nil
+}
+
+func (m *MockBlockStore) BlockedAmong(ctx context.Context, userID int64, userIDs []int64) ([]int64, error) {
+	blocked := []int64{}
+	for _, id := range userIDs {
+		if id == 4 || userID == 4 {
+			blocked = append(blocked, id)
+		}
+	}
+	return blocked, nil
+}
+
+func (m *MockBlockStore) Mute(ctx context.Context, userID, mutedID int64) error {
+	return nil
+}
+
+func (m *MockBlockStore) Unmute(ctx context.Context, userID, mutedID int64) error {
+	return ErrNotFound
+}
+
+func (m *MockBlockStore) GetMuted(ctx context.Context, userID int64, q PaginatedQuery) ([]RelatedUser, error) {
+	return []RelatedUser{}, nil
+}
+
+// MockSuggestionStore suggests user 2 to everyone.
+type MockSuggestionStore struct{}
+
+func (m *MockSuggestionStore) Get(ctx context.Context, userID int64, limit int) ([]Suggestion, error) {
+	return []Suggestion{{ID: 2, Username: "suggested", MutualFollows: 1, Score: 3}}, nil
+}
+
+func (m *MockSuggestionStore) GetRecentUserIDs(ctx context.Context, since time.Time, limit int) ([]int64, error) {
+	return []int64{1}, nil
+}
+
+// MockMediaStore has every media, uploaded by user 1.
+type MockMediaStore struct{}
+
+func (m *MockMediaStore) Create(ctx context.Context, media *Media) error {
+	return nil
+}
+
+func (m *MockMediaStore) GetByID(ctx context.Context, id string) (*Media, error) {
+	return &Media{ID: id, UserID: 1, Files: map[string]string{"original": id + "/original.png"}}, nil
+}
+
+func (m *MockMediaStore) Delete(ctx context.Context, id string) error {
+	return nil
+}
+
+func (m *MockMediaStore) PurgeUnattached(ctx context.Context, before time.Time) ([]Media, error) {
+	return nil, nil
+}
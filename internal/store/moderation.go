@@ -0,0 +1,122 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Account states set by moderators. is_active only tells whether the email
+// was confirmed, it is unrelated.
+const (
+	AccountActive    = "active"
+	AccountSuspended = "suspended"
+	AccountBanned    = "banned"
+)
+
+// AccountStatus is the moderation state of a user. A suspension ends on its
+// own at SuspendedUntil, a ban lasts until a moderator lifts it.
+type AccountStatus struct {
+	State          string     `json:"state"`
+	StateReason    string     `json:"state_reason,omitempty"`
+	SuspendedUntil *time.Time `json:"suspended_until,omitempty"`
+}
+
+// InGoodStanding reports whether the account may be used at now, a suspension
+// that has run out no longer counts.
+func (s AccountStatus) InGoodStanding(now time.Time) bool {
+	switch s.State {
+	case AccountBanned:
+		return false
+	case AccountSuspended:
+		return s.SuspendedUntil != nil && !now.Before(*s.SuspendedUntil)
+	default:
+		return true
+	}
+}
+
+// goodStandingSQL is the SQL condition that the user aliased u is neither
+// banned nor still suspended. Their content is hidden from everyone else
+// otherwise.
+func goodStandingSQL(u string) string {
+	return `(` + u + `.account_state = 'active' OR (` + u + `.account_state = 'suspended' AND ` + u + `.suspended_until <= NOW()))`
+}
+
+// SetStatus changes the moderation state of an active user, and reports the
+// state it replaced.
+func (s *UserStore) SetStatus(ctx context.Context, userID int64, status AccountStatus) (*AccountStatus, error) {
+	var previous AccountStatus
+
+	err := withTX(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		query := `
+		SELECT account_state, state_reason, suspended_until
+		FROM users
+		WHERE id = $1 AND is_active = true
+		FOR UPDATE
+		`
+
+		err := tx.QueryRowContext(ctx, query, userID).Scan(
+			&previous.State,
+			&previous.StateReason,
+			&previous.SuspendedUntil,
+		)
+		if err != nil {
+			switch err {
+			case sql.ErrNoRows:
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		_, err = tx.ExecContext(
+			ctx,
+			`UPDATE users SET account_state = $1, state_reason = $2, suspended_until = $3 WHERE id = $4`,
+			status.State,
+			status.StateReason,
+			status.SuspendedUntil,
+			userID,
+		)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &previous, nil
+}
+
+// ReinstateExpired reactivates the users whose suspension has run out and
+// returns their IDs. Suspensions are already lifted when they run out, this
+// only brings the rows in line.
+func (s *UserStore) ReinstateExpired(ctx context.Context) ([]int64, error) {
+	query := `
+	UPDATE users
+	SET account_state = 'active', state_reason = '', suspended_until = NULL
+	WHERE account_state = 'suspended' AND suspended_until <= NOW()
+	RETURNING id
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
@@ -0,0 +1,31 @@
+package store
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAccountStatusInGoodStanding(t *testing.T) {
+	now := time.Now()
+	later, earlier := now.Add(time.Hour), now.Add(-time.Hour)
+
+	tests := []struct {
+		name   string
+		status AccountStatus
+		want   bool
+	}{
+		{"unset", AccountStatus{}, true},
+		{"active", AccountStatus{State: AccountActive}, true},
+		{"suspended", AccountStatus{State: AccountSuspended, SuspendedUntil: &later}, false},
+		{"suspension over", AccountStatus{State: AccountSuspended, SuspendedUntil: &earlier}, true},
+		{"banned", AccountStatus{State: AccountBanned}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.status.InGoodStanding(now); got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
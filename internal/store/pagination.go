@@ -1,12 +1,17 @@
 package store
 
 import (
+	"encoding/base64"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 )
 
+var ErrInvalidCursor = errors.New("invalid cursor")
+
 type PaginatedFeedQuery struct {
 	Limit  int      `json:"limit" validate:"gte=1,lte=20"`
 	Offset int      `json:"offset" validate:"gte=0"`
@@ -76,3 +81,59 @@ func parseTime(s string) string {
 
 	return t.Format(time.DateTime)
 }
+
+// PaginatedQuery pages through lists that have no filters of their own.
+type PaginatedQuery struct {
+	Limit  int `json:"limit" validate:"gte=1,lte=100"`
+	Offset int `json:"offset" validate:"gte=0"`
+}
+
+func (q PaginatedQuery) Parse(r *http.Request) (PaginatedQuery, error) {
+	qs := r.URL.Query()
+
+	if limit := qs.Get("limit"); limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return q, err
+		}
+
+		q.Limit = l
+	}
+
+	if offset := qs.Get("offset"); offset != "" {
+		o, err := strconv.Atoi(offset)
+		if err != nil {
+			return q, err
+		}
+
+		q.Offset = o
+	}
+
+	return q, nil
+}
+
+// keysetCursor points after a row of a list ordered by a timestamp then an ID.
+// The timestamps have a precision of a second, the ID breaks the ties.
+func keysetCursor(t time.Time, id int64) string {
+	raw := fmt.Sprintf("%d,%d", t.Unix(), id)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeKeysetCursor(cursor string) (*time.Time, int64, error) {
+	if cursor == "" {
+		return nil, 0, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, 0, ErrInvalidCursor
+	}
+
+	var unix, id int64
+	if _, err := fmt.Sscanf(string(raw), "%d,%d", &unix, &id); err != nil {
+		return nil, 0, ErrInvalidCursor
+	}
+
+	t := time.Unix(unix, 0)
+	return &t, id, nil
+}
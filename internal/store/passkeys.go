@@ -0,0 +1,192 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type Passkey struct {
+	ID           int64      `json:"id"`
+	UserID       int64      `json:"user_id"`
+	CredentialID []byte     `json:"-"`
+	Name         string     `json:"name"`
+	SignCount    uint32     `json:"sign_count"`
+	Credential   []byte     `json:"-"` // serialized WebAuthn credential record
+	CreatedAt    string     `json:"created_at"`
+	LastUsedAt   *time.Time `json:"last_used_at"`
+}
+
+type PasskeyStore struct {
+	db *sql.DB
+}
+
+func (s *PasskeyStore) Create(ctx context.Context, passkey *Passkey) error {
+	query := `
+	INSERT INTO passkeys (user_id, credential_id, name, sign_count, credential)
+	VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(
+		ctx,
+		query,
+		passkey.UserID,
+		passkey.CredentialID,
+		passkey.Name,
+		passkey.SignCount,
+		passkey.Credential,
+	).Scan(
+		&passkey.ID,
+		&passkey.CreatedAt,
+	)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return ErrorConflict
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (s *PasskeyStore) GetByUserID(ctx context.Context, userID int64) ([]Passkey, error) {
+	query := `
+	SELECT id, user_id, credential_id, name, sign_count, credential, created_at, last_used_at
+	FROM passkeys
+	WHERE user_id = $1
+	ORDER BY created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	passkeys := []Passkey{}
+	for rows.Next() {
+		var p Passkey
+		err := rows.Scan(
+			&p.ID,
+			&p.UserID,
+			&p.CredentialID,
+			&p.Name,
+			&p.SignCount,
+			&p.Credential,
+			&p.CreatedAt,
+			&p.LastUsedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		passkeys = append(passkeys, p)
+	}
+
+	return passkeys, rows.Err()
+}
+
+// UpdateAfterLogin stores the sign count and flags reported by the authenticator.
+func (s *PasskeyStore) UpdateAfterLogin(ctx context.Context, passkey *Passkey) error {
+	query := `
+	UPDATE passkeys SET sign_count = $1, credential = $2, last_used_at = NOW()
+	WHERE credential_id = $3
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, passkey.SignCount, passkey.Credential, passkey.CredentialID)
+	return err
+}
+
+func (s *PasskeyStore) Delete(ctx context.Context, userID, passkeyID int64) error {
+	query := `DELETE FROM passkeys WHERE id = $1 AND user_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, passkeyID, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// CreateSession keeps the ceremony state between the begin and finish calls,
+// keyed by its challenge. userID is 0 for a login that doesn't know the user yet.
+func (s *PasskeyStore) CreateSession(ctx context.Context, challenge string, userID int64, data []byte, exp time.Duration) error {
+	query := `
+	INSERT INTO passkey_sessions (challenge, user_id, data, expiry)
+	VALUES ($1, NULLIF($2, 0), $3, $4)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, challenge, userID, data, time.Now().Add(exp))
+	return err
+}
+
+// ConsumeSession returns and deletes a ceremony state, so a challenge can only be answered once.
+func (s *PasskeyStore) ConsumeSession(ctx context.Context, challenge string) (int64, []byte, error) {
+	query := `
+	DELETE FROM passkey_sessions
+	WHERE challenge = $1 AND expiry > $2
+	RETURNING COALESCE(user_id, 0), data
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var (
+		userID int64
+		data   []byte
+	)
+	err := s.db.QueryRowContext(ctx, query, challenge, time.Now()).Scan(&userID, &data)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, nil, ErrNotFound
+		default:
+			return 0, nil, err
+		}
+	}
+
+	return userID, data, nil
+}
+
+// PurgeSessions deletes the ceremony states that expired without being
+// answered and returns how many it deleted.
+func (s *PasskeyStore) PurgeSessions(ctx context.Context) (int64, error) {
+	query := `DELETE FROM passkey_sessions WHERE expiry <= $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
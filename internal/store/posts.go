@@ -19,6 +19,7 @@ type Post struct {
 	Version   int       `json:"version"`
 	Comments  []Comment `json:"comments"`
 	User      User      `json:"user"`
+	Media     []Media   `json:"media"`
 }
 
 type PostWithMetadata struct {
@@ -38,9 +39,18 @@ func (s *PostStore) GetUserFeed(ctx context.Context, userID int64, fq PaginatedF
 	FROM posts p
 	LEFT JOIN users u ON u.id = p.user_id
 	LEFT JOIN comments c ON c.post_id = p.id
-	JOIN followers f ON f.user_id = p.user_id OR p.user_id = $1
 	WHERE 
-		f.user_id = $1 OR p.user_id = $1 AND
+		-- followers only holds approved follows, so private accounts show
+		-- to their approved followers only
+		(p.user_id = $1 OR EXISTS (
+			SELECT 1 FROM followers f WHERE f.user_id = p.user_id AND f.follower_id = $1
+		)) AND
+		-- muted users are left out without telling them
+		NOT EXISTS (
+			SELECT 1 FROM mutes m WHERE m.user_id = $1 AND m.muted_id = p.user_id
+		) AND
+		NOT ` + blockedSQL("p.user_id", "$1") + ` AND
+		(p.user_id = $1 OR ` + goodStandingSQL("u") + `) AND
 		(p.title ILIKE '%' || $4 || '%' OR p.content ILIKE '%' || $4 || '%') AND
 		(p.tags @> $5 OR $5 = '{}')
 	GROUP BY p.id, u.username
@@ -81,36 +91,74 @@ func (s *PostStore) GetUserFeed(ctx context.Context, userID int64, fq PaginatedF
 		feed = append(feed, p)
 	}
 
+	if len(feed) == 0 {
+		return feed, nil
+	}
+
+	postIDs := make([]int64, len(feed))
+	for i, p := range feed {
+		postIDs[i] = p.ID
+	}
+
+	media, err := s.getMedia(ctx, postIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range feed {
+		feed[i].Media = media[feed[i].ID]
+	}
+
 	return feed, nil
 
 }
 
+// Create also attaches post.Media, which only need their ID and alt text set
+// and are filled in once attached. Media that isn't the author's is
+// ErrUnknownMedia.
 func (s *PostStore) Create(ctx context.Context, post *Post) error {
-	query := `
-	INSERT INTO posts (content, title, user_id, tags)
-	VALUES ($1, $2, $3, $4) RETURNING id, created_at, updated_at
-	`
-	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	err := withTX(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+		INSERT INTO posts (content, title, user_id, tags)
+		VALUES ($1, $2, $3, $4) RETURNING id, created_at, updated_at
+		`
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+
+		defer cancel()
+
+		err := tx.QueryRowContext(
+			ctx,
+			query,
+			post.Content,
+			post.Title,
+			post.UserID,
+			// post.Tags,
+			pq.Array(post.Tags),
+		).Scan(
+			&post.ID,
+			&post.CreatedAt,
+			&post.UpdatedAt,
+		)
+		if err != nil {
+			return err
+		}
 
-	defer cancel()
+		return s.attachMedia(ctx, tx, post)
+	})
+	if err != nil {
+		return err
+	}
 
-	err := s.db.QueryRowContext(
-		ctx,
-		query,
-		post.Content,
-		post.Title,
-		post.UserID,
-		// post.Tags,
-		pq.Array(post.Tags),
-	).Scan(
-		&post.ID,
-		&post.CreatedAt,
-		&post.UpdatedAt,
-	)
+	if len(post.Media) == 0 {
+		return nil
+	}
+
+	media, err := s.getMedia(ctx, []int64{post.ID})
 	if err != nil {
 		return err
 	}
 
+	post.Media = media[post.ID]
 	return nil
 }
 
@@ -146,6 +194,12 @@ func (s *PostStore) GetByID(ctx context.Context, id int64) (*Post, error) {
 
 	}
 
+	media, err := s.getMedia(ctx, []int64{post.ID})
+	if err != nil {
+		return nil, err
+	}
+	post.Media = media[post.ID]
+
 	return &post, nil
 }
 
@@ -0,0 +1,168 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var ErrRefreshTokenReused = errors.New("refresh token has already been used")
+
+type RefreshToken struct {
+	Token     string     `json:"-"` // sha256 hash of the token handed to the client
+	UserID    int64      `json:"user_id"`
+	FamilyID  string     `json:"family_id"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at"`
+	CreatedAt string     `json:"created_at"`
+}
+
+type RefreshTokenStore struct {
+	db *sql.DB
+}
+
+func (s *RefreshTokenStore) Create(ctx context.Context, token *RefreshToken) error {
+	return withTX(s.db, ctx, func(tx *sql.Tx) error {
+		return s.create(ctx, tx, token)
+	})
+}
+
+// Rotate exchanges a refresh token for the next one in its family. Presenting
+// a token that was already rotated is treated as theft: the whole family is
+// revoked and ErrRefreshTokenReused is returned.
+func (s *RefreshTokenStore) Rotate(ctx context.Context, token string, next *RefreshToken) error {
+	reused := false
+
+	err := withTX(s.db, ctx, func(tx *sql.Tx) error {
+		current, err := s.getForUpdate(ctx, tx, token)
+		if err != nil {
+			return err
+		}
+
+		if current.RevokedAt != nil {
+			// commit the family revocation, then report the reuse
+			reused = true
+			next.UserID = current.UserID
+			next.FamilyID = current.FamilyID
+			return s.revokeFamily(ctx, tx, current.FamilyID)
+		}
+
+		if time.Now().After(current.ExpiresAt) {
+			return ErrNotFound
+		}
+
+		if err := s.revoke(ctx, tx, current.Token); err != nil {
+			return err
+		}
+
+		next.UserID = current.UserID
+		next.FamilyID = current.FamilyID
+
+		return s.create(ctx, tx, next)
+	})
+	if err != nil {
+		return err
+	}
+
+	if reused {
+		return ErrRefreshTokenReused
+	}
+
+	return nil
+}
+
+// RevokeFamily revokes the token and every other token issued from the same
+// login, and returns the family ID.
+func (s *RefreshTokenStore) RevokeFamily(ctx context.Context, token string) (string, error) {
+	var familyID string
+
+	err := withTX(s.db, ctx, func(tx *sql.Tx) error {
+		current, err := s.getForUpdate(ctx, tx, token)
+		if err != nil {
+			return err
+		}
+
+		familyID = current.FamilyID
+		return s.revokeFamily(ctx, tx, current.FamilyID)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return familyID, nil
+}
+
+func (s *RefreshTokenStore) create(ctx context.Context, tx *sql.Tx, token *RefreshToken) error {
+	query := `
+	INSERT INTO refresh_tokens (token, user_id, family_id, expiry)
+	VALUES ($1, $2, $3, $4) RETURNING created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return tx.QueryRowContext(
+		ctx,
+		query,
+		token.Token,
+		token.UserID,
+		token.FamilyID,
+		token.ExpiresAt,
+	).Scan(&token.CreatedAt)
+}
+
+func (s *RefreshTokenStore) getForUpdate(ctx context.Context, tx *sql.Tx, token string) (*RefreshToken, error) {
+	query := `
+	SELECT token, user_id, family_id, expiry, revoked_at, created_at
+	FROM refresh_tokens
+	WHERE token = $1
+	FOR UPDATE
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rt := &RefreshToken{}
+	err := tx.QueryRowContext(ctx, query, token).Scan(
+		&rt.Token,
+		&rt.UserID,
+		&rt.FamilyID,
+		&rt.ExpiresAt,
+		&rt.RevokedAt,
+		&rt.CreatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return rt, nil
+}
+
+func (s *RefreshTokenStore) revoke(ctx context.Context, tx *sql.Tx, token string) error {
+	query := `UPDATE refresh_tokens SET revoked_at = NOW() WHERE token = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, token)
+	return err
+}
+
+func (s *RefreshTokenStore) revokeFamily(ctx context.Context, tx *sql.Tx, familyID string) error {
+	query := `
+	UPDATE refresh_tokens SET revoked_at = NOW()
+	WHERE family_id = $1 AND revoked_at IS NULL
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, familyID)
+	return err
+}
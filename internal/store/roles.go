@@ -3,13 +3,52 @@ package store
 import (
 	"context"
 	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrLastAdmin         = errors.New("the last admin can't be demoted")
+	ErrDefaultRole       = errors.New("default roles can't be renamed")
+	ErrAdminLockout      = errors.New("the admin role must keep the roles:manage permission")
+	ErrUnknownPermission = errors.New("unknown permission")
 )
 
+// AdminRole is the role that manages the others, there is always at least one
+// active user with it.
+const AdminRole = "admin"
+
+const manageRolesPermission = "roles:manage"
+
+// defaultRoles are seeded by the migrations and referred to by name.
+var defaultRoles = []string{"user", "moderator", AdminRole}
+
 type Role struct {
-	ID          int    `json:"id"`
+	ID          int         `json:"id"`
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Level       int         `json:"level"`
+	Permissions Permissions `json:"permissions,omitempty"`
+}
+
+type Permission struct {
+	ID          int64  `json:"id"`
 	Name        string `json:"name"`
 	Description string `json:"description"`
-	Level       int    `json:"level"`
+}
+
+// Permissions is the set of permission names, like "posts:delete", a user's
+// role grants.
+type Permissions []string
+
+func (p Permissions) Has(permission string) bool {
+	for _, name := range p {
+		if name == permission {
+			return true
+		}
+	}
+	return false
 }
 
 type RoleStore struct {
@@ -36,3 +75,342 @@ func (s *RoleStore) GetByName(ctx context.Context, slug string) (*Role, error) {
 
 	return role, nil
 }
+
+func (s *RoleStore) GetByID(ctx context.Context, id int64) (*Role, error) {
+	query := `
+	SELECT r.id, r.name, COALESCE(r.description, ''), r.level,
+		ARRAY(
+			SELECT p.name FROM role_permissions rp
+			JOIN permissions p ON p.id = rp.permission_id
+			WHERE rp.role_id = r.id
+			ORDER BY p.name
+		)
+	FROM roles r
+	WHERE r.id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	role := &Role{}
+	var permissions []string
+	err := s.db.QueryRowContext(ctx, query, id).Scan(
+		&role.ID,
+		&role.Name,
+		&role.Description,
+		&role.Level,
+		pq.Array(&permissions),
+	)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	role.Permissions = permissions
+
+	return role, nil
+}
+
+func (s *RoleStore) GetAll(ctx context.Context) ([]Role, error) {
+	query := `
+	SELECT r.id, r.name, COALESCE(r.description, ''), r.level,
+		ARRAY(
+			SELECT p.name FROM role_permissions rp
+			JOIN permissions p ON p.id = rp.permission_id
+			WHERE rp.role_id = r.id
+			ORDER BY p.name
+		)
+	FROM roles r
+	ORDER BY r.level, r.id
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := []Role{}
+	for rows.Next() {
+		var role Role
+		var permissions []string
+		if err := rows.Scan(&role.ID, &role.Name, &role.Description, &role.Level, pq.Array(&permissions)); err != nil {
+			return nil, err
+		}
+		role.Permissions = permissions
+		roles = append(roles, role)
+	}
+
+	return roles, rows.Err()
+}
+
+// Create returns ErrorConflict when the name is taken and ErrUnknownPermission
+// when a permission doesn't exist.
+func (s *RoleStore) Create(ctx context.Context, role *Role) error {
+	return withTX(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+		INSERT INTO roles (name, description, level)
+		VALUES ($1, $2, $3) RETURNING id
+		`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		err := tx.QueryRowContext(ctx, query, role.Name, role.Description, role.Level).Scan(&role.ID)
+		if err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+				return ErrorConflict
+			}
+			return err
+		}
+
+		return s.setPermissions(ctx, tx, role)
+	})
+}
+
+// Update replaces the role and its permissions. Default roles keep their
+// names, and the admin role keeps roles:manage so someone can still undo it.
+func (s *RoleStore) Update(ctx context.Context, role *Role) error {
+	return withTX(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		var name string
+		err := tx.QueryRowContext(ctx, `SELECT name FROM roles WHERE id = $1 FOR UPDATE`, role.ID).Scan(&name)
+		if err != nil {
+			switch err {
+			case sql.ErrNoRows:
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		for _, d := range defaultRoles {
+			if name == d && role.Name != name {
+				return ErrDefaultRole
+			}
+		}
+
+		if name == AdminRole && !role.Permissions.Has(manageRolesPermission) {
+			return ErrAdminLockout
+		}
+
+		query := `UPDATE roles SET name = $1, description = $2, level = $3 WHERE id = $4`
+		if _, err := tx.ExecContext(ctx, query, role.Name, role.Description, role.Level, role.ID); err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+				return ErrorConflict
+			}
+			return err
+		}
+
+		return s.setPermissions(ctx, tx, role)
+	})
+}
+
+func (s *RoleStore) setPermissions(ctx context.Context, tx *sql.Tx, role *Role) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM role_permissions WHERE role_id = $1`, role.ID); err != nil {
+		return err
+	}
+
+	if len(role.Permissions) == 0 {
+		return nil
+	}
+
+	query := `
+	INSERT INTO role_permissions (role_id, permission_id)
+	SELECT $1, id FROM permissions WHERE name = ANY($2)
+	`
+
+	res, err := tx.ExecContext(ctx, query, role.ID, pq.Array(role.Permissions))
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	// names are unique, so a missing row is a name that doesn't exist
+	if rows != int64(len(role.Permissions)) {
+		return ErrUnknownPermission
+	}
+
+	return nil
+}
+
+func (s *RoleStore) GetAllPermissions(ctx context.Context) ([]Permission, error) {
+	query := `SELECT id, name, COALESCE(description, '') FROM permissions ORDER BY name`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	permissions := []Permission{}
+	for rows.Next() {
+		var p Permission
+		if err := rows.Scan(&p.ID, &p.Name, &p.Description); err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, p)
+	}
+
+	return permissions, rows.Err()
+}
+
+// GetPermissions returns the effective permissions of a user, an unknown
+// user has none.
+func (s *RoleStore) GetPermissions(ctx context.Context, userID int64) (Permissions, error) {
+	query := `
+	SELECT p.name
+	FROM users u
+	JOIN role_permissions rp ON rp.role_id = u.role_id
+	JOIN permissions p ON p.id = rp.permission_id
+	WHERE u.id = $1
+	ORDER BY p.name
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	permissions := Permissions{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, name)
+	}
+
+	return permissions, rows.Err()
+}
+
+// Assign gives a user a role and returns the role it had. Demoting the last
+// active admin is refused with ErrLastAdmin, the admins are locked so two
+// demotions can't race past it.
+func (s *RoleStore) Assign(ctx context.Context, userID, roleID int64) (int64, error) {
+	var previousID int64
+
+	err := withTX(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		var roleName string
+		err := tx.QueryRowContext(ctx, `SELECT name FROM roles WHERE id = $1`, roleID).Scan(&roleName)
+		if err != nil {
+			switch err {
+			case sql.ErrNoRows:
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		query := `
+		SELECT u.id
+		FROM users u
+		JOIN roles r ON r.id = u.role_id
+		WHERE r.name = $1 AND u.is_active = true
+		FOR UPDATE OF u
+		`
+
+		rows, err := tx.QueryContext(ctx, query, AdminRole)
+		if err != nil {
+			return err
+		}
+
+		isAdmin := false
+		admins := 0
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return err
+			}
+			admins++
+			isAdmin = isAdmin || id == userID
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		if isAdmin && admins == 1 && roleName != AdminRole {
+			return ErrLastAdmin
+		}
+
+		query = `
+		UPDATE users u SET role_id = $1
+		FROM users prev
+		WHERE u.id = $2 AND prev.id = u.id
+		RETURNING prev.role_id
+		`
+
+		err = tx.QueryRowContext(ctx, query, roleID, userID).Scan(&previousID)
+		if err != nil {
+			switch err {
+			case sql.ErrNoRows:
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return previousID, nil
+}
+
+// GetUsers lists the users that have a role, oldest accounts first.
+func (s *RoleStore) GetUsers(ctx context.Context, roleID int64, q PaginatedQuery) ([]User, error) {
+	query := `
+	SELECT id, username, email, created_at, is_active, role_id
+	FROM users
+	WHERE role_id = $1
+	ORDER BY id
+	LIMIT $2 OFFSET $3
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, roleID, q.Limit, q.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []User{}
+	for rows.Next() {
+		var u User
+		if err := rows.Scan(&u.ID, &u.Username, &u.Email, &u.CreatedAt, &u.IsActive, &u.RoleID); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+
+	return users, rows.Err()
+}
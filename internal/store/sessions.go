@@ -0,0 +1,234 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Session is a login on a device. Its ID is the jti claim of the access
+// tokens and the family of the refresh tokens issued for that login.
+type Session struct {
+	ID         string     `json:"id"`
+	UserID     int64      `json:"user_id"`
+	UserAgent  string     `json:"user_agent"`
+	IP         string     `json:"ip"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	RevokedAt  *time.Time `json:"-"`
+	CreatedAt  string     `json:"created_at"`
+}
+
+type SessionStore struct {
+	db *sql.DB
+}
+
+// a session stays alive as long as it has a refresh token left to rotate,
+// logging out or resetting the password ends it without touching this table
+const liveSessionCondition = `
+	s.revoked_at IS NULL AND EXISTS (
+		SELECT 1 FROM refresh_tokens rt
+		WHERE rt.family_id = s.id AND rt.revoked_at IS NULL AND rt.expiry > NOW()
+	)
+`
+
+func (s *SessionStore) Create(ctx context.Context, session *Session) error {
+	query := `
+	INSERT INTO sessions (id, user_id, user_agent, ip)
+	VALUES ($1, $2, $3, $4) RETURNING last_seen_at, created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return s.db.QueryRowContext(
+		ctx,
+		query,
+		session.ID,
+		session.UserID,
+		session.UserAgent,
+		session.IP,
+	).Scan(
+		&session.LastSeenAt,
+		&session.CreatedAt,
+	)
+}
+
+// GetByID returns a live session.
+func (s *SessionStore) GetByID(ctx context.Context, id string) (*Session, error) {
+	query := `
+	SELECT s.id, s.user_id, s.user_agent, s.ip, s.last_seen_at, s.revoked_at, s.created_at
+	FROM sessions s
+	WHERE s.id = $1 AND ` + liveSessionCondition
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	session := &Session{}
+	err := s.db.QueryRowContext(ctx, query, id).Scan(
+		&session.ID,
+		&session.UserID,
+		&session.UserAgent,
+		&session.IP,
+		&session.LastSeenAt,
+		&session.RevokedAt,
+		&session.CreatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return session, nil
+}
+
+// GetByUserID returns the live sessions of a user, most recently used first.
+func (s *SessionStore) GetByUserID(ctx context.Context, userID int64) ([]Session, error) {
+	query := `
+	SELECT s.id, s.user_id, s.user_agent, s.ip, s.last_seen_at, s.revoked_at, s.created_at
+	FROM sessions s
+	WHERE s.user_id = $1 AND ` + liveSessionCondition + `
+	ORDER BY s.last_seen_at DESC
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []Session{}
+	for rows.Next() {
+		var session Session
+		err := rows.Scan(
+			&session.ID,
+			&session.UserID,
+			&session.UserAgent,
+			&session.IP,
+			&session.LastSeenAt,
+			&session.RevokedAt,
+			&session.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		sessions = append(sessions, session)
+	}
+
+	return sessions, rows.Err()
+}
+
+// Touch records activity on the session, at most once a minute to spare the writes.
+func (s *SessionStore) Touch(ctx context.Context, id string) error {
+	query := `
+	UPDATE sessions SET last_seen_at = NOW()
+	WHERE id = $1 AND last_seen_at < NOW() - INTERVAL '1 minute'
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, id)
+	return err
+}
+
+// Revoke ends a session of the user and drops its refresh tokens.
+func (s *SessionStore) Revoke(ctx context.Context, userID int64, id string) error {
+	return withTX(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+		UPDATE sessions SET revoked_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+		`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		res, err := tx.ExecContext(ctx, query, id, userID)
+		if err != nil {
+			return err
+		}
+
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rows == 0 {
+			return ErrNotFound
+		}
+
+		return deleteRefreshTokens(ctx, tx, []string{id})
+	})
+}
+
+// RevokeAll ends every session of the user but exceptID, which can be empty,
+// and returns their IDs.
+func (s *SessionStore) RevokeAll(ctx context.Context, userID int64, exceptID string) ([]string, error) {
+	var ids []string
+
+	err := withTX(s.db, ctx, func(tx *sql.Tx) error {
+		var err error
+		ids, err = revokeSessions(ctx, tx, userID, exceptID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+func revokeSessions(ctx context.Context, tx *sql.Tx, userID int64, exceptID string) ([]string, error) {
+	query := `
+	UPDATE sessions SET revoked_at = NOW()
+	WHERE user_id = $1 AND id::text <> $2 AND revoked_at IS NULL
+	RETURNING id
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := tx.QueryContext(ctx, query, userID, exceptID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := deleteRefreshTokens(ctx, tx, ids); err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+func deleteRefreshTokens(ctx context.Context, tx *sql.Tx, sessionIDs []string) error {
+	query := `DELETE FROM refresh_tokens WHERE family_id = ANY($1::uuid[])`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, pq.Array(sessionIDs))
+	return err
+}
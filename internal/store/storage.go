@@ -27,28 +27,139 @@ type Storage struct {
 		GetByEmail(context.Context, string) (*User, error)
 		CreateAndInvite(ctx context.Context, user *User, token string, exp time.Duration) error
 		Activate(ctx context.Context, token string) error
+		RotateInvitation(ctx context.Context, email string, token string, exp time.Duration) (*User, error)
+		PurgeInactive(ctx context.Context, grace time.Duration) (int64, int64, error)
 		Delete(context.Context, int64) error
+		CreatePasswordReset(ctx context.Context, userID int64, token string, exp time.Duration) error
+		ResetPassword(ctx context.Context, token string, user *User) error
+		UpdatePassword(context.Context, *User) error
+		ChangePassword(ctx context.Context, user *User, exceptSessionID string) ([]string, error)
+		CreateEmailChange(context.Context, *EmailChange) error
+		ConfirmEmailChange(ctx context.Context, token string) (*EmailChange, error)
+		RevertEmailChange(ctx context.Context, revertToken string) (*EmailChange, error)
+		UpdateProfile(ctx context.Context, userID int64, profile Profile) error
+		Search(ctx context.Context, viewerID int64, q string, limit int) ([]UserSearchResult, error)
+		SearchByPrefix(ctx context.Context, prefix string, limit int) ([]UserSearchResult, error)
+		SetStatus(ctx context.Context, userID int64, status AccountStatus) (*AccountStatus, error)
+		ReinstateExpired(context.Context) ([]int64, error)
 	}
 	Comments interface {
 		Create(context.Context, *Comment) error
-		GetByPostID(ctx context.Context, postID int64) ([]Comment, error)
+		GetByPostID(ctx context.Context, postID, viewerID int64, q CommentQuery) ([]Comment, error)
+		GetReplies(ctx context.Context, parentID, viewerID int64, q CommentQuery) ([]Comment, error)
+		GetRepliesOf(ctx context.Context, parentIDs []int64, viewerID int64, limit int) (map[int64][]Comment, error)
+		GetByID(context.Context, int64) (*Comment, error)
+		Update(context.Context, *Comment) error
+		Delete(context.Context, int64) error
 	}
 	Followers interface {
-		Follow(ctx context.Context, followerID, userID int64) error
+		Follow(ctx context.Context, followerID, userID int64) (bool, error)
 		UnFollow(ctx context.Context, followerID, userID int64) error
+		GetRequests(ctx context.Context, userID int64, q PaginatedQuery) ([]FollowRequest, error)
+		ApproveRequest(ctx context.Context, userID, followerID int64) error
+		RejectRequest(ctx context.Context, userID, followerID int64) error
+		SetPrivate(ctx context.Context, userID int64, private bool) error
+		CanView(ctx context.Context, userID, viewerID int64) (bool, error)
+		GetFollowers(ctx context.Context, userID, viewerID int64, q FollowQuery) ([]FollowEntry, error)
+		GetFollowing(ctx context.Context, userID, viewerID int64, q FollowQuery) ([]FollowEntry, error)
+		Counts(ctx context.Context, userID int64) (int64, int64, error)
+	}
+	Blocks interface {
+		Block(ctx context.Context, userID, blockedID int64) error
+		Unblock(ctx context.Context, userID, blockedID int64) error
+		GetBlocked(ctx context.Context, userID int64, q PaginatedQuery) ([]RelatedUser, error)
+		IsBlocked(ctx context.Context, userID, otherID int64) (bool, error)
+		BlockedAmong(ctx context.Context, userID int64, userIDs []int64) ([]int64, error)
+		Mute(ctx context.Context, userID, mutedID int64) error
+		Unmute(ctx context.Context, userID, mutedID int64) error
+		GetMuted(ctx context.Context, userID int64, q PaginatedQuery) ([]RelatedUser, error)
+	}
+	Media interface {
+		Create(context.Context, *Media) error
+		GetByID(context.Context, string) (*Media, error)
+		Delete(context.Context, string) error
+		PurgeUnattached(ctx context.Context, before time.Time) ([]Media, error)
+	}
+	Suggestions interface {
+		Get(ctx context.Context, userID int64, limit int) ([]Suggestion, error)
+		GetRecentUserIDs(ctx context.Context, since time.Time, limit int) ([]int64, error)
 	}
 	Roles interface {
 		GetByName(context.Context, string) (*Role, error)
+		GetByID(context.Context, int64) (*Role, error)
+		GetAll(context.Context) ([]Role, error)
+		Create(context.Context, *Role) error
+		Update(context.Context, *Role) error
+		GetAllPermissions(context.Context) ([]Permission, error)
+		GetPermissions(ctx context.Context, userID int64) (Permissions, error)
+		Assign(ctx context.Context, userID, roleID int64) (int64, error)
+		GetUsers(ctx context.Context, roleID int64, q PaginatedQuery) ([]User, error)
+	}
+	RefreshTokens interface {
+		Create(context.Context, *RefreshToken) error
+		Rotate(ctx context.Context, token string, next *RefreshToken) error
+		RevokeFamily(ctx context.Context, token string) (string, error)
+	}
+	MFA interface {
+		GetByUserID(context.Context, int64) (*MFA, error)
+		Enroll(ctx context.Context, userID int64, secret string) error
+		Confirm(ctx context.Context, userID int64, step int64, recoveryCodes []string) error
+		UseStep(ctx context.Context, userID int64, step int64) error
+		UseRecoveryCode(ctx context.Context, userID int64, code string) error
+		Disable(context.Context, int64) error
+	}
+	Passkeys interface {
+		Create(context.Context, *Passkey) error
+		GetByUserID(context.Context, int64) ([]Passkey, error)
+		UpdateAfterLogin(context.Context, *Passkey) error
+		Delete(ctx context.Context, userID, passkeyID int64) error
+		CreateSession(ctx context.Context, challenge string, userID int64, data []byte, exp time.Duration) error
+		ConsumeSession(ctx context.Context, challenge string) (int64, []byte, error)
+		PurgeSessions(context.Context) (int64, error)
+	}
+	PersonalAccessTokens interface {
+		Create(context.Context, *PersonalAccessToken) error
+		GetByUserID(context.Context, int64) ([]PersonalAccessToken, error)
+		GetByToken(context.Context, string) (*PersonalAccessToken, error)
+		Touch(context.Context, int64) error
+		Delete(ctx context.Context, userID, tokenID int64) error
+	}
+	Sessions interface {
+		Create(context.Context, *Session) error
+		GetByID(context.Context, string) (*Session, error)
+		GetByUserID(context.Context, int64) ([]Session, error)
+		Touch(context.Context, string) error
+		Revoke(ctx context.Context, userID int64, id string) error
+		RevokeAll(ctx context.Context, userID int64, exceptID string) ([]string, error)
+	}
+	Audit interface {
+		Create(context.Context, *AuditEntry) error
+		Get(context.Context, AuditQuery) ([]AuditEntry, error)
+	}
+	Impersonations interface {
+		Create(context.Context, *Impersonation) error
+		GetByID(context.Context, string) (*Impersonation, error)
+		End(context.Context, string) error
 	}
 }
 
 func NewStorage(db *sql.DB) Storage {
 	return Storage{
-		Posts:     &PostStore{db},
-		Users:     &UserStore{db},
-		Comments:  &CommentStore{db},
-		Followers: &FollowerStore{db},
-		Roles:     &RoleStore{db},
+		Posts:                &PostStore{db},
+		Users:                &UserStore{db},
+		Comments:             &CommentStore{db},
+		Followers:            &FollowerStore{db},
+		Blocks:               &BlockStore{db},
+		Suggestions:          &SuggestionStore{db},
+		Media:                &MediaStore{db},
+		Roles:                &RoleStore{db},
+		RefreshTokens:        &RefreshTokenStore{db},
+		MFA:                  &MFAStore{db},
+		Passkeys:             &PasskeyStore{db},
+		PersonalAccessTokens: &PersonalAccessTokenStore{db},
+		Sessions:             &SessionStore{db},
+		Audit:                &AuditStore{db},
+		Impersonations:       &ImpersonationStore{db},
 	}
 }
 
@@ -0,0 +1,141 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Suggestion is a user worth following, with what it was ranked on.
+type Suggestion struct {
+	ID            int64   `json:"id"`
+	Username      string  `json:"username"`
+	MutualFollows int64   `json:"mutual_follows"` // followed by users you follow
+	SharedTags    int64   `json:"shared_tags"`    // recently posted tags you also post
+	RecentPosts   int64   `json:"recent_posts"`
+	Score         float64 `json:"score"`
+}
+
+// SuggestionActivityWindow is how far back posts count as recent activity
+// and shared tags.
+const SuggestionActivityWindow = 30 * 24 * time.Hour
+
+type SuggestionStore struct {
+	db *sql.DB
+}
+
+// Get ranks the users userID could follow. A mutual follow weighs most, then a
+// shared tag, then recent posts, which are dampened so a prolific stranger
+// doesn't outrank friends of friends. Followed, requested, muted and blocked
+// users are left out.
+func (s *SuggestionStore) Get(ctx context.Context, userID int64, limit int) ([]Suggestion, error) {
+	query := `
+	WITH following AS (
+		SELECT user_id FROM followers WHERE follower_id = $1
+	),
+	mutual AS (
+		SELECT f.user_id AS id, COUNT(*) AS n
+		FROM followers f
+		JOIN following fw ON fw.user_id = f.follower_id
+		GROUP BY f.user_id
+	),
+	my_tags AS (
+		SELECT DISTINCT t.tag
+		FROM posts p CROSS JOIN LATERAL unnest(p.tags) AS t(tag)
+		WHERE p.user_id = $1 AND p.created_at > $3
+	),
+	shared AS (
+		SELECT p.user_id AS id, COUNT(DISTINCT t.tag) AS n
+		FROM posts p CROSS JOIN LATERAL unnest(p.tags) AS t(tag)
+		JOIN my_tags mt ON mt.tag = t.tag
+		WHERE p.created_at > $3
+		GROUP BY p.user_id
+	),
+	active AS (
+		SELECT p.user_id AS id, COUNT(*) AS n
+		FROM posts p
+		WHERE p.created_at > $3
+		GROUP BY p.user_id
+	)
+	SELECT u.id, u.username,
+		COALESCE(m.n, 0), COALESCE(sh.n, 0), COALESCE(a.n, 0),
+		3 * COALESCE(m.n, 0) + 2 * COALESCE(sh.n, 0) + LN(1 + COALESCE(a.n, 0)) AS score
+	FROM users u
+	LEFT JOIN mutual m ON m.id = u.id
+	LEFT JOIN shared sh ON sh.id = u.id
+	LEFT JOIN active a ON a.id = u.id
+	WHERE u.id <> $1 AND u.is_active = true AND ` + goodStandingSQL("u") + `
+		AND (m.id IS NOT NULL OR sh.id IS NOT NULL OR a.id IS NOT NULL)
+		AND NOT EXISTS (SELECT 1 FROM following fw WHERE fw.user_id = u.id)
+		AND NOT EXISTS (SELECT 1 FROM follow_requests fr WHERE fr.user_id = u.id AND fr.follower_id = $1)
+		AND NOT EXISTS (SELECT 1 FROM mutes mu WHERE mu.user_id = $1 AND mu.muted_id = u.id)
+		AND NOT ` + blockedSQL("u.id", "$1") + `
+	ORDER BY score DESC, u.id DESC
+	LIMIT $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	since := time.Now().Add(-SuggestionActivityWindow)
+
+	rows, err := s.db.QueryContext(ctx, query, userID, limit, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	suggestions := []Suggestion{}
+	for rows.Next() {
+		var sg Suggestion
+		err := rows.Scan(
+			&sg.ID,
+			&sg.Username,
+			&sg.MutualFollows,
+			&sg.SharedTags,
+			&sg.RecentPosts,
+			&sg.Score,
+		)
+		if err != nil {
+			return nil, err
+		}
+		suggestions = append(suggestions, sg)
+	}
+
+	return suggestions, rows.Err()
+}
+
+// GetRecentUserIDs lists up to limit users with a live session seen since the
+// given time, the most recently seen first. They are the ones whose
+// suggestions are worth computing ahead of time.
+func (s *SuggestionStore) GetRecentUserIDs(ctx context.Context, since time.Time, limit int) ([]int64, error) {
+	query := `
+	SELECT s.user_id
+	FROM sessions s
+	JOIN users u ON u.id = s.user_id
+	WHERE s.revoked_at IS NULL AND s.last_seen_at > $1 AND u.is_active = true
+	GROUP BY s.user_id
+	ORDER BY MAX(s.last_seen_at) DESC
+	LIMIT $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, since, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
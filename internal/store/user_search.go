@@ -0,0 +1,114 @@
+package store
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// UserSearchResult is a user matching a search, Score is how closely, from 0
+// to 1.
+type UserSearchResult struct {
+	ID          int64   `json:"id"`
+	Username    string  `json:"username"`
+	DisplayName string  `json:"display_name"`
+	AvatarURL   string  `json:"avatar_url"`
+	Score       float64 `json:"score"`
+}
+
+// UserSearchQuery searches users by username and display name. Prefix only
+// matches the start of usernames, for @mention autocomplete.
+type UserSearchQuery struct {
+	Q      string `json:"q" validate:"required,max=50"`
+	Prefix bool   `json:"prefix"`
+	Limit  int    `json:"limit" validate:"gte=1,lte=20"`
+}
+
+func (q UserSearchQuery) Parse(r *http.Request) (UserSearchQuery, error) {
+	qs := r.URL.Query()
+
+	// a leading @ is how mentions are typed, not part of the username
+	q.Q = strings.TrimPrefix(strings.TrimSpace(qs.Get("q")), "@")
+
+	if prefix := qs.Get("prefix"); prefix != "" {
+		p, err := strconv.ParseBool(prefix)
+		if err != nil {
+			return q, err
+		}
+
+		q.Prefix = p
+	}
+
+	if limit := qs.Get("limit"); limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return q, err
+		}
+
+		q.Limit = l
+	}
+
+	return q, nil
+}
+
+// Search ranks active users by how similar their username or display name is
+// to q, leaving out the users viewerID is blocked with.
+func (s *UserStore) Search(ctx context.Context, viewerID int64, q string, limit int) ([]UserSearchResult, error) {
+	query := `
+	SELECT u.id, u.username, u.display_name, u.avatar_url,
+		GREATEST(similarity(u.username, $1), similarity(u.display_name, $1)) AS score
+	FROM users u
+	WHERE u.is_active = true AND ` + goodStandingSQL("u") + `
+		AND (u.username % $1 OR u.display_name % $1 OR u.username ILIKE $2 || '%')
+		AND NOT ` + blockedSQL("u.id", "$3") + `
+	ORDER BY score DESC, u.username
+	LIMIT $4
+	`
+
+	return s.searchUsers(ctx, query, q, escapeLike(q), viewerID, limit)
+}
+
+// SearchByPrefix lists the active users whose username starts with prefix,
+// shortest first so an exact match comes on top. It is the same for every
+// viewer so it can be cached, blocks are left to the caller.
+func (s *UserStore) SearchByPrefix(ctx context.Context, prefix string, limit int) ([]UserSearchResult, error) {
+	query := `
+	SELECT u.id, u.username, u.display_name, u.avatar_url,
+		length($1)::float / length(u.username) AS score
+	FROM users u
+	WHERE u.is_active = true AND ` + goodStandingSQL("u") + `
+		AND lower(u.username) LIKE lower($2) || '%'
+	ORDER BY length(u.username), u.username
+	LIMIT $3
+	`
+
+	return s.searchUsers(ctx, query, prefix, escapeLike(prefix), limit)
+}
+
+func (s *UserStore) searchUsers(ctx context.Context, query string, args ...any) ([]UserSearchResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []UserSearchResult{}
+	for rows.Next() {
+		var u UserSearchResult
+		if err := rows.Scan(&u.ID, &u.Username, &u.DisplayName, &u.AvatarURL, &u.Score); err != nil {
+			return nil, err
+		}
+		results = append(results, u)
+	}
+
+	return results, rows.Err()
+}
+
+// escapeLike escapes the LIKE wildcards in s, so it only matches literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,25 @@
+package store
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEscapeLike(t *testing.T) {
+	if got := escapeLike(`50%_off\`); got != `50\%\_off\\` {
+		t.Errorf("expected the wildcards escaped, got %s", got)
+	}
+}
+
+func TestUserSearchQueryParse(t *testing.T) {
+	r := httptest.NewRequest("GET", "/v1/users/search?q=%20@gopher&prefix=true", nil)
+
+	q, err := UserSearchQuery{Limit: 10}.Parse(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if q.Q != "gopher" || !q.Prefix || q.Limit != 10 {
+		t.Errorf("expected a prefix search for gopher, got %+v", q)
+	}
+}
@@ -7,13 +7,12 @@ import (
 	"encoding/hex"
 	"errors"
 	"time"
-
-	"golang.org/x/crypto/bcrypt"
 )
 
 var (
 	ErrDuplicateEmail    = errors.New("a user with that email already exists")
 	ErrDuplicateUsername = errors.New("a user with that username already exists")
+	ErrTokenExpired      = errors.New("token has expired")
 )
 
 type User struct {
@@ -24,8 +23,20 @@ type User struct {
 	Password  password `json:"-"`
 	CreatedAt string   `json:"created_at"`
 	IsActive  bool     `json:"is_active"`
+	IsPrivate bool     `json:"is_private"`
 	RoleID    int64    `json:"role_id"` //after authentication we created the coloumn of roles for the permissions
 	Role      Role     `json:"role"`
+	Profile
+	AccountStatus
+}
+
+// Profile is what a user tells about themselves, it is shown to everyone.
+type Profile struct {
+	DisplayName string `json:"display_name"`
+	Bio         string `json:"bio"`
+	Website     string `json:"website"`
+	Location    string `json:"location"`
+	AvatarURL   string `json:"avatar_url"`
 }
 
 type password struct {
@@ -34,7 +45,7 @@ type password struct {
 }
 
 func (p *password) Set(text string) error {
-	hash, err := bcrypt.GenerateFromPassword([]byte(text), bcrypt.DefaultCost)
+	hash, err := DefaultPasswordHasher.Hash(text)
 	if err != nil {
 		return err
 	}
@@ -44,7 +55,21 @@ func (p *password) Set(text string) error {
 }
 
 func (p *password) Compare(text string) error {
-	return bcrypt.CompareHashAndPassword(p.hash, []byte(text))
+	hasher, err := passwordHasherFor(p.hash)
+	if err != nil {
+		return err
+	}
+	return hasher.Compare(p.hash, text)
+}
+
+// NeedsRehash reports whether the hash should be replaced by one from
+// DefaultPasswordHasher, which can only be done while the text is at hand.
+func (p *password) NeedsRehash() bool {
+	hasher, err := passwordHasherFor(p.hash)
+	if err != nil {
+		return true
+	}
+	return hasher != DefaultPasswordHasher || hasher.Outdated(p.hash)
 }
 
 type UserStore struct {
@@ -101,7 +126,9 @@ func (s *UserStore) Create(ctx context.Context, tx *sql.Tx, user *User) error {
 func (s *UserStore) GetByID(ctx context.Context, id int64) (*User, error) {
 	//is_active is added to the query after we had create the coloum in the databases
 	query := `
-	SELECT users.id, email, username, password, created_at, roles.*
+	SELECT users.id, email, username, password, created_at, is_private,
+		display_name, bio, website, location, avatar_url,
+		account_state, state_reason, suspended_until, roles.*
 	FROM users
 	JOIN roles ON (users.role_id = roles.id)
 	WHERE users.id = $1 AND is_active = true
@@ -117,6 +144,15 @@ func (s *UserStore) GetByID(ctx context.Context, id int64) (*User, error) {
 		&User.Username,
 		&User.Password.hash,
 		&User.CreatedAt,
+		&User.IsPrivate,
+		&User.DisplayName,
+		&User.Bio,
+		&User.Website,
+		&User.Location,
+		&User.AvatarURL,
+		&User.State,
+		&User.StateReason,
+		&User.SuspendedUntil,
 		&User.Role.ID,
 		&User.Role.Name,
 		&User.Role.Level,
@@ -194,12 +230,14 @@ func (s *UserStore) createUserInvitation(ctx context.Context, tx *sql.Tx, token
 	return nil
 }
 
+// getUserFromInvitation returns ErrTokenExpired rather than ErrNotFound for a
+// token that exists but has expired, so the user can be told to ask for a new one.
 func (s *UserStore) getUserFromInvitation(ctx context.Context, tx *sql.Tx, token string) (*User, error) {
 	query := `
-	SELECT user_id, u.username, u.email, u.created_at, u.is_active	
+	SELECT user_id, u.username, u.email, u.created_at, u.is_active, ui.expiry
 	FROM users u
 	JOIN user_invitations ui ON u.id = ui.user_id
-	WHERE ui.token = $1 AND ui.expiry > $2
+	WHERE ui.token = $1
 	`
 	hash := sha256.Sum256([]byte(token))
 	hashToken := hex.EncodeToString(hash[:])
@@ -209,12 +247,14 @@ func (s *UserStore) getUserFromInvitation(ctx context.Context, tx *sql.Tx, token
 	defer cancel()
 
 	user := &User{}
-	err := tx.QueryRowContext(ctx, query, hashToken, time.Now()).Scan(
+	var expiry time.Time
+	err := tx.QueryRowContext(ctx, query, hashToken).Scan(
 		&user.ID,
 		&user.Username,
 		&user.Email,
 		&user.CreatedAt,
 		&user.IsActive,
+		&expiry,
 	)
 	if err != nil {
 		switch err {
@@ -224,9 +264,140 @@ func (s *UserStore) getUserFromInvitation(ctx context.Context, tx *sql.Tx, token
 			return nil, err
 		}
 	}
+
+	if time.Now().After(expiry) {
+		return nil, ErrTokenExpired
+	}
+
+	return user, nil
+}
+
+// RotateInvitation replaces the invitations of the inactive user with the
+// email by a new one, and returns the user.
+func (s *UserStore) RotateInvitation(ctx context.Context, email string, token string, invitationEXP time.Duration) (*User, error) {
+	user := &User{}
+
+	err := withTX(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+		SELECT id, username, email, created_at
+		FROM users
+		WHERE email = $1 AND is_active = false
+		FOR UPDATE
+		`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		err := tx.QueryRowContext(ctx, query, email).Scan(
+			&user.ID,
+			&user.Username,
+			&user.Email,
+			&user.CreatedAt,
+		)
+		if err != nil {
+			switch err {
+			case sql.ErrNoRows:
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		// the old links stop working, only the latest mail can activate
+		if err := s.deleteUserInvitation(ctx, tx, user.ID); err != nil {
+			return err
+		}
+
+		return s.createUserInvitation(ctx, tx, token, invitationEXP, user.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	return user, nil
 }
 
+// PurgeInactive deletes the invitations that expired more than the grace
+// period ago, then the accounts that were never activated within it and have
+// no invitation left. It returns the number of invitations and accounts
+// deleted.
+func (s *UserStore) PurgeInactive(ctx context.Context, grace time.Duration) (int64, int64, error) {
+	var invitations, users int64
+
+	// an expired invitation is kept for the grace period so its link still
+	// answers that it expired
+	cutoff := time.Now().Add(-grace)
+
+	err := withTX(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		res, err := tx.ExecContext(ctx, `DELETE FROM user_invitations WHERE expiry < $1`, cutoff)
+		if err != nil {
+			return err
+		}
+
+		if invitations, err = res.RowsAffected(); err != nil {
+			return err
+		}
+
+		query := `
+		DELETE FROM users u
+		WHERE u.is_active = false AND u.created_at < $1
+		AND NOT EXISTS (SELECT 1 FROM user_invitations ui WHERE ui.user_id = u.id)
+		`
+
+		res, err = tx.ExecContext(ctx, query, cutoff)
+		if err != nil {
+			return err
+		}
+
+		users, err = res.RowsAffected()
+		return err
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return invitations, users, nil
+}
+
+func (s *UserStore) UpdateProfile(ctx context.Context, userID int64, profile Profile) error {
+	query := `
+	UPDATE users
+	SET display_name = $1, bio = $2, website = $3, location = $4, avatar_url = $5
+	WHERE id = $6 AND is_active = true
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(
+		ctx,
+		query,
+		profile.DisplayName,
+		profile.Bio,
+		profile.Website,
+		profile.Location,
+		profile.AvatarURL,
+		userID,
+	)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
 func (s *UserStore) update(ctx context.Context, tx *sql.Tx, user *User) error {
 	query := `UPDATE users SET username = $1, email = $2, is_active = $3 WHERE id = $4`
 
@@ -283,7 +454,8 @@ func (s *UserStore) delete(ctx context.Context, tx *sql.Tx, id int64) error {
 func (s *UserStore) GetByEmail(ctx context.Context, email string) (*User, error) {
 
 	query := `
-	SELECT id, email, username, password, created_at
+	SELECT id, email, username, password, created_at,
+		account_state, state_reason, suspended_until
 	FROM users
 	WHERE email = $1 AND is_active = true
 	`
@@ -297,6 +469,9 @@ func (s *UserStore) GetByEmail(ctx context.Context, email string) (*User, error)
 		&user.Username,
 		&user.Password.hash,
 		&user.CreatedAt,
+		&user.State,
+		&user.StateReason,
+		&user.SuspendedUntil,
 	)
 	if err != nil {
 		switch {
@@ -310,3 +485,150 @@ func (s *UserStore) GetByEmail(ctx context.Context, email string) (*User, error)
 
 	return user, nil
 }
+
+func (s *UserStore) CreatePasswordReset(ctx context.Context, userID int64, token string, exp time.Duration) error {
+	query := `
+	INSERT INTO password_resets (token, user_id, expiry)
+	VALUES ($1, $2, $3)
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, token, userID, time.Now().Add(exp))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *UserStore) ResetPassword(ctx context.Context, token string, user *User) error {
+	return withTX(s.db, ctx, func(tx *sql.Tx) error {
+		// find the user that this token belongs to
+		u, err := s.getUserFromPasswordReset(ctx, tx, token)
+		if err != nil {
+			return err
+		}
+
+		user.ID = u.ID
+		user.Username = u.Username
+		user.Email = u.Email
+
+		if err := s.updatePassword(ctx, tx, user); err != nil {
+			return err
+		}
+
+		// the token is single use, and any other outstanding one is void now
+		if err := s.deletePasswordResets(ctx, tx, user.ID); err != nil {
+			return err
+		}
+
+		// sign the user out everywhere, whoever asked for the reset may have been logged in
+		return s.revokeRefreshTokens(ctx, tx, user.ID)
+	})
+}
+
+func (s *UserStore) getUserFromPasswordReset(ctx context.Context, tx *sql.Tx, token string) (*User, error) {
+	query := `
+	SELECT user_id, u.username, u.email
+	FROM users u
+	JOIN password_resets pr ON u.id = pr.user_id
+	WHERE pr.token = $1 AND pr.expiry > $2 AND u.is_active = true
+	`
+	hash := sha256.Sum256([]byte(token))
+	hashToken := hex.EncodeToString(hash[:])
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	user := &User{}
+	err := tx.QueryRowContext(ctx, query, hashToken, time.Now()).Scan(
+		&user.ID,
+		&user.Username,
+		&user.Email,
+	)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+	return user, nil
+}
+
+func (s *UserStore) UpdatePassword(ctx context.Context, user *User) error {
+	return withTX(s.db, ctx, func(tx *sql.Tx) error {
+		return s.updatePassword(ctx, tx, user)
+	})
+}
+
+// ChangePassword sets the new password of the user, voids its outstanding
+// reset links and ends every session but exceptSessionID, which can be empty.
+// It returns the IDs of the sessions it ended.
+func (s *UserStore) ChangePassword(ctx context.Context, user *User, exceptSessionID string) ([]string, error) {
+	var sessionIDs []string
+
+	err := withTX(s.db, ctx, func(tx *sql.Tx) error {
+		if err := s.updatePassword(ctx, tx, user); err != nil {
+			return err
+		}
+
+		// a reset link mailed before the change would undo it
+		if err := s.deletePasswordResets(ctx, tx, user.ID); err != nil {
+			return err
+		}
+
+		var err error
+		sessionIDs, err = revokeSessions(ctx, tx, user.ID, exceptSessionID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return sessionIDs, nil
+}
+
+func (s *UserStore) updatePassword(ctx context.Context, tx *sql.Tx, user *User) error {
+	query := `UPDATE users SET password = $1 WHERE id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, user.Password.hash, user.ID)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *UserStore) deletePasswordResets(ctx context.Context, tx *sql.Tx, userID int64) error {
+	query := `DELETE FROM password_resets WHERE user_id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *UserStore) revokeRefreshTokens(ctx context.Context, tx *sql.Tx, userID int64) error {
+	query := `
+	UPDATE refresh_tokens SET revoked_at = NOW()
+	WHERE user_id = $1 AND revoked_at IS NULL
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+	return nil
+}